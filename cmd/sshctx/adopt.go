@@ -0,0 +1,114 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/discovery"
+	"github.com/spencercjh/sshctx/internal/printer"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+// AdoptOp describes turning a discovered host into a Host block of sshconfig.
+type AdoptOp struct {
+	Target string // discovered host like `user@host:port`
+	Name   string // name of the Host block, the hostname if empty
+	DryRun bool   // print the Host block instead of adding it
+}
+
+func (op AdoptOp) Run(stdout, stderr io.Writer) error {
+	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.DefaultLoader)
+
+	defer func(sshConfig *sshconfig.SSHConfig) {
+		_ = sshConfig.Close()
+	}(sc)
+
+	if err := sc.Parse(); err != nil {
+		return errors.Wrap(err, "sshconfig error")
+	}
+	d, ok := discovery.ParseName(op.Target)
+	if !ok {
+		return errors.Errorf("invalid host '%s', expected [user@]host[:port]", op.Target)
+	}
+	if h, ok := configuredHost(sc, d); ok {
+		return errors.Errorf("%s is already in ssh_config as %s", op.Target, h.DisplayName)
+	}
+	name := op.Name
+	if name == "" {
+		name = d.Host
+	}
+	if _, err := sc.Lookup(name); err == nil {
+		return errors.Errorf("there is already a host named %s in ssh_config, choose another one with --name", name)
+	}
+
+	block := hostBlock(name, d)
+	if op.DryRun {
+		_, err := fmt.Fprint(stdout, block)
+		return err
+	}
+	path, err := sshconfig.GetSSHConfigPath()
+	if err != nil {
+		return errors.Wrap(err, "Can't determine sshconfig path")
+	}
+	if err := appendHostBlock(path, block); err != nil {
+		return err
+	}
+	_ = printer.Success(stderr, "Added host %s to %s.", printer.SuccessColor.Sprint(name), tildePath(path))
+	return nil
+}
+
+// hostBlock returns the Host block of sshconfig connecting to d as name.
+func hostBlock(name string, d discovery.Host) string {
+	var b strings.Builder
+	b.WriteString("Host " + name + "\n")
+	b.WriteString("    Hostname " + d.Host + "\n")
+	if d.User != "" {
+		b.WriteString("    User " + d.User + "\n")
+	}
+	if d.Port > 0 && d.Port != 22 {
+		b.WriteString("    Port " + strconv.Itoa(d.Port) + "\n")
+	}
+	return b.String()
+}
+
+// appendHostBlock adds block to the end of the sshconfig file, separated
+// from the hosts before it by an empty line.
+func appendHostBlock(path, block string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "Can't read sshconfig")
+	}
+	if len(data) > 0 {
+		if data[len(data)-1] != '\n' {
+			block = "\n" + block
+		}
+		block = "\n" + block
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return errors.Wrap(err, "Can't open sshconfig")
+	}
+	if _, err := f.WriteString(block); err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "Can't write sshconfig")
+	}
+	return errors.Wrap(f.Close(), "Can't write sshconfig")
+}
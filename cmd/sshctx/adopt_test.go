@@ -0,0 +1,89 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/spencercjh/sshctx/internal/discovery"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+func TestFilterDiscovered(t *testing.T) {
+	setupKnownHosts(t, knownHostsTestConfig)
+	t.Setenv("USER", "me")
+	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.DefaultLoader)
+	defer sc.Close()
+	if err := sc.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	found := []discovery.Host{
+		{Host: "web"}, // name of a configured host
+		{Host: "10.0.0.2", Port: 2222, User: "admin"}, // Hostname and Port of a configured host
+		{Host: "10.0.0.2", User: "admin"},             // same address, other port
+		{Host: "10.0.0.3", User: "root"},              // typed in the history
+		{Host: "10.0.0.3"},                            // its known_hosts entry
+		{Host: "10.0.0.3", User: "root"},              // typed again
+		{Host: "build.example.com"},                   // only in known_hosts
+		{Host: "devbox"},                              // not a valid ssh parameter
+	}
+	var got []string
+	for _, h := range filterDiscovered(sc, found) {
+		got = append(got, h.DisplayName+"#"+h.ToSSHParameter())
+	}
+	want := []string{"admin@10.0.0.2#admin@10.0.0.2", "build.example.com#me@build.example.com", "root@10.0.0.3#root@10.0.0.3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filterDiscovered() = %v, want %v", got, want)
+	}
+}
+
+func TestAdoptOp(t *testing.T) {
+	setupKnownHosts(t, knownHostsTestConfig)
+	path := os.Getenv("SSHCONFIG")
+
+	var stdout, stderr bytes.Buffer
+	if err := (AdoptOp{Target: "deploy@10.0.0.3:2200"}).Run(&stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	want := knownHostsTestConfig + "\nHost 10.0.0.3\n    Hostname 10.0.0.3\n    User deploy\n    Port 2200\n"
+	if string(data) != want {
+		t.Errorf("sshconfig after adopt =\n%s\nwant\n%s", data, want)
+	}
+
+	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.DefaultLoader)
+	defer sc.Close()
+	if err := sc.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if h, err := sc.Lookup("10.0.0.3"); err != nil || h.Username != "deploy" || h.Port != 2200 {
+		t.Errorf("adopted host = %+v, %v", h, err)
+	}
+
+	for _, op := range []AdoptOp{
+		{Target: "deploy@10.0.0.3:2200"},  // adopted already
+		{Target: "10.0.0.4", Name: "web"}, // name taken
+		{Target: "root@10.0.0.2:2222"},    // Hostname and Port of db
+		{Target: "$HOST"},                 // invalid
+	} {
+		if err := op.Run(&stdout, &stderr); err == nil {
+			t.Errorf("%+v succeeded", op)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/printer"
+	"github.com/spencercjh/sshctx/internal/sshagent"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+// AgentOp describes showing whether ssh-agent holds the keys of the hosts.
+type AgentOp struct{}
+
+func (op AgentOp) Run(stdout, _ io.Writer) error {
+	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.DefaultLoader)
+
+	defer func(sshConfig *sshconfig.SSHConfig) {
+		_ = sshConfig.Close()
+	}(sc)
+
+	if err := sc.Parse(); err != nil {
+		return errors.Wrap(err, "sshconfig error")
+	}
+	c, err := sshagent.Dial()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = c.Close() }()
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "HOST\tIDENTITY\tSTATUS")
+	for _, h := range sc.Hosts {
+		keys, err := c.Check(sc.IdentityFiles(h))
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			_, _ = fmt.Fprintf(w, "%s\t-\t%s\n", h.DisplayName, printer.WarningColor.Sprint("no identity file"))
+		}
+		for _, k := range keys {
+			status := printer.SuccessColor.Sprint("loaded")
+			switch {
+			case k.Err != nil:
+				status = printer.ErrorColor.Sprint(k.Err)
+			case !k.Loaded:
+				status = printer.ErrorColor.Sprint("not loaded")
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", h.DisplayName, tildePath(k.Path), status)
+		}
+	}
+	return w.Flush()
+}
+
+// ensureAgentKey offers to ssh-add the first readable identity file when
+// ssh-agent holds none of them. Problems are reported but never stop the
+// connection, ssh may still authenticate another way.
+func ensureAgentKey(stderr io.Writer, identityFiles []string, lifetime time.Duration) {
+	if len(identityFiles) == 0 {
+		return
+	}
+	c, err := sshagent.Dial()
+	if err == sshagent.ErrNoAgent {
+		return
+	} else if err != nil {
+		_ = printer.Warning(stderr, "%v", err)
+		return
+	}
+	defer func() { _ = c.Close() }()
+	keys, err := c.Check(identityFiles)
+	if err != nil {
+		_ = printer.Warning(stderr, "%v", err)
+		return
+	}
+	var missing *sshagent.Key
+	for i := range keys {
+		if keys[i].Loaded {
+			return
+		}
+		if keys[i].Err == nil && missing == nil {
+			missing = &keys[i]
+		}
+	}
+	if missing == nil {
+		return
+	}
+
+	msg := fmt.Sprintf("Key %s is not in ssh-agent.", tildePath(missing.Path))
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		_, _ = fmt.Fprintf(stderr, "%s %s\n", printer.WarningColor.Sprint("warning:"), msg)
+		return
+	}
+	_, _ = fmt.Fprintf(stderr, "%s %s Add it with ssh-add? [Y/n] ", printer.WarningColor.Sprint("?"), msg)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if a := strings.ToLower(strings.TrimSpace(answer)); a != "" && a != "y" && a != "yes" {
+		return
+	}
+	cmd := exec.Command("ssh-add", sshAddArgs(missing.Path, lifetime)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		_ = printer.Error(stderr, "ssh-add failed: %v", err)
+	}
+}
+
+// sshAddArgs returns the arguments of ssh-add adding the key at path for lifetime.
+func sshAddArgs(path string, lifetime time.Duration) []string {
+	if lifetime > 0 {
+		return []string{"-t", strconv.Itoa(int(lifetime.Seconds())), path}
+	}
+	return []string{path}
+}
+
+// tildePath shortens a path in the home directory to start with ~.
+func tildePath(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(home, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.Join("~", rel)
+	}
+	return path
+}
@@ -0,0 +1,122 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestAgentOp_relativeIdentityFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("ssh-agent listens on a named pipe on windows")
+	}
+	setupKnownHosts(t, `Host web
+    Hostname 10.0.0.1
+    User root
+    IdentityFile keys/id_web
+
+Host db
+    Hostname 10.0.0.2
+    User root
+    IdentityFile keys/id_db
+`)
+	// like ssh(1), the keys are looked up in the current directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.Chdir(wd)
+	}()
+	if err := os.Mkdir("keys", 0700); err != nil {
+		t.Fatal(err)
+	}
+	keyring := agent.NewKeyring()
+	for _, name := range []string{"id_web", "id_db"} {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sshPub, err := ssh.NewPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join("keys", name)
+		if err := os.WriteFile(path, []byte("private"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path+".pub", ssh.MarshalAuthorizedKey(sshPub), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if name == "id_web" {
+			if err := keyring.Add(agent.AddedKey{PrivateKey: priv}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_ = agent.ServeAgent(keyring, conn)
+			_ = conn.Close()
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", sock)
+
+	var stdout bytes.Buffer
+	if err := (AgentOp{}).Run(&stdout, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("output = %q", stdout.String())
+	}
+	if fields := strings.Fields(lines[1]); len(fields) != 3 || fields[0] != "web" || fields[1] != filepath.Join("keys", "id_web") || fields[2] != "loaded" {
+		t.Errorf("web line = %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "db ") || !strings.Contains(lines[2], "not loaded") {
+		t.Errorf("db line = %q", lines[2])
+	}
+
+	var stderr bytes.Buffer
+	ensureAgentKey(&stderr, []string{filepath.Join("keys", "id_db")}, 0)
+	if !strings.Contains(stderr.String(), "Key keys/id_db is not in ssh-agent.") {
+		t.Errorf("ensureAgentKey() printed %q", stderr.String())
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/backend"
+	"github.com/spencercjh/sshctx/internal/settings"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+// connection is how sshctx connects to a host.
+type connection struct {
+	Argv        []string // command line of the client
+	Record      bool     // record the session
+	RecordInput bool     // record what is typed too
+	PreConnect  []string // hooks to run before connecting
+	PostConnect []string // hooks to run after the connection ends
+	// IdentityFiles are the keys of the host to look for in ssh-agent, none to skip the check.
+	IdentityFiles []string
+	AgentLifetime time.Duration // lifetime of keys added to ssh-agent
+	Warnings      []string      // problems to tell about before connecting
+}
+
+// resolveConnection decides how to connect to h. The backend is taken from
+// the `#sshctx: backend=...` metadata of the host, then from the settings
+// file, and defaults to ssh. Hooks come from the settings file. Sessions are recorded when opts ask for it, or
+// when the `#sshctx: record=true` metadata or the settings do.
+func resolveConnection(h sshconfig.Host, opts connectOptions) (connection, error) {
+	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.ReadOnlyLoader)
+
+	defer func(sshConfig *sshconfig.SSHConfig) {
+		_ = sshConfig.Close()
+	}(sc)
+
+	if err := sc.Parse(); err != nil {
+		return connection{}, errors.Wrap(err, "sshconfig error")
+	}
+	s, err := settings.Load()
+	if err != nil {
+		return connection{}, err
+	}
+	meta := sc.Metadata(h)
+
+	name, ok := meta["backend"]
+	if !ok {
+		if name, err = s.BackendFor(h); err != nil {
+			return connection{}, err
+		}
+	}
+	conn := connection{Record: opts.Record || opts.RecordInput, RecordInput: opts.RecordInput || s.Record.Input}
+	if conn.Argv, err = backend.Command(name, h); err != nil {
+		return connection{}, err
+	}
+	if conn.PreConnect, conn.PostConnect, err = s.HooksFor(h); err != nil {
+		return connection{}, err
+	}
+	conn.Warnings = certificateWarnings(sc, h)
+	if !s.Agent.Skip {
+		conn.IdentityFiles = sc.IdentityFiles(h)
+		conn.AgentLifetime = s.Agent.Lifetime
+	}
+
+	if v, ok := meta["record"]; ok {
+		record, err := strconv.ParseBool(v)
+		if err != nil {
+			return connection{}, errors.Errorf("invalid record metadata of %s: %s", h.DisplayName, v)
+		}
+		conn.Record = conn.Record || record
+	} else if !conn.Record {
+		if conn.Record, err = s.AlwaysRecord(h); err != nil {
+			return connection{}, err
+		}
+	}
+	return conn, nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/creack/pty"
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/broadcast"
+	"github.com/spencercjh/sshctx/internal/printer"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+	"golang.org/x/term"
+)
+
+// BroadcastOp describes typing into all hosts matching a selector at once.
+type BroadcastOp struct {
+	Selector string
+}
+
+func (op BroadcastOp) Run(stdout, stderr io.Writer) error {
+	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.DefaultLoader)
+
+	defer func(sshConfig *sshconfig.SSHConfig) {
+		_ = sshConfig.Close()
+	}(sc)
+
+	if err := sc.Parse(); err != nil {
+		return errors.Wrap(err, "sshconfig error")
+	}
+	hosts, err := sshconfig.Select(sc.Hosts, op.Selector)
+	if err != nil {
+		return err
+	}
+
+	var size *pty.Winsize
+	stdinFd := int(os.Stdin.Fd())
+	if term.IsTerminal(stdinFd) {
+		if cols, rows, err := term.GetSize(stdinFd); err == nil {
+			size = &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)}
+		}
+	}
+
+	var sessions []*broadcast.Session
+	defer func() {
+		for _, s := range sessions {
+			_ = s.Close()
+		}
+	}()
+	for _, h := range hosts {
+		args := append([]string{"-t", "-t"}, h.ToSSHArgs()...)
+		s, err := broadcast.Start(h.DisplayName, exec.Command("ssh", args...), size)
+		if err != nil {
+			return err
+		}
+		sessions = append(sessions, s)
+	}
+
+	_ = printer.Success(stderr, "Broadcasting to %d hosts. Press Ctrl-] then 1-9 or n to type into one host, a for all hosts, q to quit.", len(sessions))
+	if term.IsTerminal(stdinFd) {
+		state, err := term.MakeRaw(stdinFd)
+		if err != nil {
+			return errors.Wrap(err, "failed to put terminal into raw mode")
+		}
+		defer func() {
+			_ = term.Restore(stdinFd, state)
+		}()
+	}
+	b := broadcast.New(stdout, sessions...)
+	if size != nil {
+		b.Resize(int(size.Rows), int(size.Cols))
+		stop := notifyResize(func() {
+			if cols, rows, err := term.GetSize(stdinFd); err == nil {
+				b.Resize(rows, cols)
+			}
+		})
+		defer stop()
+	}
+	return b.Run(os.Stdin)
+}
@@ -0,0 +1,95 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/printer"
+	"github.com/spencercjh/sshctx/internal/probe"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+// defaultCheckTimeout is how long `check` waits for each host.
+const defaultCheckTimeout = 5 * time.Second
+
+// CheckOp describes checking that hosts are reachable over SSH.
+type CheckOp struct {
+	Selector string // empty for all hosts
+	Timeout  time.Duration
+}
+
+func (op CheckOp) Run(stdout, _ io.Writer) error {
+	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.DefaultLoader)
+
+	defer func(sshConfig *sshconfig.SSHConfig) {
+		_ = sshConfig.Close()
+	}(sc)
+
+	if err := sc.Parse(); err != nil {
+		return errors.Wrap(err, "sshconfig error")
+	}
+	hosts := sc.Hosts
+	if op.Selector != "" {
+		var err error
+		if hosts, err = sshconfig.Select(sc.Hosts, op.Selector); err != nil {
+			return err
+		}
+	}
+	timeout := op.Timeout
+	if timeout == 0 {
+		timeout = defaultCheckTimeout
+	}
+	results := probeHosts(probe.New(timeout), hosts)
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "HOST\tADDRESS\tSTATUS\tVERSION\tLATENCY")
+	down := 0
+	for i, r := range results {
+		status := printer.SuccessColor.Sprint("up")
+		version := r.Version()
+		latency := r.Latency.Round(time.Millisecond).String()
+		if !r.Up {
+			down++
+			status = printer.ErrorColor.Sprint("down")
+			version = r.Err.Error()
+			if r.Latency == 0 {
+				latency = "-"
+			}
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", hosts[i].DisplayName, r.Addr, status, version, latency)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if down > 0 {
+		return errors.Errorf("%d of %d hosts are down", down, len(hosts))
+	}
+	return nil
+}
+
+// probeHosts probes all hosts concurrently, results are in the order of hosts.
+func probeHosts(p *probe.Prober, hosts []sshconfig.Host) []probe.Result {
+	addrs := make([]string, len(hosts))
+	for i, h := range hosts {
+		addrs[i] = h.Addr()
+	}
+	return p.ProbeAll(context.Background(), addrs)
+}
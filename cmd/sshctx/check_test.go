@@ -0,0 +1,31 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCheckArgs(t *testing.T) {
+	if op, ok := parseArgs([]string{"check", "--timeout", "2s", "web-*"}).(CheckOp); !ok || op.Timeout != 2*time.Second || op.Selector != "web-*" {
+		t.Errorf("parseArgs() = %#v", op)
+	}
+	for _, timeout := range []string{"0s", "-1s", "soon"} {
+		if _, ok := parseArgs([]string{"check", "--timeout", timeout}).(UnsupportedOp); !ok {
+			t.Errorf("parseArgs(check --timeout %s) succeeded", timeout)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/cmdutil"
+	"github.com/spencercjh/sshctx/internal/printer"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+// maxParallelCopies limits how many hosts `cp --to` copies to at the same time.
+const maxParallelCopies = 8
+
+// CpOp describes copying files from or to hosts with scp(1) or rsync(1).
+type CpOp struct {
+	Src string // local path or `DisplayName:path`
+	Dst string // local path or `DisplayName:path`, or the remote path when To is set
+	To  string // selector of the hosts to push Src to
+	// Tool forces "scp" or "rsync". If empty, rsync is used when it's installed.
+	Tool   string
+	DryRun bool // print the command lines instead of copying
+}
+
+// cpEndpoint is one side of a copy.
+type cpEndpoint struct {
+	Host *sshconfig.Host // nil for a local path
+	Path string
+}
+
+func (e cpEndpoint) String() string {
+	if e.Host == nil {
+		return e.Path
+	}
+	return e.Host.Destination() + ":" + e.Path
+}
+
+func (op CpOp) Run(stdout, stderr io.Writer) error {
+	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.ReadOnlyLoader)
+
+	defer func(sshConfig *sshconfig.SSHConfig) {
+		_ = sshConfig.Close()
+	}(sc)
+
+	if err := sc.Parse(); err != nil {
+		return errors.Wrap(err, "sshconfig error")
+	}
+	tool, err := cpTool(op.Tool)
+	if err != nil {
+		return err
+	}
+
+	if op.To != "" {
+		hosts, err := sshconfig.Select(sc.Hosts, op.To)
+		if err != nil {
+			return err
+		}
+		if op.DryRun {
+			for i := range hosts {
+				args, err := cpArgs(tool, cpEndpoint{Path: op.Src}, cpEndpoint{Host: &hosts[i], Path: op.Dst})
+				if err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintln(stdout, cmdutil.ShellQuote(args))
+			}
+			return nil
+		}
+		return pushToHosts(stdout, stderr, tool, op.Src, op.Dst, hosts)
+	}
+
+	src := resolveCpEndpoint(sc.Hosts, op.Src)
+	dst := resolveCpEndpoint(sc.Hosts, op.Dst)
+	args, err := cpArgs(tool, src, dst)
+	if err != nil {
+		return err
+	}
+	if op.DryRun {
+		_, err := fmt.Fprintln(stdout, cmdutil.ShellQuote(args))
+		return err
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "%s failed", tool)
+	}
+	_ = printer.Success(stderr, "Copied %s to %s.", src, dst)
+	return nil
+}
+
+// cpTool decides whether to copy with scp or rsync.
+func cpTool(forced string) (string, error) {
+	_, err := exec.LookPath("rsync")
+	switch forced {
+	case "scp":
+		return "scp", nil
+	case "rsync":
+		if err != nil {
+			return "", errors.New("rsync is not installed")
+		}
+		return "rsync", nil
+	}
+	if err == nil {
+		return "rsync", nil
+	}
+	return "scp", nil
+}
+
+// resolveCpEndpoint parses `DisplayName:path`. Anything else, including
+// `host:path` for hosts that are not in sshconfig, is left to scp/rsync.
+func resolveCpEndpoint(hosts []sshconfig.Host, arg string) cpEndpoint {
+	i := strings.Index(arg, ":")
+	if i <= 0 {
+		return cpEndpoint{Path: arg}
+	}
+	for _, h := range hosts {
+		if h.DisplayName == arg[:i] {
+			h := h
+			return cpEndpoint{Host: &h, Path: arg[i+1:]}
+		}
+	}
+	return cpEndpoint{Path: arg}
+}
+
+// cpArgs builds the command line copying src to dst with tool.
+func cpArgs(tool string, src, dst cpEndpoint) ([]string, error) {
+	// scp and rsync take a single port for both sides
+	port := 0
+	for _, e := range []cpEndpoint{src, dst} {
+		if e.Host == nil {
+			continue
+		}
+		if port != 0 && port != e.Host.EffectivePort() {
+			return nil, errors.New("can't copy between hosts listening on different ports")
+		}
+		port = e.Host.EffectivePort()
+	}
+	if port == 22 {
+		port = 0
+	}
+
+	var args []string
+	switch tool {
+	case "rsync":
+		args = []string{"rsync", "-az", "--progress"}
+		if port != 0 {
+			args = append(args, "-e", "ssh -p "+strconv.Itoa(port))
+		}
+	default:
+		args = []string{"scp"}
+		if port != 0 {
+			args = append(args, "-P", strconv.Itoa(port))
+		}
+		if fi, err := os.Stat(src.Path); src.Host == nil && err == nil && fi.IsDir() {
+			args = append(args, "-r")
+		}
+	}
+	return append(args, src.String(), dst.String()), nil
+}
+
+// pushToHosts copies the local src to dst on each host in parallel,
+// reporting every host as it starts and finishes.
+func pushToHosts(stdout, stderr io.Writer, tool, src, dst string, hosts []sshconfig.Host) error {
+	if _, err := os.Stat(src); err != nil {
+		return errors.Wrap(err, "can't read source")
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var failed []string
+	sem := make(chan struct{}, maxParallelCopies)
+	for _, h := range hosts {
+		h := h
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			mu.Lock()
+			_, _ = fmt.Fprintf(stdout, "→ %s: copying %s to %s\n", h.DisplayName, src, dst)
+			mu.Unlock()
+
+			start := time.Now()
+			args, err := cpArgs(tool, cpEndpoint{Path: src}, cpEndpoint{Host: &h, Path: dst})
+			if err == nil {
+				cmd := exec.Command(args[0], args[1:]...)
+				cmd.Stdout = &progressWriter{mu: &mu, w: stdout, prefix: h.DisplayName + ": "}
+				cmd.Stderr = &progressWriter{mu: &mu, w: stderr, prefix: h.DisplayName + ": "}
+				err = cmd.Run()
+			}
+			elapsed := time.Since(start).Round(time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed = append(failed, h.DisplayName)
+				_ = printer.Error(stderr, "%s: %v after %s", h.DisplayName, err, elapsed)
+				return
+			}
+			_ = printer.Success(stdout, "%s: done in %s", h.DisplayName, elapsed)
+		}()
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		return errors.Errorf("failed to copy to %d of %d hosts: %s", len(failed), len(hosts), strings.Join(failed, ", "))
+	}
+	_ = printer.Success(stdout, "Copied %s to %d hosts.", src, len(hosts))
+	return nil
+}
+
+// progressInterval is how often the progress of a copy to a host is shown.
+const progressInterval = time.Second
+
+// progressWriter writes the output of a copy to a host line by line, prefixed
+// with the host. Progress updates ending with a carriage return are shown at
+// most once per progressInterval so parallel copies don't flood the terminal.
+type progressWriter struct {
+	mu     *sync.Mutex // shared by the writers of all hosts
+	w      io.Writer
+	prefix string
+	line   []byte
+	last   time.Time
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	for _, c := range b {
+		switch c {
+		case '\n':
+			p.emit()
+		case '\r':
+			if time.Since(p.last) >= progressInterval {
+				p.emit()
+			}
+			p.line = p.line[:0]
+		default:
+			p.line = append(p.line, c)
+		}
+	}
+	return len(b), nil
+}
+
+// emit writes the current line, unless it's blank.
+func (p *progressWriter) emit() {
+	if line := strings.TrimSpace(string(p.line)); line != "" {
+		p.mu.Lock()
+		_, _ = fmt.Fprintf(p.w, "%s%s\n", p.prefix, line)
+		p.mu.Unlock()
+		p.last = time.Now()
+	}
+	p.line = p.line[:0]
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+func TestCpArgs(t *testing.T) {
+	hosts := []sshconfig.Host{
+		{DisplayName: "web", Host: "10.0.0.1", Username: "root", Port: 2222},
+		{DisplayName: "db", Host: "10.0.0.2", Username: "admin"},
+		{DisplayName: "cache", Host: "10.0.0.3", Username: "admin", Port: 22},
+	}
+	tests := []struct {
+		name     string
+		tool     string
+		src, dst string
+		want     []string
+		wantErr  bool
+	}{
+		{name: "scp-upload", tool: "scp", src: "a.txt", dst: "web:/tmp/",
+			want: []string{"scp", "-P", "2222", "a.txt", "root@10.0.0.1:/tmp/"}},
+		{name: "scp-download", tool: "scp", src: "db:~/a.txt", dst: ".",
+			want: []string{"scp", "admin@10.0.0.2:~/a.txt", "."}},
+		{name: "unknown-host-left-alone", tool: "scp", src: "other:/a", dst: ".",
+			want: []string{"scp", "other:/a", "."}},
+		{name: "rsync-upload", tool: "rsync", src: "a.txt", dst: "web:/tmp/",
+			want: []string{"rsync", "-az", "--progress", "-e", "ssh -p 2222", "a.txt", "root@10.0.0.1:/tmp/"}},
+		{name: "different-ports", tool: "scp", src: "web:/a", dst: "cache:/a", wantErr: true},
+		{name: "different-ports-default", tool: "scp", src: "web:/a", dst: "db:/a", wantErr: true},
+		{name: "same-default-port", tool: "scp", src: "db:/a", dst: "cache:/a",
+			want: []string{"scp", "admin@10.0.0.2:/a", "admin@10.0.0.3:/a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := resolveCpEndpoint(hosts, tt.src)
+			dst := resolveCpEndpoint(hosts, tt.dst)
+			got, err := cpArgs(tt.tool, src, dst)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("cpArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cpArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProgressWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := &progressWriter{mu: new(sync.Mutex), w: &out, prefix: "web: "}
+	_, _ = w.Write([]byte("     32,768   3%    0.00kB/s    0:00:00\r"))
+	_, _ = w.Write([]byte("    524,288  50%   12.00MB/s    0:00:01\r  1,048,576 100%   12.00MB/s    0:00:01 (xfr#1, to-chk=0/1)\n"))
+	_, _ = w.Write([]byte("\nsent 1,048,700 bytes\n"))
+	// the update at 50% comes too soon after the one at 3%
+	want := "web: 32,768   3%    0.00kB/s    0:00:00\n" +
+		"web: 1,048,576 100%   12.00MB/s    0:00:01 (xfr#1, to-chk=0/1)\n" +
+		"web: sent 1,048,700 bytes\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestCpOp_dryRunCreatesNoData(t *testing.T) {
+	setupKnownHosts(t, switchTestConfig)
+	t.Setenv("SSHCTX_SETTINGS", "")
+
+	for _, op := range []CpOp{
+		{Src: "a.txt", Dst: "web:/tmp/", Tool: "scp", DryRun: true},
+		{Src: "a.txt", Dst: "/tmp/", To: "web", Tool: "scp", DryRun: true},
+	} {
+		var stdout, stderr bytes.Buffer
+		if err := op.Run(&stdout, &stderr); err != nil {
+			t.Fatalf("Run(%+v) error = %v", op, err)
+		}
+		if got := strings.TrimSpace(stdout.String()); got != "scp a.txt root@10.0.0.1:/tmp/" {
+			t.Errorf("Run(%+v) printed %q", op, got)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(os.Getenv("HOME"), ".sshctx", "config.yaml")); !os.IsNotExist(err) {
+		t.Errorf("--dry-run created the sshctx data file: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"facette.io/natsort"
+	"github.com/spencercjh/sshctx/internal/discovery"
+	"github.com/spencercjh/sshctx/internal/env"
+	"github.com/spencercjh/sshctx/internal/knownhosts"
+	"github.com/spencercjh/sshctx/internal/settings"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+// discoveredIcon marks the hosts in the list that aren't in sshconfig.
+const discoveredIcon = "🔭"
+
+// isListItem returns whether target is a line of the list, `💻: DisplayName#user@host`
+// or `🔭: DisplayName#user@host`.
+func isListItem(target string) bool {
+	return strings.HasPrefix(target, "💻") || strings.HasPrefix(target, discoveredIcon)
+}
+
+// discoveredHosts returns the hosts found by the discovery sources enabled
+// in settings that aren't in sshconfig, named like `user@host:port`.
+func discoveredHosts(sc *sshconfig.SSHConfig) ([]sshconfig.Host, error) {
+	s, err := settings.Load()
+	if err != nil {
+		return nil, err
+	}
+	var found []discovery.Host
+	if s.Discovery.History {
+		found = append(found, discovery.History(discovery.HistoryFiles()...)...)
+	}
+	if s.Discovery.KnownHosts {
+		found = append(found, discovery.KnownHosts(sc.UserKnownHostsFiles(sshconfig.Host{})...)...)
+	}
+	return filterDiscovered(sc, found), nil
+}
+
+// filterDiscovered drops the hosts that are in sshconfig, duplicates, and
+// hosts of known_hosts that the history has with a user.
+func filterDiscovered(sc *sshconfig.SSHConfig, found []discovery.Host) []sshconfig.Host {
+	withUser := map[string]bool{}
+	for _, d := range found {
+		if d.User != "" {
+			withUser[knownhosts.Normalize(d.Host, d.Port)] = true
+		}
+	}
+	var hosts []sshconfig.Host
+	seen := map[string]bool{}
+	for _, d := range found {
+		if seen[d.Name()] || (d.User == "" && withUser[knownhosts.Normalize(d.Host, d.Port)]) {
+			continue
+		}
+		seen[d.Name()] = true
+		if _, ok := configuredHost(sc, d); ok {
+			continue
+		}
+		h := sshconfig.Host{DisplayName: d.Name(), Host: d.Host, Username: d.User, Port: d.Port}
+		if h.Username == "" {
+			h.Username = os.Getenv("USER")
+		}
+		if !env.SSHParameterRegexp.MatchString(h.ToSSHParameter()) {
+			continue
+		}
+		hosts = append(hosts, h)
+	}
+	sort.SliceStable(hosts, func(i, j int) bool {
+		return natsort.Compare(hosts[i].DisplayName, hosts[j].DisplayName)
+	})
+	return hosts
+}
+
+// configuredHost returns the host of sshconfig d refers to, by its name or
+// by its Hostname and port.
+func configuredHost(sc *sshconfig.SSHConfig, d discovery.Host) (sshconfig.Host, bool) {
+	for _, h := range sc.Hosts {
+		if h.DisplayName == d.Host && d.Port == 0 {
+			return h, true
+		}
+		if h.Host == d.Host && knownhosts.Normalize(h.Host, h.EffectivePort()) == knownhosts.Normalize(d.Host, d.Port) {
+			return h, true
+		}
+	}
+	return sshconfig.EmptyHost, false
+}
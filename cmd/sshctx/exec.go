@@ -0,0 +1,224 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/cmdutil"
+	"github.com/spencercjh/sshctx/internal/printer"
+	"github.com/spencercjh/sshctx/internal/settings"
+	"github.com/spencercjh/sshctx/internal/sshagent"
+	"github.com/spencercjh/sshctx/internal/sshclient"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+const (
+	// engineSSH runs commands with ssh(1).
+	engineSSH = "ssh"
+	// engineGo runs commands in-process with the built-in client.
+	engineGo = "go"
+	// maxParallelExecs limits on how many hosts `exec` runs at the same time.
+	maxParallelExecs = 8
+)
+
+// ExecOp describes running a command on hosts without a terminal.
+type ExecOp struct {
+	Selector string // hosts to run on, all hosts matching Filter if empty
+	Command  string
+	Engine   string // engineSSH or engineGo, the settings decide if empty
+	DryRun   bool   // print how the command would run instead of running it
+	filterOptions
+}
+
+// execFunc runs the command on a host.
+type execFunc func(h sshconfig.Host, stdin io.Reader, stdout, stderr io.Writer) error
+
+func (op ExecOp) Run(stdout, stderr io.Writer) error {
+	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.ReadOnlyLoader)
+
+	defer func(sshConfig *sshconfig.SSHConfig) {
+		_ = sshConfig.Close()
+	}(sc)
+
+	if err := sc.Parse(); err != nil {
+		return errors.Wrap(err, "sshconfig error")
+	}
+	hosts, err := op.hosts(sc)
+	if err != nil {
+		return err
+	}
+	engine := op.Engine
+	if engine == "" {
+		s, err := settings.Load()
+		if err != nil {
+			return err
+		}
+		engine = s.Engine
+	}
+
+	var run execFunc
+	switch engine {
+	case "", engineSSH:
+		if op.DryRun {
+			for _, h := range hosts {
+				_, _ = fmt.Fprintln(stdout, cmdutil.ShellQuote(sshExecArgs(h, op.Command)))
+			}
+			return nil
+		}
+		run = op.runWithSSH
+	case engineGo:
+		if op.DryRun {
+			for _, h := range hosts {
+				cfg, err := sshclient.Resolve(sc, h)
+				if err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintf(stdout, "%s: %s\n", describeConfig(cfg), cmdutil.ShellQuote([]string{op.Command}))
+			}
+			return nil
+		}
+		a, err := sshagent.Dial()
+		var keys agent.Agent
+		if err == nil {
+			defer a.Close()
+			keys = a.Agent()
+		} else if err != sshagent.ErrNoAgent {
+			_ = printer.Warning(stderr, "%v", err)
+		}
+		run = func(h sshconfig.Host, stdin io.Reader, stdout, stderr io.Writer) error {
+			cfg, err := sshclient.Resolve(sc, h)
+			if err != nil {
+				return err
+			}
+			c, err := sshclient.Dial(cfg, keys)
+			var hostKeyErr *sshclient.HostKeyError
+			if errors.As(err, &hostKeyErr) && hostKeyErr.Changed {
+				return errors.Errorf("%v\n%s", err, forgetHint(hostKeyErr.Host))
+			} else if err != nil {
+				return err
+			}
+			defer c.Close()
+			return c.Run(op.Command, stdin, stdout, stderr)
+		}
+	default:
+		return errors.Errorf("unknown engine '%s', use %s or %s", engine, engineSSH, engineGo)
+	}
+
+	if len(hosts) == 1 {
+		return run(hosts[0], os.Stdin, stdout, stderr)
+	}
+	return execOnHosts(stdout, stderr, hosts, run)
+}
+
+// hosts returns the hosts matching the selector and the filter.
+func (op ExecOp) hosts(sc *sshconfig.SSHConfig) ([]sshconfig.Host, error) {
+	hosts := sc.Hosts
+	if op.Selector != "" {
+		selected, err := sshconfig.Select(sc.Hosts, op.Selector)
+		if err != nil {
+			return nil, err
+		}
+		hosts = selected
+	}
+	hosts, err := op.apply(sc, hosts)
+	if err != nil {
+		return nil, err
+	}
+	if len(hosts) == 0 {
+		return nil, errors.Errorf("no host matches filter: %s", op.Filter)
+	}
+	return hosts, nil
+}
+
+func (op ExecOp) runWithSSH(h sshconfig.Host, stdin io.Reader, stdout, stderr io.Writer) error {
+	args := sshExecArgs(h, op.Command)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+// sshExecArgs builds the ssh(1) command line running command on the host.
+func sshExecArgs(h sshconfig.Host, command string) []string {
+	return append(append([]string{"ssh"}, h.ToSSHArgs()...), command)
+}
+
+// describeConfig describes how the built-in client connects, e.g.
+// `root@10.0.0.1:22 via jump@bastion:22`.
+func describeConfig(cfg *sshclient.Config) string {
+	s := cfg.User + "@" + cfg.Addr
+	if len(cfg.Jumps) > 0 {
+		var jumps []string
+		for _, j := range cfg.Jumps {
+			jumps = append(jumps, j.User+"@"+j.Addr)
+		}
+		s += " via " + strings.Join(jumps, ",")
+	}
+	return s
+}
+
+// execOnHosts runs the command on the hosts in parallel, printing the
+// output of each host when it's done.
+func execOnHosts(stdout, stderr io.Writer, hosts []sshconfig.Host, run execFunc) error {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var failed []string
+	sem := make(chan struct{}, maxParallelExecs)
+	for _, h := range hosts {
+		h := h
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			start := time.Now()
+			var out bytes.Buffer
+			err := run(h, nil, &out, &out)
+			elapsed := time.Since(start).Round(time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			output := out.String()
+			if output != "" && !strings.HasSuffix(output, "\n") {
+				output += "\n"
+			}
+			_, _ = fmt.Fprintf(stdout, "→ %s\n%s", h.DisplayName, output)
+			if err != nil {
+				failed = append(failed, h.DisplayName)
+				_ = printer.Error(stderr, "%s: %v after %s", h.DisplayName, err, elapsed)
+				return
+			}
+			_ = printer.Success(stdout, "%s: done in %s", h.DisplayName, elapsed)
+		}()
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		return errors.Errorf("failed on %d of %d hosts: %s", len(failed), len(hosts), strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spencercjh/sshctx/internal/sshclient"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+func TestSSHExecArgs(t *testing.T) {
+	h := sshconfig.Host{DisplayName: "web", Host: "10.0.0.1", Username: "root", Port: 2222}
+	want := []string{"ssh", "-p", "2222", "root@10.0.0.1", "uptime -p"}
+	if got := sshExecArgs(h, "uptime -p"); !reflect.DeepEqual(got, want) {
+		t.Errorf("sshExecArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestDescribeConfig(t *testing.T) {
+	cfg := &sshclient.Config{User: "root", Addr: "10.0.0.1:22", Jumps: []*sshclient.Config{
+		{User: "jump", Addr: "bastion:22"}, {User: "ops", Addr: "10.0.0.2:2222"},
+	}}
+	want := "root@10.0.0.1:22 via jump@bastion:22,ops@10.0.0.2:2222"
+	if got := describeConfig(cfg); got != want {
+		t.Errorf("describeConfig() = %q, want %q", got, want)
+	}
+}
+
+func TestExecOnHosts(t *testing.T) {
+	hosts := []sshconfig.Host{{DisplayName: "web"}, {DisplayName: "db"}, {DisplayName: "cache"}}
+	run := func(h sshconfig.Host, _ io.Reader, stdout, _ io.Writer) error {
+		_, _ = io.WriteString(stdout, "hello from "+h.DisplayName)
+		if h.DisplayName == "db" {
+			return errors.New("exit status 1")
+		}
+		return nil
+	}
+	var stdout, stderr bytes.Buffer
+	err := execOnHosts(&stdout, &stderr, hosts, run)
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 hosts: db") {
+		t.Errorf("execOnHosts() error = %v, want db to fail", err)
+	}
+	for _, h := range hosts {
+		if !strings.Contains(stdout.String(), "→ "+h.DisplayName+"\nhello from "+h.DisplayName+"\n") {
+			t.Errorf("output of %s missing:\n%s", h.DisplayName, stdout.String())
+		}
+	}
+	if !strings.Contains(stderr.String(), "db: exit status 1") {
+		t.Errorf("stderr = %q, want the error of db", stderr.String())
+	}
+}
+
+func TestExecOp_dryRunCreatesNoData(t *testing.T) {
+	setupKnownHosts(t, switchTestConfig)
+	t.Setenv("SSHCTX_SETTINGS", "")
+
+	var stdout, stderr bytes.Buffer
+	if err := (ExecOp{Selector: "web", Command: "uptime", Engine: engineSSH, DryRun: true}).Run(&stdout, &stderr); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := strings.TrimSpace(stdout.String()); got != "ssh root@10.0.0.1 uptime" {
+		t.Errorf("Run() printed %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(os.Getenv("HOME"), ".sshctx", "config.yaml")); !os.IsNotExist(err) {
+		t.Errorf("--dry-run created the sshctx data file: %v", err)
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of `export`.
+const (
+	exportAnsibleINI   = "ansible-ini"
+	exportAnsibleYAML  = "ansible-yaml"
+	exportCSV          = "csv"
+	exportJSON         = "json"
+	exportPrometheusSD = "prometheus-sd"
+	exportHosts        = "hosts"
+)
+
+// exportFormats are the formats of `export` in the order of the help.
+var exportFormats = []string{exportAnsibleINI, exportAnsibleYAML, exportCSV, exportJSON, exportPrometheusSD, exportHosts}
+
+// csvHeader are the columns of the csv export, which `import` reads back.
+var csvHeader = []string{"name", "hostname", "user", "port", "tags"}
+
+// ansibleGroupRegexp matches the characters not allowed in Ansible group names.
+var ansibleGroupRegexp = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// ExportOp describes writing the hosts of sshconfig in the format of another tool.
+type ExportOp struct {
+	Format string
+	filterOptions
+}
+
+func (op ExportOp) Run(stdout, _ io.Writer) error {
+	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.DefaultLoader)
+
+	defer func(sshConfig *sshconfig.SSHConfig) {
+		_ = sshConfig.Close()
+	}(sc)
+
+	if err := sc.Parse(); err != nil {
+		return errors.Wrap(err, "sshconfig error")
+	}
+	// patterns like `Host web-*` are settings for several hosts, not a host
+	var hosts []sshconfig.Host
+	for _, h := range sc.Hosts {
+		if !strings.ContainsAny(h.DisplayName+h.Host, "*?!") {
+			hosts = append(hosts, h)
+		}
+	}
+	hosts, err := op.apply(sc, hosts)
+	if err != nil {
+		return err
+	}
+	views, err := hostViews(sc, hosts, nil)
+	if err != nil {
+		return err
+	}
+
+	switch op.Format {
+	case exportAnsibleINI:
+		return exportToAnsibleINI(stdout, views)
+	case exportAnsibleYAML:
+		return exportToAnsibleYAML(stdout, views)
+	case exportCSV:
+		return exportToCSV(stdout, views)
+	case exportJSON:
+		return printHosts(stdout, views, outputJSON)
+	case exportPrometheusSD:
+		return exportToPrometheusSD(stdout, views)
+	case exportHosts:
+		return exportToHosts(stdout, views)
+	}
+	return errors.Errorf("unsupported export format '%s'", op.Format)
+}
+
+// ansibleVars returns the variables of a host in an Ansible inventory, in a stable order.
+func ansibleVars(v hostView) [][2]string {
+	vars := [][2]string{
+		{"ansible_host", v.Host},
+		{"ansible_user", v.User},
+		{"ansible_port", strconv.Itoa(v.Port)},
+	}
+	if key := v.Options["identityfile"]; len(key) > 0 {
+		vars = append(vars, [2]string{"ansible_ssh_private_key_file", key[0]})
+	}
+	if jump := v.Options["proxyjump"]; len(jump) > 0 && jump[0] != "none" {
+		vars = append(vars, [2]string{"ansible_ssh_common_args", "-o ProxyJump=" + jump[0]})
+	}
+	return vars
+}
+
+// ansibleGroups returns the hosts of each tag, and the tags in the order
+// they first appear.
+func ansibleGroups(views []hostView) ([]string, map[string][]string) {
+	var names []string
+	groups := map[string][]string{}
+	for _, v := range views {
+		for _, t := range v.Tags {
+			g := ansibleGroupRegexp.ReplaceAllString(t, "_")
+			if _, ok := groups[g]; !ok {
+				names = append(names, g)
+			}
+			groups[g] = append(groups[g], v.DisplayName)
+		}
+	}
+	return names, groups
+}
+
+func exportToAnsibleINI(w io.Writer, views []hostView) error {
+	var b strings.Builder
+	b.WriteString("[all]\n")
+	for _, v := range views {
+		// the INI format separates the name and the variables with whitespace
+		if strings.IndexFunc(v.DisplayName, unicode.IsSpace) >= 0 {
+			return errors.Errorf("host '%s' has whitespace in its name, export it with %s instead", v.DisplayName, exportAnsibleYAML)
+		}
+		b.WriteString(v.DisplayName)
+		for _, kv := range ansibleVars(v) {
+			value := kv[1]
+			if strings.ContainsAny(value, " \t'\"") {
+				value = "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+			}
+			b.WriteString(" " + kv[0] + "=" + value)
+		}
+		b.WriteString("\n")
+	}
+	names, groups := ansibleGroups(views)
+	for _, g := range names {
+		b.WriteString("\n[" + g + "]\n")
+		for _, h := range groups[g] {
+			b.WriteString(h + "\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return errors.Wrap(err, "write error")
+}
+
+func exportToAnsibleYAML(w io.Writer, views []hostView) error {
+	hosts := &yaml.Node{Kind: yaml.MappingNode}
+	for _, v := range views {
+		vars := &yaml.Node{Kind: yaml.MappingNode}
+		for _, kv := range ansibleVars(v) {
+			value := &yaml.Node{Kind: yaml.ScalarNode, Value: kv[1]}
+			if kv[0] == "ansible_port" {
+				value.Tag = "!!int"
+			}
+			vars.Content = append(vars.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: kv[0]}, value)
+		}
+		hosts.Content = append(hosts.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: v.DisplayName}, vars)
+	}
+	all := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: "hosts"}, hosts,
+	}}
+
+	names, groups := ansibleGroups(views)
+	if len(names) > 0 {
+		children := &yaml.Node{Kind: yaml.MappingNode}
+		for _, g := range names {
+			members := &yaml.Node{Kind: yaml.MappingNode}
+			for _, h := range groups[g] {
+				members.Content = append(members.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: h},
+					&yaml.Node{Kind: yaml.MappingNode, Style: yaml.FlowStyle})
+			}
+			children.Content = append(children.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: g},
+				&yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{{Kind: yaml.ScalarNode, Value: "hosts"}, members}})
+		}
+		all.Content = append(all.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "children"}, children)
+	}
+	doc := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{{Kind: yaml.ScalarNode, Value: "all"}, all}}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return errors.Wrap(err, "can't marshal inventory")
+	}
+	return errors.Wrap(enc.Close(), "write error")
+}
+
+func exportToCSV(w io.Writer, views []hostView) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write(csvHeader)
+	for _, v := range views {
+		_ = cw.Write([]string{v.DisplayName, v.Host, v.User, strconv.Itoa(v.Port), strings.Join(v.Tags, ",")})
+	}
+	cw.Flush()
+	return errors.Wrap(cw.Error(), "write error")
+}
+
+// prometheusTargetGroup is an entry of a Prometheus file_sd file.
+type prometheusTargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// exportToPrometheusSD writes a target group per host with its SSH address,
+// e.g. for the ssh_banner module of blackbox_exporter.
+func exportToPrometheusSD(w io.Writer, views []hostView) error {
+	groups := []prometheusTargetGroup{}
+	for _, v := range views {
+		labels := map[string]string{"host": v.DisplayName, "user": v.User}
+		if len(v.Tags) > 0 {
+			labels["tags"] = strings.Join(v.Tags, ",")
+		}
+		groups = append(groups, prometheusTargetGroup{
+			Targets: []string{net.JoinHostPort(v.Host, strconv.Itoa(v.Port))},
+			Labels:  labels,
+		})
+	}
+	data, err := json.MarshalIndent(groups, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "can't marshal targets")
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return errors.Wrap(err, "write error")
+}
+
+// exportToHosts writes a /etc/hosts line per IP address with the names of
+// its hosts. Hosts whose Hostname is a DNS name are left out.
+func exportToHosts(w io.Writer, views []hostView) error {
+	var addrs []string
+	names := map[string][]string{}
+	for _, v := range views {
+		if net.ParseIP(v.Host) == nil {
+			continue
+		}
+		if _, ok := names[v.Host]; !ok {
+			addrs = append(addrs, v.Host)
+		}
+		names[v.Host] = append(names[v.Host], v.DisplayName)
+	}
+	for _, addr := range addrs {
+		if _, err := fmt.Fprintf(w, "%s\t%s\n", addr, strings.Join(names[addr], " ")); err != nil {
+			return errors.Wrap(err, "write error")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const exportTestConfig = `Host web
+    Hostname 10.0.0.1
+    User deploy
+    IdentityFile ~/.ssh/web
+    #sshctx: tags=prod, front-end
+
+Host db
+    Hostname db.example.com
+    Port 2222
+    ProxyJump bastion
+    #sshctx: tags=prod
+
+Host web-alias
+    Hostname 10.0.0.1
+
+Host test-*
+    User tester
+`
+
+func runExport(t *testing.T, format string) string {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	if err := (ExportOp{Format: format}).Run(&stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	return stdout.String()
+}
+
+func TestExportOp(t *testing.T) {
+	setupKnownHosts(t, exportTestConfig)
+	t.Setenv("USER", "me")
+
+	wantINI := `[all]
+web ansible_host=10.0.0.1 ansible_user=deploy ansible_port=22 ansible_ssh_private_key_file=~/.ssh/web
+db ansible_host=db.example.com ansible_user=me ansible_port=2222 ansible_ssh_common_args='-o ProxyJump=bastion'
+web-alias ansible_host=10.0.0.1 ansible_user=me ansible_port=22
+
+[prod]
+web
+db
+
+[front_end]
+web
+`
+	if got := runExport(t, exportAnsibleINI); got != wantINI {
+		t.Errorf("ansible-ini =\n%s\nwant\n%s", got, wantINI)
+	}
+
+	var inventory struct {
+		All struct {
+			Hosts    map[string]map[string]interface{}
+			Children map[string]struct{ Hosts map[string]interface{} }
+		}
+	}
+	if err := yaml.Unmarshal([]byte(runExport(t, exportAnsibleYAML)), &inventory); err != nil {
+		t.Fatal(err)
+	}
+	if len(inventory.All.Hosts) != 3 || inventory.All.Hosts["db"]["ansible_port"] != 2222 ||
+		len(inventory.All.Children["prod"].Hosts) != 2 {
+		t.Errorf("ansible-yaml = %+v", inventory)
+	}
+
+	wantCSV := "name,hostname,user,port,tags\nweb,10.0.0.1,deploy,22,\"prod,front-end\"\ndb,db.example.com,me,2222,prod\nweb-alias,10.0.0.1,me,22,\n"
+	if got := runExport(t, exportCSV); got != wantCSV {
+		t.Errorf("csv = %q, want %q", got, wantCSV)
+	}
+
+	var groups []prometheusTargetGroup
+	if err := json.Unmarshal([]byte(runExport(t, exportPrometheusSD)), &groups); err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 3 || groups[1].Targets[0] != "db.example.com:2222" || groups[0].Labels["tags"] != "prod,front-end" {
+		t.Errorf("prometheus-sd = %+v", groups)
+	}
+
+	if got, want := runExport(t, exportHosts), "10.0.0.1\tweb web-alias\n"; got != want {
+		t.Errorf("hosts = %q, want %q", got, want)
+	}
+}
+
+func TestExportToAnsibleINI_whitespaceInName(t *testing.T) {
+	views := []hostView{{DisplayName: "web", Host: "10.0.0.1", Port: 22}, {DisplayName: "build box", Host: "10.0.0.2", Port: 22}}
+	var out bytes.Buffer
+	err := exportToAnsibleINI(&out, views)
+	if err == nil || !strings.Contains(err.Error(), "build box") {
+		t.Errorf("exportToAnsibleINI() error = %v, want an error about 'build box'", err)
+	}
+	if out.Len() > 0 {
+		t.Errorf("exportToAnsibleINI() wrote %q", out.String())
+	}
+}
+
+func TestParseExportArgs(t *testing.T) {
+	if op, ok := parseArgs([]string{"export", "--format", "csv", "--filter", "tag=prod"}).(ExportOp); !ok || op.Format != "csv" || op.Filter != "tag=prod" {
+		t.Errorf("parseArgs() = %#v", op)
+	}
+	for _, argv := range [][]string{{"export"}, {"export", "--format", "xml"}, {"export", "--format"}} {
+		if _, ok := parseArgs(argv).(UnsupportedOp); !ok {
+			t.Errorf("parseArgs(%q) succeeded", argv)
+		}
+	}
+}
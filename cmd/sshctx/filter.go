@@ -0,0 +1,90 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strconv"
+
+	"github.com/spencercjh/sshctx/internal/filter"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+// filterOptions restrict the hosts an operation works on.
+type filterOptions struct {
+	Filter string // expression, see filter.Parse
+	Search string // text any field of the host contains
+}
+
+func (o filterOptions) filtered() bool {
+	return o.Filter != "" || o.Search != ""
+}
+
+// apply returns the hosts matching both the filter and the search.
+func (o filterOptions) apply(sc *sshconfig.SSHConfig, hosts []sshconfig.Host) ([]sshconfig.Host, error) {
+	if !o.filtered() {
+		return hosts, nil
+	}
+	var exprs []filter.Expr
+	if o.Filter != "" {
+		e, err := filter.Parse(o.Filter)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+	}
+	if o.Search != "" {
+		exprs = append(exprs, filter.Search(o.Search))
+	}
+	var matched []sshconfig.Host
+hosts:
+	for _, h := range hosts {
+		fields := filterFields(sc, h)
+		for _, e := range exprs {
+			if !e.Match(fields) {
+				continue hosts
+			}
+		}
+		matched = append(matched, h)
+	}
+	return matched, nil
+}
+
+// args returns the options as command line arguments of the host list.
+func (o filterOptions) args() []string {
+	var args []string
+	if o.Search != "" {
+		args = append(args, "search", o.Search)
+	}
+	if o.Filter != "" {
+		args = append(args, "--filter", o.Filter)
+	}
+	return args
+}
+
+// filterFields returns the values filters look at: the options of the host,
+// its name, hostname, user, port and tags.
+func filterFields(sc *sshconfig.SSHConfig, h sshconfig.Host) filter.Fields {
+	fields := filter.Fields{}
+	for k, v := range sc.Options(h) {
+		fields[k] = v
+	}
+	fields["name"] = []string{h.DisplayName}
+	fields["host"] = []string{h.DisplayName}
+	fields["hostname"] = []string{h.Host}
+	fields["user"] = []string{h.Username}
+	fields["port"] = []string{strconv.Itoa(h.EffectivePort())}
+	fields["tag"] = sc.Tags(h)
+	return fields
+}
@@ -0,0 +1,78 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestListOp_filter(t *testing.T) {
+	setupKnownHosts(t, listTestConfig)
+	t.Setenv("USER", "me")
+
+	tests := []struct {
+		op   ListOp
+		want string
+	}{
+		{op: ListOp{filterOptions: filterOptions{Filter: "tag=prod"}}, want: "web\n"},
+		{op: ListOp{filterOptions: filterOptions{Filter: "has:IdentityFile or user=deploy"}}, want: "web\ndb\n"},
+		{op: ListOp{filterOptions: filterOptions{Filter: "port!=22"}}, want: "db\n"},
+		{op: ListOp{filterOptions: filterOptions{Search: "10.0.0.2"}}, want: "db\n"},
+		{op: ListOp{filterOptions: filterOptions{Search: ".ssh/DB"}}, want: "db\n"},
+		{op: ListOp{filterOptions: filterOptions{Search: "10.0.0", Filter: "user=deploy"}}, want: "web\n"},
+		{op: ListOp{filterOptions: filterOptions{Filter: "user=nobody"}}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.op.Filter+tt.op.Search, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			tt.op.Output = outputName
+			if err := tt.op.Run(&stdout, &stderr); err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("output = %q, want %q", stdout.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestExecOp_filter(t *testing.T) {
+	setupKnownHosts(t, listTestConfig)
+	t.Setenv("SSHCTX_SETTINGS", "")
+
+	var stdout, stderr bytes.Buffer
+	op, ok := parseArgs([]string{"exec", "--dry-run", "--filter", "tag=prod", "--", "uptime"}).(ExecOp)
+	if !ok || op.Selector != "" || op.Command != "uptime" {
+		t.Fatalf("parseArgs() = %#v", op)
+	}
+	if err := op.Run(&stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if want := "ssh deploy@10.0.0.1 uptime\n"; stdout.String() != want {
+		t.Errorf("output = %q, want %q", stdout.String(), want)
+	}
+
+	if op, ok := parseArgs([]string{"exec", "--filter", "tag=prod", "w*", "uptime"}).(ExecOp); !ok || op.Selector != "w*" {
+		t.Errorf("parseArgs() with selector = %#v", op)
+	}
+	op = ExecOp{Selector: "db", Command: "uptime", DryRun: true, filterOptions: filterOptions{Filter: "tag=prod"}}
+	if err := op.Run(&stdout, &stderr); err == nil {
+		t.Error("exec on a selector without hosts matching the filter succeeded")
+	}
+	if _, ok := parseArgs([]string{"--filter", "user=root and"}).(UnsupportedOp); !ok {
+		t.Error("parseArgs() accepted an invalid filter")
+	}
+}
@@ -17,9 +17,13 @@ package main
 import (
 	"fmt"
 	"github.com/spencercjh/sshctx/internal/cmdutil"
+	"github.com/spencercjh/sshctx/internal/filter"
+	"github.com/spencercjh/sshctx/internal/tmux"
 	"io"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // UnsupportedOp indicates an unsupported flag.
@@ -42,6 +46,53 @@ func parseArgs(argv []string) Op {
 		return ListOp{}
 	}
 
+	switch argv[0] {
+	case "broadcast":
+		if len(argv) != 2 {
+			return UnsupportedOp{Err: fmt.Errorf("'broadcast' requires exactly one host selector")}
+		}
+		return BroadcastOp{Selector: argv[1]}
+	case "cp":
+		return parseCpArgs(argv[1:])
+	case "exec":
+		return parseExecArgs(argv[1:])
+	case "known-hosts":
+		return parseKnownHostsArgs(argv[1:])
+	case "adopt":
+		return parseAdoptArgs(argv[1:])
+	case "export":
+		return parseExportArgs(argv[1:])
+	case "import":
+		return parseImportArgs(argv[1:])
+	case "-o", "--output", "--with-status", "--format", "--format-file", "--filter":
+		return parseListArgs(argv, "")
+	case "search":
+		if len(argv) < 2 || argv[1] == "" {
+			return UnsupportedOp{Err: fmt.Errorf("'search' requires a text")}
+		}
+		return parseListArgs(argv[2:], argv[1])
+	case "-p", "--previous":
+		return parsePreviousArgs(argv[1:])
+	case "tunnel":
+		return parseTunnelArgs(argv[1:])
+	case "mux":
+		return parseMuxArgs(argv[1:])
+	case "check":
+		return parseCheckArgs(argv[1:])
+	case "recordings":
+		return parseRecordingsArgs(argv[1:])
+	case "keys":
+		if len(argv) != 1 {
+			return UnsupportedOp{Err: fmt.Errorf("too many arguments")}
+		}
+		return KeysOp{}
+	case "agent":
+		if len(argv) != 2 || argv[1] != "status" {
+			return UnsupportedOp{Err: fmt.Errorf("'agent' requires status")}
+		}
+		return AgentOp{}
+	}
+
 	if len(argv) == 1 {
 		v := argv[0]
 		if v == "--help" || v == "-h" {
@@ -50,14 +101,513 @@ func parseArgs(argv []string) Op {
 		if v == "--version" || v == "-V" || v == "-v" {
 			return VersionOp{}
 		}
-		if v == "--previous" || v == "-p" {
-			return PreviousOp{}
-		}
 
 		if strings.HasPrefix(v, "-") && v != "-" {
 			return UnsupportedOp{Err: fmt.Errorf("unsupported option '%s'", v)}
 		}
 		return SwitchOp{Target: argv[0]}
 	}
-	return UnsupportedOp{Err: fmt.Errorf("too many arguments")}
+	return parseSwitchArgs(argv)
+}
+
+// parseListArgs parses the options of listing the hosts, e.g. `--with-status -o json`.
+// Filtering the hosts without choosing an output opens the interactive
+// picker with the matching hosts when possible.
+func parseListArgs(argv []string, search string) Op {
+	op := ListOp{filterOptions: filterOptions{Search: search}}
+	for i := 0; i < len(argv); i++ {
+		switch v := argv[i]; v {
+		case "--with-status":
+			op.WithStatus = true
+		case "-o", "--output":
+			if i+1 >= len(argv) {
+				return UnsupportedOp{Err: fmt.Errorf("'%s' requires one of json, yaml, name or wide", v)}
+			}
+			i++
+			switch argv[i] {
+			case outputJSON, outputYAML, outputName, outputWide:
+				op.Output = argv[i]
+			default:
+				return UnsupportedOp{Err: fmt.Errorf("unsupported output format '%s'", argv[i])}
+			}
+		case "--format", "--format-file":
+			n, err := parseFormatArg(argv[i:], &op.formatOptions)
+			if err != nil {
+				return UnsupportedOp{Err: err}
+			}
+			i += n
+		case "--filter":
+			n, err := parseFilterArg(argv[i:], &op.filterOptions)
+			if err != nil {
+				return UnsupportedOp{Err: err}
+			}
+			i += n
+		default:
+			return UnsupportedOp{Err: fmt.Errorf("unsupported option '%s'", v)}
+		}
+	}
+	if op.Output != "" && op.formatted() {
+		return UnsupportedOp{Err: fmt.Errorf("'--output' can't be combined with '--format'")}
+	}
+	if op.filtered() && op.Output == "" && !op.formatted() && !op.WithStatus {
+		if cmdutil.UseFzf(os.Stdout) {
+			return FzfOp{SelfCmd: os.Args[0], filterOptions: op.filterOptions}
+		}
+		if cmdutil.UsePromptui(os.Stdout) {
+			return PromptuiOp{filterOptions: op.filterOptions}
+		}
+	}
+	return op
+}
+
+// parseFilterArg parses `--filter <EXPR>` at the start of argv and returns
+// how many more arguments it used.
+func parseFilterArg(argv []string, o *filterOptions) (int, error) {
+	if len(argv) < 2 {
+		return 0, fmt.Errorf("'--filter' requires an expression")
+	}
+	if o.Filter != "" {
+		return 0, fmt.Errorf("'--filter' can only be given once, combine expressions with and")
+	}
+	if _, err := filter.Parse(argv[1]); err != nil {
+		return 0, err
+	}
+	o.Filter = argv[1]
+	return 1, nil
+}
+
+// parsePreviousArgs parses the options of `--previous`.
+func parsePreviousArgs(argv []string) Op {
+	var op PreviousOp
+	for i := 0; i < len(argv); i++ {
+		switch v := argv[i]; v {
+		case "--format", "--format-file":
+			n, err := parseFormatArg(argv[i:], &op.formatOptions)
+			if err != nil {
+				return UnsupportedOp{Err: err}
+			}
+			i += n
+		default:
+			return UnsupportedOp{Err: fmt.Errorf("unsupported option '%s'", v)}
+		}
+	}
+	return op
+}
+
+// parseFormatArg parses `--format <TEMPLATE>` or `--format-file <FILE>` at
+// the start of argv and returns how many more arguments it used.
+func parseFormatArg(argv []string, o *formatOptions) (int, error) {
+	if len(argv) < 2 || argv[1] == "" {
+		return 0, fmt.Errorf("'%s' requires a template", argv[0])
+	}
+	if o.formatted() {
+		return 0, fmt.Errorf("only one of '--format' and '--format-file' can be given")
+	}
+	if argv[0] == "--format" {
+		o.Format = argv[1]
+	} else {
+		o.FormatFile = argv[1]
+	}
+	return 1, nil
+}
+
+// parseSwitchArgs parses the options of connecting to a host, e.g. `--wait <HOST>`.
+func parseSwitchArgs(argv []string) Op {
+	var op SwitchOp
+	timeoutGiven, attemptsGiven := false, false
+	for i := 0; i < len(argv); i++ {
+		switch v := argv[i]; {
+		case v == "--wait":
+			op.Wait = true
+		case v == "--reconnect":
+			op.Reconnect = true
+		case v == "--dry-run" || v == "--print":
+			op.DryRun = true
+		case v == "--record":
+			op.Record = true
+		case v == "--record-input":
+			op.RecordInput = true
+		case v == "--tmux":
+			if i+1 >= len(argv) {
+				return UnsupportedOp{Err: fmt.Errorf("'--tmux' requires one of window, split or tiled")}
+			}
+			i++
+			switch argv[i] {
+			case tmux.Window, tmux.Split, tmux.Tiled:
+				op.Tmux = argv[i]
+			default:
+				return UnsupportedOp{Err: fmt.Errorf("unsupported tmux layout '%s'", argv[i])}
+			}
+		case v == "--sync":
+			op.Sync = true
+		case v == "--attempts":
+			if i+1 >= len(argv) {
+				return UnsupportedOp{Err: fmt.Errorf("'--attempts' requires a number")}
+			}
+			i++
+			n, err := strconv.Atoi(argv[i])
+			if err != nil || n <= 0 {
+				return UnsupportedOp{Err: fmt.Errorf("invalid attempts '%s'", argv[i])}
+			}
+			op.ReconnectAttempts = n
+			attemptsGiven = true
+		case v == "--timeout":
+			if i+1 >= len(argv) {
+				return UnsupportedOp{Err: fmt.Errorf("'--timeout' requires a duration")}
+			}
+			i++
+			d, err := time.ParseDuration(argv[i])
+			if err != nil || d <= 0 {
+				return UnsupportedOp{Err: fmt.Errorf("invalid timeout '%s'", argv[i])}
+			}
+			op.WaitTimeout = d
+			timeoutGiven = true
+		case strings.HasPrefix(v, "-") && v != "-":
+			return UnsupportedOp{Err: fmt.Errorf("unsupported option '%s'", v)}
+		case op.Target != "":
+			return UnsupportedOp{Err: fmt.Errorf("too many arguments")}
+		default:
+			op.Target = v
+		}
+	}
+	if op.Target == "" {
+		return UnsupportedOp{Err: fmt.Errorf("no host given")}
+	}
+	if timeoutGiven && !op.Wait {
+		return UnsupportedOp{Err: fmt.Errorf("'--timeout' requires '--wait'")}
+	}
+	if attemptsGiven && !op.Reconnect {
+		return UnsupportedOp{Err: fmt.Errorf("'--attempts' requires '--reconnect'")}
+	}
+	if op.Sync && op.Tmux != tmux.Tiled {
+		return UnsupportedOp{Err: fmt.Errorf("'--sync' requires '--tmux tiled'")}
+	}
+	return op
+}
+
+// parseCpArgs parses the arguments of `cp`.
+func parseCpArgs(argv []string) Op {
+	var op CpOp
+	var paths []string
+	for i := 0; i < len(argv); i++ {
+		switch v := argv[i]; v {
+		case "--rsync":
+			op.Tool = "rsync"
+		case "--scp":
+			op.Tool = "scp"
+		case "--dry-run", "--print":
+			op.DryRun = true
+		case "--to":
+			if i+1 >= len(argv) {
+				return UnsupportedOp{Err: fmt.Errorf("'--to' requires a host selector")}
+			}
+			i++
+			op.To = argv[i]
+		default:
+			if strings.HasPrefix(v, "-") {
+				return UnsupportedOp{Err: fmt.Errorf("unsupported option '%s'", v)}
+			}
+			paths = append(paths, v)
+		}
+	}
+	if len(paths) != 2 {
+		return UnsupportedOp{Err: fmt.Errorf("'cp' requires a source and a destination")}
+	}
+	op.Src, op.Dst = paths[0], paths[1]
+	return op
+}
+
+// parseExecArgs parses the arguments of `exec`: options, the host selector
+// and the command, which may follow `--` and is joined with spaces like ssh(1) does.
+func parseExecArgs(argv []string) Op {
+	var op ExecOp
+	for i := 0; i < len(argv); i++ {
+		switch v := argv[i]; {
+		case v == "--dry-run" || v == "--print":
+			op.DryRun = true
+		case v == "--engine":
+			if i+1 >= len(argv) {
+				return UnsupportedOp{Err: fmt.Errorf("'--engine' requires %s or %s", engineSSH, engineGo)}
+			}
+			i++
+			if argv[i] != engineSSH && argv[i] != engineGo {
+				return UnsupportedOp{Err: fmt.Errorf("unsupported engine '%s'", argv[i])}
+			}
+			op.Engine = argv[i]
+		case v == "--filter":
+			n, err := parseFilterArg(argv[i:], &op.filterOptions)
+			if err != nil {
+				return UnsupportedOp{Err: err}
+			}
+			i += n
+		case strings.HasPrefix(v, "-") && v != "--":
+			return UnsupportedOp{Err: fmt.Errorf("unsupported option '%s'", v)}
+		default:
+			// with --filter, the selector is optional and needs a -- before the command
+			command := argv[i:]
+			if op.Filter == "" || command[0] != "--" {
+				op.Selector, command = v, argv[i+1:]
+			}
+			if op.Selector == "--" {
+				return UnsupportedOp{Err: fmt.Errorf("'exec' requires a host selector")}
+			}
+			if len(command) > 0 && command[0] == "--" {
+				command = command[1:]
+			}
+			if len(command) == 0 {
+				return UnsupportedOp{Err: fmt.Errorf("'exec' requires a command")}
+			}
+			op.Command = strings.Join(command, " ")
+			return op
+		}
+	}
+	return UnsupportedOp{Err: fmt.Errorf("'exec' requires a host selector or --filter and a command")}
+}
+
+// parseKnownHostsArgs parses the arguments of `known-hosts`.
+func parseKnownHostsArgs(argv []string) Op {
+	if len(argv) == 0 {
+		return UnsupportedOp{Err: fmt.Errorf("'known-hosts' requires one of status, prune or forget")}
+	}
+	switch argv[0] {
+	case "status":
+		if len(argv) != 1 {
+			return UnsupportedOp{Err: fmt.Errorf("too many arguments")}
+		}
+		return KnownHostsOp{Action: "status"}
+	case "prune":
+		op := KnownHostsOp{Action: "prune"}
+		for _, v := range argv[1:] {
+			switch v {
+			case "--dry-run", "--print":
+				op.DryRun = true
+			case "--yes", "-y":
+				op.Yes = true
+			case "--hashed":
+				op.Hashed = true
+			default:
+				return UnsupportedOp{Err: fmt.Errorf("unsupported option '%s'", v)}
+			}
+		}
+		return op
+	case "forget":
+		if len(argv) != 2 {
+			return UnsupportedOp{Err: fmt.Errorf("'known-hosts forget' requires a host")}
+		}
+		return KnownHostsOp{Action: "forget", Target: argv[1]}
+	}
+	return UnsupportedOp{Err: fmt.Errorf("unsupported known-hosts action '%s'", argv[0])}
+}
+
+// parseExportArgs parses the arguments of `export`.
+func parseExportArgs(argv []string) Op {
+	var op ExportOp
+	for i := 0; i < len(argv); i++ {
+		switch v := argv[i]; v {
+		case "--format":
+			if i+1 >= len(argv) {
+				return UnsupportedOp{Err: fmt.Errorf("'--format' requires one of %s", strings.Join(exportFormats, ", "))}
+			}
+			i++
+			op.Format = argv[i]
+		case "--filter":
+			n, err := parseFilterArg(argv[i:], &op.filterOptions)
+			if err != nil {
+				return UnsupportedOp{Err: err}
+			}
+			i += n
+		default:
+			return UnsupportedOp{Err: fmt.Errorf("unsupported option '%s'", v)}
+		}
+	}
+	for _, f := range exportFormats {
+		if op.Format == f {
+			return op
+		}
+	}
+	if op.Format == "" {
+		return UnsupportedOp{Err: fmt.Errorf("'export' requires --format")}
+	}
+	return UnsupportedOp{Err: fmt.Errorf("unsupported export format '%s'", op.Format)}
+}
+
+// parseImportArgs parses the arguments of `import`.
+func parseImportArgs(argv []string) Op {
+	var op ImportOp
+	for i := 0; i < len(argv); i++ {
+		switch v := argv[i]; {
+		case v == "--format":
+			if i+1 >= len(argv) {
+				return UnsupportedOp{Err: fmt.Errorf("'--format' requires one of %s", strings.Join(importFormats, ", "))}
+			}
+			i++
+			op.Format = argv[i]
+		case v == "--to" || v == "--prefix" || v == "--tag":
+			if i+1 >= len(argv) || argv[i+1] == "" {
+				return UnsupportedOp{Err: fmt.Errorf("'%s' requires a value", v)}
+			}
+			i++
+			switch v {
+			case "--to":
+				op.To = argv[i]
+			case "--prefix":
+				op.Prefix = argv[i]
+			default:
+				for _, t := range strings.Split(argv[i], ",") {
+					if t = strings.TrimSpace(t); t != "" {
+						op.Tags = append(op.Tags, t)
+					}
+				}
+			}
+		case v == "--dry-run" || v == "--print":
+			op.DryRun = true
+		case v == "--yes" || v == "-y":
+			op.Yes = true
+		case strings.HasPrefix(v, "-"):
+			return UnsupportedOp{Err: fmt.Errorf("unsupported option '%s'", v)}
+		case op.File != "":
+			return UnsupportedOp{Err: fmt.Errorf("too many arguments")}
+		default:
+			op.File = v
+		}
+	}
+	if op.File == "" {
+		return UnsupportedOp{Err: fmt.Errorf("'import' requires a file")}
+	}
+	if op.Format == "" {
+		return UnsupportedOp{Err: fmt.Errorf("'import' requires --format")}
+	}
+	if _, ok := importParsers[op.Format]; !ok {
+		return UnsupportedOp{Err: fmt.Errorf("unsupported import format '%s'", op.Format)}
+	}
+	return op
+}
+
+// parseAdoptArgs parses the arguments of `adopt`.
+func parseAdoptArgs(argv []string) Op {
+	var op AdoptOp
+	for i := 0; i < len(argv); i++ {
+		switch v := argv[i]; {
+		case v == "--dry-run" || v == "--print":
+			op.DryRun = true
+		case v == "--name":
+			if i+1 >= len(argv) {
+				return UnsupportedOp{Err: fmt.Errorf("'--name' requires a host name")}
+			}
+			i++
+			op.Name = argv[i]
+		case strings.HasPrefix(v, "-"):
+			return UnsupportedOp{Err: fmt.Errorf("unsupported option '%s'", v)}
+		case op.Target != "":
+			return UnsupportedOp{Err: fmt.Errorf("too many arguments")}
+		default:
+			op.Target = v
+		}
+	}
+	if op.Target == "" {
+		return UnsupportedOp{Err: fmt.Errorf("'adopt' requires a host")}
+	}
+	return op
+}
+
+// parseTunnelArgs parses the arguments of `tunnel`.
+func parseTunnelArgs(argv []string) Op {
+	if len(argv) == 0 {
+		return UnsupportedOp{Err: fmt.Errorf("'tunnel' requires one of up, down or status")}
+	}
+	switch argv[0] {
+	case "up", "down":
+		if len(argv) != 2 {
+			return UnsupportedOp{Err: fmt.Errorf("'tunnel %s' requires a tunnel name", argv[0])}
+		}
+		return TunnelOp{Action: argv[0], Name: argv[1]}
+	case "status":
+		if len(argv) > 2 {
+			return UnsupportedOp{Err: fmt.Errorf("too many arguments")}
+		}
+		op := TunnelOp{Action: "status"}
+		if len(argv) == 2 {
+			op.Name = argv[1]
+		}
+		return op
+	}
+	return UnsupportedOp{Err: fmt.Errorf("unsupported tunnel action '%s'", argv[0])}
+}
+
+// parseMuxArgs parses the arguments of `mux`.
+func parseMuxArgs(argv []string) Op {
+	if len(argv) == 0 {
+		return UnsupportedOp{Err: fmt.Errorf("'mux' requires one of ls, stop or warm")}
+	}
+	switch argv[0] {
+	case "ls":
+		if len(argv) != 1 {
+			return UnsupportedOp{Err: fmt.Errorf("too many arguments")}
+		}
+		return MuxOp{Action: "ls"}
+	case "stop":
+		if len(argv) != 2 {
+			return UnsupportedOp{Err: fmt.Errorf("'mux stop' requires a host or --all")}
+		}
+		if argv[1] == "--all" {
+			return MuxOp{Action: "stop", All: true}
+		}
+		return MuxOp{Action: "stop", Target: argv[1]}
+	case "warm":
+		if len(argv) != 2 {
+			return UnsupportedOp{Err: fmt.Errorf("'mux warm' requires exactly one host selector")}
+		}
+		return MuxOp{Action: "warm", Target: argv[1]}
+	}
+	return UnsupportedOp{Err: fmt.Errorf("unsupported mux action '%s'", argv[0])}
+}
+
+// parseRecordingsArgs parses the arguments of `recordings`.
+func parseRecordingsArgs(argv []string) Op {
+	if len(argv) == 0 {
+		return UnsupportedOp{Err: fmt.Errorf("'recordings' requires one of ls or play")}
+	}
+	switch argv[0] {
+	case "ls":
+		if len(argv) > 2 {
+			return UnsupportedOp{Err: fmt.Errorf("too many arguments")}
+		}
+		op := RecordingsOp{Action: "ls"}
+		if len(argv) == 2 {
+			op.Target = argv[1]
+		}
+		return op
+	case "play":
+		if len(argv) != 2 {
+			return UnsupportedOp{Err: fmt.Errorf("'recordings play' requires a recording file or a host")}
+		}
+		return RecordingsOp{Action: "play", Target: argv[1]}
+	}
+	return UnsupportedOp{Err: fmt.Errorf("unsupported recordings action '%s'", argv[0])}
+}
+
+// parseCheckArgs parses the arguments of `check`.
+func parseCheckArgs(argv []string) Op {
+	var op CheckOp
+	for i := 0; i < len(argv); i++ {
+		switch v := argv[i]; {
+		case v == "--timeout":
+			if i+1 >= len(argv) {
+				return UnsupportedOp{Err: fmt.Errorf("'--timeout' requires a duration")}
+			}
+			i++
+			d, err := time.ParseDuration(argv[i])
+			if err != nil || d <= 0 {
+				return UnsupportedOp{Err: fmt.Errorf("invalid timeout '%s'", argv[i])}
+			}
+			op.Timeout = d
+		case strings.HasPrefix(v, "-"):
+			return UnsupportedOp{Err: fmt.Errorf("unsupported option '%s'", v)}
+		case op.Selector != "":
+			return UnsupportedOp{Err: fmt.Errorf("too many arguments")}
+		default:
+			op.Selector = v
+		}
+	}
+	return op
 }
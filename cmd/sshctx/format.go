@@ -0,0 +1,155 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// formatOptions select a text/template printing each host, executed with
+// its hostView.
+type formatOptions struct {
+	Format     string // template given on the command line, `\t` and `\n` are unescaped
+	FormatFile string // file to read the template from
+}
+
+// formatUnescaper turns the escapes typed in a shell into the characters.
+var formatUnescaper = strings.NewReplacer(`\t`, "\t", `\n`, "\n", `\\`, `\`)
+
+// unescapeFormat applies formatUnescaper to the text of a template outside
+// its actions, whose strings like in `{{join .Tags "\n"}}` have escapes of their own.
+func unescapeFormat(text string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(text, "{{")
+		if i == -1 {
+			b.WriteString(formatUnescaper.Replace(text))
+			return b.String()
+		}
+		b.WriteString(formatUnescaper.Replace(text[:i]))
+		end := actionEnd(text, i+2)
+		b.WriteString(text[i:end])
+		text = text[end:]
+	}
+}
+
+// actionEnd returns the index after the `}}` closing the action whose
+// content starts at i, skipping quoted strings.
+func actionEnd(text string, i int) int {
+	var quote byte
+	for ; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '`' || c == '\'':
+			quote = c
+		case strings.HasPrefix(text[i:], "}}"):
+			return i + 2
+		}
+	}
+	return len(text)
+}
+
+// hostTemplate prints a host with a template, on a line of its own unless
+// the template ends with a newline.
+type hostTemplate struct {
+	tmpl    *template.Template
+	newline bool
+	host    hostView // host being printed, read by the template funcs
+}
+
+func (o formatOptions) formatted() bool {
+	return o.Format != "" || o.FormatFile != ""
+}
+
+// template parses the template of the options.
+func (o formatOptions) template() (*hostTemplate, error) {
+	text := unescapeFormat(o.Format)
+	name := "--format"
+	if o.FormatFile != "" {
+		data, err := os.ReadFile(o.FormatFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't read format file")
+		}
+		text, name = string(data), o.FormatFile
+	}
+	ht := &hostTemplate{newline: !strings.HasSuffix(text, "\n")}
+	tmpl, err := template.New(name).Funcs(ht.funcs()).Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid format")
+	}
+	ht.tmpl = tmpl
+	return ht, nil
+}
+
+// funcs are the functions templates can use besides the fields of hostView:
+//
+//	opt "IdentityFile"   first value of an option of the host, "" if not set
+//	opts "LocalForward"  all values of an option of the host
+//	meta "backend"       value of `#sshctx: backend=...`
+//	tags                 tags of the host
+//	hasTag "prod"        whether the host has a tag
+//	join .Tags ","       strings.Join
+func (ht *hostTemplate) funcs() template.FuncMap {
+	return template.FuncMap{
+		"opt": func(key string) string {
+			if v := ht.host.Options[strings.ToLower(key)]; len(v) > 0 {
+				return v[0]
+			}
+			return ""
+		},
+		"opts": func(key string) []string {
+			return ht.host.Options[strings.ToLower(key)]
+		},
+		"meta": func(key string) string {
+			return ht.host.Metadata[strings.ToLower(key)]
+		},
+		"tags": func() []string {
+			return ht.host.Tags
+		},
+		"hasTag": func(tag string) bool {
+			for _, t := range ht.host.Tags {
+				if t == tag {
+					return true
+				}
+			}
+			return false
+		},
+		"join": strings.Join,
+	}
+}
+
+// execute prints v with the template.
+func (ht *hostTemplate) execute(w io.Writer, v hostView) error {
+	ht.host = v
+	if err := ht.tmpl.Execute(w, v); err != nil {
+		return errors.Wrap(err, "can't format host")
+	}
+	if ht.newline {
+		_, err := io.WriteString(w, "\n")
+		return errors.Wrap(err, "write error")
+	}
+	return nil
+}
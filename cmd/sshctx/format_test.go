@@ -0,0 +1,83 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListOp_format(t *testing.T) {
+	setupKnownHosts(t, listTestConfig)
+	t.Setenv("USER", "me")
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: `{{.DisplayName}}\t{{.Host}}:{{.Port}}`, want: "web\t10.0.0.1:22\ndb\t10.0.0.2:2222\n"},
+		{format: `{{.DisplayName}} {{opt "identityfile"}}{{opt "ProxyJump"}}`, want: "web \ndb ~/.ssh/db\n"},
+		{format: `{{join tags "+"}}{{if hasTag "prod"}} prod{{end}}`, want: "prod+frontend prod\n\n"},
+		{format: `{{.Host}} {{.DisplayName}}\n`, want: "10.0.0.1 web\n10.0.0.2 db\n"},
+		{format: `{{.DisplayName}}:\t{{join tags "\n\t"}}`, want: "web:\tprod\n\tfrontend\ndb:\t\n"},
+		{format: `{{"}}\\n"}}\n`, want: "}}\\n\n}}\\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if err := (ListOp{formatOptions: formatOptions{Format: tt.format}}).Run(&stdout, &stderr); err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != tt.want {
+				t.Errorf("output = %q, want %q", stdout.String(), tt.want)
+			}
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "inventory.tmpl")
+	if err := os.WriteFile(path, []byte("[{{.DisplayName}}]\nuser={{.User}}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if err := (ListOp{formatOptions: formatOptions{FormatFile: path}}).Run(&stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if want := "[web]\nuser=deploy\n[db]\nuser=me\n"; stdout.String() != want {
+		t.Errorf("--format-file output = %q, want %q", stdout.String(), want)
+	}
+
+	for _, format := range []string{"{{.Nope}}", "{{.DisplayName"} {
+		if err := (ListOp{formatOptions: formatOptions{Format: format}}).Run(&stdout, &stderr); err == nil {
+			t.Errorf("--format %s succeeded", format)
+		}
+	}
+}
+
+func TestParseFormatArgs(t *testing.T) {
+	if op, ok := parseArgs([]string{"-p", "--format", "{{.Host}}"}).(PreviousOp); !ok || op.Format != "{{.Host}}" {
+		t.Errorf("parseArgs(-p --format) = %#v", op)
+	}
+	for _, argv := range [][]string{
+		{"--format", "{{.Host}}", "-o", "json"},
+		{"--format", "{{.Host}}", "--format-file", "x"},
+		{"--format"},
+	} {
+		if _, ok := parseArgs(argv).(UnsupportedOp); !ok {
+			t.Errorf("parseArgs(%q) succeeded", argv)
+		}
+	}
+}
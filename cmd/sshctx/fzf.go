@@ -17,6 +17,7 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"github.com/spencercjh/sshctx/internal/cmdutil"
 	"github.com/spencercjh/sshctx/internal/env"
 	"github.com/spencercjh/sshctx/internal/sshconfig"
 	"io"
@@ -29,6 +30,7 @@ import (
 
 type FzfOp struct {
 	SelfCmd string
+	filterOptions
 }
 
 func (op FzfOp) Run(stdout, stderr io.Writer) error {
@@ -48,8 +50,12 @@ func (op FzfOp) Run(stdout, stderr io.Writer) error {
 	cmd.Stderr = stderr
 	cmd.Stdout = &out
 
+	list := op.SelfCmd
+	if op.filtered() {
+		list = cmdutil.ShellQuote(append([]string{op.SelfCmd}, op.args()...))
+	}
 	cmd.Env = append(os.Environ(),
-		fmt.Sprintf("FZF_DEFAULT_COMMAND=%s", op.SelfCmd),
+		fmt.Sprintf("FZF_DEFAULT_COMMAND=%s", list),
 		fmt.Sprintf("%s=1", env.ForceColor))
 	if err := cmd.Run(); err != nil {
 		var exitError *exec.ExitError
@@ -61,7 +67,7 @@ func (op FzfOp) Run(stdout, stderr io.Writer) error {
 	if choice == "" {
 		return errors.New("you did not choose any of the options")
 	}
-	displayName, sshPara, err := connectTarget(choice, stderr)
+	displayName, sshPara, err := connectTarget(choice, connectOptions{}, stderr)
 	if err != nil {
 		return errors.Wrap(err, "failed to switch host")
 	}
@@ -32,9 +32,64 @@ func (op HelpOp) Run(stdout, _ io.Writer) error {
 func printUsage(out io.Writer) error {
 	help := `USAGE:
   %PROG%                       : list the hosts
+  %PROG% --with-status         : list the hosts and whether they're reachable
+  %PROG% -o, --output json|yaml|name|wide
+                               : list the hosts of ssh_config for scripts, or as a table
+  %PROG% --format <TEMPLATE>   : print each host of ssh_config with a Go template
+  %PROG% --format-file <FILE>  : the same with the template in <FILE>
+  %PROG% --filter <EXPR>       : list or pick from the hosts matching <EXPR>, e.g. 'tag=prod and has:ProxyJump'
+  %PROG% search <TEXT>         : list or pick from the hosts with any field containing <TEXT>
   %PROG% <HOST>                : connect to <HOST>
+  %PROG% --wait [--timeout <DURATION>] <HOST>
+                               : wait until <HOST> accepts SSH connections, then connect
+  %PROG% --reconnect [--attempts <N>] <HOST>
+                               : reconnect to <HOST> when the connection drops
+  %PROG% --record[-input] <HOST>
+                               : record the session (and what is typed) to ~/.sshctx/recordings
+  %PROG% --tmux window|split <HOST>
+                               : connect to <HOST> in a new tmux window or pane
+  %PROG% --tmux tiled [--sync] <SELECTOR>
+                               : open a tmux window with a pane per host, optionally typing into all
   %PROG% -                     : connect to the previous successfully connected host
+  %PROG% --dry-run, --print <HOST>|-
+                               : print the command connecting to <HOST> instead of running it
   %PROG% -p, --previous        : show the previous successfully connected host
+  %PROG% -p --format <TEMPLATE>|--format-file <FILE>
+                               : print the previous host with a Go template
+  %PROG% broadcast <SELECTOR>  : type into all hosts matching <SELECTOR> at once
+  %PROG% cp <SRC> <DST>        : copy files, either side can be <HOST>:<PATH>
+  %PROG% cp --to <SELECTOR> <FILE> <PATH>
+                               : copy <FILE> to <PATH> on all hosts matching <SELECTOR>
+  %PROG% cp --dry-run ...      : print the copy commands instead of running them
+  %PROG% exec [--engine ssh|go] <SELECTOR> <COMMAND>
+                               : run <COMMAND> on all hosts matching <SELECTOR>
+  %PROG% exec --filter <EXPR> [<SELECTOR>] -- <COMMAND>
+                               : run <COMMAND> on all hosts matching <EXPR> (and <SELECTOR>)
+  %PROG% exec --dry-run ...    : print how the command would run instead of running it
+  %PROG% tunnel up|down <NAME> : start or stop the tunnel <NAME> defined in settings
+  %PROG% tunnel status [<NAME>]
+                               : show whether tunnels are up and accept connections
+  %PROG% mux ls                : list ControlMaster connections and check they're alive
+  %PROG% mux stop <HOST>|--all : close ControlMaster connections, removing stale sockets
+  %PROG% mux warm <SELECTOR>   : open ControlMaster connections in the background
+  %PROG% check [<SELECTOR>]    : check hosts accept SSH connections, showing version and latency
+  %PROG% keys                  : list the keys and certificates of the hosts, flagging problems
+  %PROG% agent status          : show whether ssh-agent holds the key of each host
+  %PROG% known-hosts status    : show whether known_hosts has the key of each host
+  %PROG% known-hosts prune [--dry-run] [--yes] [--hashed]
+                               : remove known_hosts entries of hosts that are no longer in ssh_config
+  %PROG% known-hosts forget <HOST>
+                               : remove the known_hosts entries of <HOST>, e.g. after it was rebuilt
+  %PROG% export --format <FORMAT> [--filter <EXPR>]
+                               : write the hosts as ansible-ini, ansible-yaml, csv, json, prometheus-sd or hosts
+  %PROG% import <FILE> --format <FORMAT> [--to <FILE>] [--prefix <P>] [--tag <T>] [--dry-run] [--yes]
+                               : add the hosts of an inventory or the sessions of PuTTY, MobaXterm or SecureCRT as Host blocks
+  %PROG% adopt [--name <NAME>] [--dry-run] <HOST>
+                               : add a Host block for a discovered host like user@host:port to ssh_config
+  %PROG% recordings ls [<HOST>]
+                               : list recorded sessions
+  %PROG% recordings play <FILE>|<HOST>
+                               : replay a recording, or the latest one of <HOST>, at its original speed
   %PROG% -h,--help             : show this message
   %PROG% -v,-V,--version       : show version`
 	help = strings.ReplaceAll(help, "%PROG%", selfName())
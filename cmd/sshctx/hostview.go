@@ -0,0 +1,83 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"time"
+
+	"github.com/spencercjh/sshctx/internal/probe"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+// hostView is a host of sshconfig with everything sshctx knows about it, as
+// printed by `-o json` and `-o yaml`.
+type hostView struct {
+	DisplayName string              `json:"name" yaml:"name"`
+	Host        string              `json:"hostname" yaml:"hostname"`
+	User        string              `json:"user" yaml:"user"`
+	Port        int                 `json:"port" yaml:"port"`                           // 22 if not set
+	Options     map[string][]string `json:"options,omitempty" yaml:"options,omitempty"` // lower case keys, like SSHConfig.Options
+	Tags        []string            `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Metadata    map[string]string   `json:"metadata,omitempty" yaml:"metadata,omitempty"` // `#sshctx: key=value` comments
+	Source      string              `json:"source" yaml:"source"`                         // sshconfig file of the Host block
+	Line        int                 `json:"line,omitempty" yaml:"line,omitempty"`         // line of the Host block
+	LastUsed    *time.Time          `json:"last_used,omitempty" yaml:"last_used,omitempty"`
+	Status      *hostStatus         `json:"status,omitempty" yaml:"status,omitempty"` // only with `--with-status`
+}
+
+// hostStatus is whether a host accepts SSH connections.
+type hostStatus struct {
+	Up        bool   `json:"up" yaml:"up"`
+	LatencyMS int64  `json:"latency_ms,omitempty" yaml:"latency_ms,omitempty"`
+	Banner    string `json:"banner,omitempty" yaml:"banner,omitempty"`
+}
+
+// newHostView collects what is known about h. lastUsed maps DisplayNames to
+// the last time they were connected to.
+func newHostView(sc *sshconfig.SSHConfig, h sshconfig.Host, source string, lastUsed map[string]time.Time) hostView {
+	v := hostView{
+		DisplayName: h.DisplayName,
+		Host:        h.Host,
+		User:        h.Username,
+		Port:        h.EffectivePort(),
+		Options:     sc.Options(h),
+		Tags:        sc.Tags(h),
+		Metadata:    sc.Metadata(h),
+		Source:      source,
+	}
+	if len(v.Options) == 0 {
+		v.Options = nil
+	}
+	if len(v.Metadata) == 0 {
+		v.Metadata = nil
+	}
+	if b, ok := sc.HostBlock(h); ok {
+		v.Line = b.Line
+	}
+	if t, ok := lastUsed[h.DisplayName]; ok {
+		v.LastUsed = &t
+	}
+	return v
+}
+
+// withStatus adds the result of probing the host.
+func (v hostView) withStatus(r probe.Result) hostView {
+	v.Status = &hostStatus{Up: r.Up}
+	if r.Up {
+		v.Status.LatencyMS = r.Latency.Milliseconds()
+		v.Status.Banner = r.Banner
+	}
+	return v
+}
@@ -0,0 +1,333 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mattn/go-isatty"
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/cmdutil"
+	"github.com/spencercjh/sshctx/internal/inventory"
+	"github.com/spencercjh/sshctx/internal/printer"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+// Formats of `import`.
+const (
+	importAnsible   = "ansible"
+	importCSV       = "csv"
+	importEtcHosts  = "etc-hosts"
+	importPuTTY     = "putty"
+	importMobaXterm = "mobaxterm"
+	importSecureCRT = "securecrt"
+)
+
+// importParsers read the hosts of each import format.
+var importParsers = map[string]func(io.Reader) ([]inventory.Host, []string, error){
+	importAnsible:   inventory.ParseAnsible,
+	importCSV:       inventory.ParseCSV,
+	importEtcHosts:  inventory.ParseEtcHosts,
+	importPuTTY:     inventory.ParsePuTTY,
+	importMobaXterm: inventory.ParseMobaXterm,
+	importSecureCRT: inventory.ParseSecureCRT,
+}
+
+// importFormats are the formats of `import` in the order of the help.
+var importFormats = []string{importAnsible, importCSV, importEtcHosts, importPuTTY, importMobaXterm, importSecureCRT}
+
+// What importing does with a host.
+const (
+	importNew       = "new"
+	importChanged   = "changed"
+	importUnchanged = "unchanged"
+	importConflict  = "conflict"
+)
+
+// ImportOp describes adding the hosts of another tool's inventory to sshconfig.
+type ImportOp struct {
+	File   string
+	Format string
+	To     string   // file to write the Host blocks to, sshconfig if empty
+	Prefix string   // prepended to the names of the hosts
+	Tags   []string // added to every host
+	DryRun bool     // only show what would be imported
+	Yes    bool     // import without asking
+}
+
+// importedHost is a host to import and what importing does with it.
+type importedHost struct {
+	inventory.Host
+	status string
+	reason string // why it conflicts
+	first  int    // 0-based lines of the Host block it replaces, if changed
+	last   int
+}
+
+func (op ImportOp) Run(stdout, stderr io.Writer) error {
+	parse, ok := importParsers[op.Format]
+	if !ok {
+		return errors.Errorf("unsupported import format '%s'", op.Format)
+	}
+	f, err := os.Open(op.File)
+	if err != nil {
+		return errors.Wrap(err, "can't open import file")
+	}
+	hosts, warnings, err := parse(f)
+	_ = f.Close()
+	if err != nil {
+		return errors.Wrapf(err, "can't import %s", op.File)
+	}
+	for _, w := range warnings {
+		_, _ = fmt.Fprintf(stderr, "%s %s\n", printer.WarningColor.Sprint("warning:"), w)
+	}
+	source, err := filepath.Abs(op.File)
+	if err != nil {
+		return errors.Wrap(err, "can't determine import file path")
+	}
+
+	configPath, err := sshconfig.GetSSHConfigPath()
+	if err != nil {
+		return errors.Wrap(err, "Can't determine sshconfig path")
+	}
+	target := op.To
+	if target == "" {
+		target = configPath
+	}
+	data, err := os.ReadFile(target)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "can't read import target")
+	}
+	// hosts of sshconfig conflict too when writing to another file
+	var sc *sshconfig.SSHConfig
+	if !sameFile(target, configPath) {
+		sc = new(sshconfig.SSHConfig).WithLoader(sshconfig.DefaultLoader)
+
+		defer func(sshConfig *sshconfig.SSHConfig) {
+			_ = sshConfig.Close()
+		}(sc)
+
+		if err := sc.Parse(); err != nil {
+			return errors.Wrap(err, "sshconfig error")
+		}
+	}
+
+	imported, err := op.plan(sc, hosts, source, data)
+	if err != nil {
+		return err
+	}
+	printImportPlan(stdout, imported)
+	count := 0
+	for _, h := range imported {
+		if h.status == importNew || h.status == importChanged {
+			count++
+		}
+	}
+	if count == 0 {
+		_ = printer.Success(stderr, "Nothing to import.")
+		return nil
+	}
+	if sc != nil {
+		// sshctx doesn't follow Include, unlike ssh
+		_, _ = fmt.Fprintf(stderr, "%s sshctx won't list the hosts of %s, it only reads %s. Add `Include %s` to it so ssh finds them.\n",
+			printer.WarningColor.Sprint("note:"), tildePath(target), tildePath(configPath), target)
+	}
+	if op.DryRun {
+		return nil
+	}
+	if !op.Yes {
+		if !isatty.IsTerminal(os.Stdin.Fd()) {
+			return errors.New("refusing to import without confirmation, pass --yes")
+		}
+		_, _ = fmt.Fprintf(stderr, "%s Write %d hosts to %s? [y/N] ", printer.WarningColor.Sprint("?"), count, tildePath(target))
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			return nil
+		}
+	}
+	if err := writeImport(target, data, imported, source); err != nil {
+		return err
+	}
+	_ = printer.Success(stderr, "Wrote %d hosts to %s.", count, tildePath(target))
+	return nil
+}
+
+// plan decides what importing does with each host. data is the current
+// contents of the target file. The hosts of sc conflict too unless it's nil.
+func (op ImportOp) plan(sc *sshconfig.SSHConfig, hosts []inventory.Host, source string, data []byte) ([]importedHost, error) {
+	blocks, err := sshconfig.ParseBlocks(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(data), "\n")
+	seen := map[string]bool{}
+	var imported []importedHost
+	for _, h := range hosts {
+		h.Name = op.Prefix + h.Name
+		h.AddTags(op.Tags...)
+		ih := importedHost{Host: h, status: importNew}
+		switch {
+		case strings.ContainsAny(h.Name, " \t*?!") || strings.ContainsAny(h.Hostname, " \t"):
+			ih.status, ih.reason = importConflict, "invalid name"
+		case seen[h.Name]:
+			ih.status, ih.reason = importConflict, "duplicate in "+filepath.Base(source)
+		}
+		seen[h.Name] = true
+		if ih.status == importConflict {
+			imported = append(imported, ih)
+			continue
+		}
+
+		for i, b := range blocks {
+			if b.Line == 0 || b.Match != "" || strings.Join(b.Patterns, " ") != h.Name {
+				continue
+			}
+			if blockMetadata(b, inventory.ImportedFromKey) != source {
+				ih.status, ih.reason = importConflict, "already a host"
+				break
+			}
+			ih.first, ih.last = b.Line-1, blockEnd(lines, blocks, i)
+			ih.status = importChanged
+			if strings.Join(lines[ih.first:ih.last+1], "\n")+"\n" == h.Block(source) {
+				ih.status = importUnchanged
+			}
+			break
+		}
+		if ih.status == importNew && sc != nil {
+			if _, err := sc.Lookup(h.Name); err == nil {
+				ih.status, ih.reason = importConflict, "already a host in ssh_config"
+			}
+		}
+		imported = append(imported, ih)
+	}
+	return imported, nil
+}
+
+// blockMetadata returns the value of a `#sshctx:` comment of the block.
+func blockMetadata(b sshconfig.Block, key string) string {
+	for _, m := range b.Metadata {
+		if strings.EqualFold(m.Key, key) {
+			return m.Value
+		}
+	}
+	return ""
+}
+
+// blockEnd returns the 0-based last line of the i-th block, leaving out the
+// empty lines and comments before the next block.
+func blockEnd(lines []string, blocks []sshconfig.Block, i int) int {
+	end := len(lines) - 1
+	if i+1 < len(blocks) {
+		end = blocks[i+1].Line - 2
+	}
+	for end >= blocks[i].Line {
+		l := strings.TrimSpace(lines[end])
+		if l != "" && (!strings.HasPrefix(l, "#") || strings.HasPrefix(l, "#sshctx:")) {
+			break
+		}
+		end--
+	}
+	return end
+}
+
+func printImportPlan(stdout io.Writer, imported []importedHost) {
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tHOSTNAME\tUSER\tPORT\tTAGS\tSTATUS")
+	for _, h := range imported {
+		user, port, tags := h.User, "-", "-"
+		if user == "" {
+			user = "-"
+		}
+		if h.Port > 0 {
+			port = strconv.Itoa(h.Port)
+		}
+		if len(h.Tags) > 0 {
+			tags = strings.Join(h.Tags, ",")
+		}
+		status := h.status
+		switch h.status {
+		case importNew:
+			status = printer.SuccessColor.Sprint(status)
+		case importChanged:
+			status = printer.WarningColor.Sprint(status)
+		case importConflict:
+			status = printer.ErrorColor.Sprint(status) + " (" + h.reason + ")"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", h.Name, h.Hostname, user, port, tags, status)
+	}
+	_ = w.Flush()
+}
+
+// writeImport replaces the changed Host blocks of the target file and appends
+// the new ones, keeping the previous contents in `<target>.old`.
+func writeImport(target string, data []byte, imported []importedHost, source string) error {
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+	// replace from the bottom so the lines of the blocks above stay valid
+	for i := len(imported) - 1; i >= 0; i-- {
+		h := imported[i]
+		if h.status != importChanged {
+			continue
+		}
+		block := strings.Split(strings.TrimSuffix(h.Block(source), "\n"), "\n")
+		lines = append(lines[:h.first], append(block, lines[h.last+1:]...)...)
+	}
+	var b strings.Builder
+	if len(lines) > 0 {
+		b.WriteString(strings.Join(lines, "\n") + "\n")
+	}
+	for _, h := range imported {
+		if h.status == importNew {
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(h.Block(source))
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return errors.Wrap(err, "can't create import target dir")
+	}
+	mode := os.FileMode(0600)
+	if fi, err := os.Stat(target); err == nil {
+		mode = fi.Mode().Perm()
+		if err := os.WriteFile(target+".old", data, mode); err != nil {
+			return errors.Wrap(err, "can't back up import target")
+		}
+	}
+	return errors.Wrap(cmdutil.WriteFileAtomic(target, []byte(b.String()), mode), "can't write import target")
+}
+
+// sameFile reports whether two paths are the same file, even if it doesn't exist yet.
+func sameFile(a, b string) bool {
+	fa, errA := os.Stat(a)
+	fb, errB := os.Stat(b)
+	if errA == nil && errB == nil {
+		return os.SameFile(fa, fb)
+	}
+	absA, _ := filepath.Abs(a)
+	absB, _ := filepath.Abs(b)
+	return absA == absB
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runImport(t *testing.T, op ImportOp) (string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	if err := op.Run(&stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	return stdout.String(), stderr.String()
+}
+
+func TestImportOp(t *testing.T) {
+	setupKnownHosts(t, knownHostsTestConfig)
+	path := os.Getenv("SSHCONFIG")
+	csvPath := filepath.Join(t.TempDir(), "hosts.csv")
+	writeCSV := func(rows string) {
+		if err := os.WriteFile(csvPath, []byte("name,hostname,user,port,tags\n"+rows), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeCSV("app,10.1.0.1,deploy,22,prod\ncache,10.1.0.2,,6022,\n")
+	op := ImportOp{File: csvPath, Format: importCSV, Prefix: "lab-", Tags: []string{"lab"}, Yes: true}
+
+	stdout, _ := runImport(t, ImportOp{File: csvPath, Format: importCSV, Prefix: "lab-", DryRun: true})
+	if !strings.Contains(stdout, "lab-app") || !strings.Contains(stdout, "new") {
+		t.Errorf("dry run printed %q", stdout)
+	}
+	if data, _ := os.ReadFile(path); string(data) != knownHostsTestConfig {
+		t.Fatalf("dry run changed ssh_config:\n%s", data)
+	}
+
+	runImport(t, op)
+	want := knownHostsTestConfig + `
+Host lab-app
+    Hostname 10.1.0.1
+    User deploy
+    #sshctx: tags=prod,lab
+    #sshctx: imported-from=` + csvPath + `
+
+Host lab-cache
+    Hostname 10.1.0.2
+    Port 6022
+    #sshctx: tags=lab
+    #sshctx: imported-from=` + csvPath + `
+`
+	if data, _ := os.ReadFile(path); string(data) != want {
+		t.Fatalf("ssh_config after import:\n%s\nwant:\n%s", data, want)
+	}
+	if data, _ := os.ReadFile(path + ".old"); string(data) != knownHostsTestConfig {
+		t.Errorf("backup = %q, want the previous ssh_config", data)
+	}
+
+	// importing again updates the blocks it created instead of adding more
+	writeCSV("app,10.1.0.9,deploy,22,prod\ncache,10.1.0.2,,6022,\n")
+	stdout, _ = runImport(t, op)
+	if !strings.Contains(stdout, "changed") || !strings.Contains(stdout, "unchanged") {
+		t.Errorf("re-import printed %q", stdout)
+	}
+	want = strings.Replace(want, "10.1.0.1", "10.1.0.9", 1)
+	if data, _ := os.ReadFile(path); string(data) != want {
+		t.Fatalf("ssh_config after re-import:\n%s\nwant:\n%s", data, want)
+	}
+
+	_, stderr := runImport(t, op)
+	if !strings.Contains(stderr, "Nothing to import") {
+		t.Errorf("third import printed %q", stderr)
+	}
+}
+
+func TestImportOp_conflicts(t *testing.T) {
+	setupKnownHosts(t, knownHostsTestConfig)
+	path := os.Getenv("SSHCONFIG")
+	hostsPath := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(hostsPath, []byte("10.0.0.1 web\n10.0.0.5 new-web\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _ := runImport(t, ImportOp{File: hostsPath, Format: importEtcHosts, Yes: true})
+	if !strings.Contains(stdout, "conflict (already a host)") {
+		t.Errorf("import printed %q", stdout)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Count(string(data), "Host web\n") != 1 || !strings.Contains(string(data), "Host new-web\n") {
+		t.Errorf("ssh_config after import:\n%s", data)
+	}
+
+	// hosts of ssh_config conflict when writing to another file too
+	if err := os.WriteFile(hostsPath, []byte("10.0.0.1 web\n10.0.0.6 other-web\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	to := filepath.Join(t.TempDir(), "imported")
+	stdout, stderr := runImport(t, ImportOp{File: hostsPath, Format: importEtcHosts, To: to, Yes: true})
+	if !strings.Contains(stdout, "conflict (already a host in ssh_config)") || !strings.Contains(stderr, "won't list the hosts") ||
+		!strings.Contains(stderr, "Include "+to) {
+		t.Errorf("import printed %q, %q", stdout, stderr)
+	}
+	if data, _ := os.ReadFile(to); strings.Contains(string(data), "Host web\n") || !strings.Contains(string(data), "Host other-web\n") {
+		t.Errorf("%s:\n%s", to, data)
+	}
+}
+
+func TestParseImportArgs(t *testing.T) {
+	op, ok := parseArgs([]string{"import", "inv.ini", "--format", "ansible", "--prefix", "lab-", "--tag", "a,b", "--tag", "c", "--to", "lab", "-y"}).(ImportOp)
+	if !ok || op.File != "inv.ini" || op.Format != "ansible" || op.Prefix != "lab-" || strings.Join(op.Tags, ",") != "a,b,c" || op.To != "lab" || !op.Yes {
+		t.Errorf("parseArgs() = %#v", op)
+	}
+	for _, argv := range [][]string{{"import", "--format", "csv"}, {"import", "a.csv"}, {"import", "a.csv", "--format", "xml"}, {"import", "a.csv", "b.csv", "--format", "csv"}} {
+		if _, ok := parseArgs(argv).(UnsupportedOp); !ok {
+			t.Errorf("parseArgs(%q) should be unsupported", argv)
+		}
+	}
+}
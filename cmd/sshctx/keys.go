@@ -0,0 +1,206 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/printer"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+	"github.com/spencercjh/sshctx/internal/sshkeys"
+	"golang.org/x/crypto/ssh"
+)
+
+// certTimeLayout formats the validity of certificates.
+const certTimeLayout = "2006-01-02 15:04"
+
+// KeysOp describes listing and auditing the identity and certificate files of the hosts.
+type KeysOp struct{}
+
+// keyFile is a file referenced by IdentityFile or CertificateFile.
+type keyFile struct {
+	path  string
+	cert  bool
+	hosts []sshconfig.Host
+}
+
+func (op KeysOp) Run(stdout, _ io.Writer) error {
+	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.DefaultLoader)
+
+	defer func(sshConfig *sshconfig.SSHConfig) {
+		_ = sshConfig.Close()
+	}(sc)
+
+	if err := sc.Parse(); err != nil {
+		return errors.Wrap(err, "sshconfig error")
+	}
+	files := referencedKeyFiles(sc)
+
+	now := time.Now()
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "FILE\tTYPE\tBITS\tFINGERPRINT\tPROBLEMS\tHOSTS")
+	bad := 0
+	var certs []sshkeys.Info
+	for _, f := range files {
+		var info sshkeys.Info
+		if f.cert {
+			info = sshkeys.InspectCertificate(f.path)
+			if info.Cert != nil {
+				certs = append(certs, info)
+				info.Problems = append(info.Problems, certificateProblems(info.Cert, f.hosts, now)...)
+			}
+		} else {
+			info = sshkeys.InspectIdentity(f.path)
+			if names := sha1Hosts(sc, f.hosts); info.Type == ssh.KeyAlgoRSA && len(names) > 0 {
+				info.Problems = append(info.Problems, "signs with ssh-rsa (SHA-1) for "+strings.Join(names, ","))
+			}
+		}
+		typ, bits, fingerprint := "-", "-", "-"
+		if info.Type != "" {
+			typ, bits, fingerprint = info.Type, strconv.Itoa(info.Bits), info.Fingerprint
+		}
+		problems := printer.SuccessColor.Sprint("ok")
+		if len(info.Problems) > 0 {
+			bad++
+			problems = printer.ErrorColor.Sprint(strings.Join(info.Problems, ", "))
+		}
+		names := make([]string, len(f.hosts))
+		for i, h := range f.hosts {
+			names[i] = h.DisplayName
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			tildePath(f.path), typ, bits, fingerprint, problems, strings.Join(names, ","))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(certs) > 0 {
+		_, _ = fmt.Fprintln(stdout)
+		w = tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "CERTIFICATE\tVALID FROM\tVALID UNTIL\tREMAINING\tPRINCIPALS")
+		for _, c := range certs {
+			until, remaining := "forever", "-"
+			if before, ok := sshkeys.ValidBefore(c.Cert); ok {
+				until = before.Format(certTimeLayout)
+				remaining = printer.SuccessColor.Sprint(before.Sub(now).Round(time.Minute))
+				if !now.Before(before) {
+					remaining = printer.ErrorColor.Sprint("expired")
+				}
+			}
+			principals := strings.Join(c.Cert.ValidPrincipals, ",")
+			if principals == "" {
+				principals = "(any)"
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", tildePath(c.Path),
+				sshkeys.ValidAfter(c.Cert).Format(certTimeLayout), until, remaining, principals)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if bad > 0 {
+		return errors.Errorf("%d of %d key files have problems", bad, len(files))
+	}
+	return nil
+}
+
+// sha1Hosts returns the names of the hosts letting ssh(1) sign with ssh-rsa,
+// which uses SHA-1, through PubkeyAcceptedAlgorithms or its old name.
+func sha1Hosts(sc *sshconfig.SSHConfig, hosts []sshconfig.Host) []string {
+	var names []string
+	for _, h := range hosts {
+		algorithms := sc.Option(h, "PubkeyAcceptedAlgorithms")
+		if algorithms == "" {
+			algorithms = sc.Option(h, "PubkeyAcceptedKeyTypes")
+		}
+		if sshkeys.AllowsSHA1(algorithms) {
+			names = append(names, h.DisplayName)
+		}
+	}
+	return names
+}
+
+// certificateProblems returns why the certificate can't be used now to log in to the hosts.
+func certificateProblems(cert *ssh.Certificate, hosts []sshconfig.Host, now time.Time) []string {
+	problems := sshkeys.CheckValidity(cert, now)
+	for _, h := range hosts {
+		if !sshkeys.HasPrincipal(cert, h.Username) {
+			problems = append(problems, fmt.Sprintf("no principal %s for %s", h.Username, h.DisplayName))
+		}
+	}
+	return problems
+}
+
+// certificateWarnings returns the problems of the certificates of h, which
+// make ssh(1) fall back to other ways to authenticate.
+func certificateWarnings(sc *sshconfig.SSHConfig, h sshconfig.Host) []string {
+	var warnings []string
+	for _, path := range sc.CertificateFiles(h) {
+		info := sshkeys.InspectCertificate(path)
+		if info.Cert == nil {
+			continue
+		}
+		for _, p := range certificateProblems(info.Cert, []sshconfig.Host{h}, time.Now()) {
+			warnings = append(warnings, fmt.Sprintf("certificate %s: %s", tildePath(path), p))
+		}
+	}
+	return warnings
+}
+
+// referencedKeyFiles returns the identity and certificate files the hosts
+// use, in the order they are first referenced. Like ssh(1), relative paths
+// are relative to the current directory once ~ and tokens are expanded.
+func referencedKeyFiles(sc *sshconfig.SSHConfig) []*keyFile {
+	var files []*keyFile
+	byPath := map[string]*keyFile{}
+	add := func(h sshconfig.Host, path string, cert bool) {
+		key := path
+		if abs, err := filepath.Abs(path); err == nil {
+			key = abs
+		}
+		f, ok := byPath[key]
+		if !ok {
+			f = &keyFile{path: path, cert: cert}
+			byPath[key] = f
+			files = append(files, f)
+		}
+		for _, other := range f.hosts {
+			if other.DisplayName == h.DisplayName {
+				return
+			}
+		}
+		f.hosts = append(f.hosts, h)
+	}
+	for _, h := range sc.Hosts {
+		for _, p := range sc.Options(h)["identityfile"] {
+			if p != "none" {
+				add(h, sc.ExpandTokens(h, p), false)
+			}
+		}
+		for _, p := range sc.CertificateFiles(h) {
+			add(h, p, true)
+		}
+	}
+	return files
+}
@@ -0,0 +1,95 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestKeysOp(t *testing.T) {
+	setupKnownHosts(t, `Host legacy
+    Hostname 10.0.0.1
+    User root
+    IdentityFile keys/id_rsa
+    PubkeyAcceptedAlgorithms +ssh-rsa
+
+Host modern
+    Hostname 10.0.0.2
+    User root
+    IdentityFile ./keys/id_rsa
+    IdentityFile keys/id_ed25519
+`)
+	// relative paths are relative to the current directory, not the home one
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.Chdir(wd)
+	}()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll("keys", 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, pub := range map[string]interface{}{"id_rsa": &rsaKey.PublicKey, "id_ed25519": edPub} {
+		sshPub, err := ssh.NewPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join("keys", name)
+		if err := os.WriteFile(path, []byte("private"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path+".pub", ssh.MarshalAuthorizedKey(sshPub), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout bytes.Buffer
+	err = (KeysOp{}).Run(&stdout, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 key files") {
+		t.Errorf("Run() error = %v, want the RSA key to have problems", err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("output = %q", stdout.String())
+	}
+	if !strings.HasPrefix(lines[1], "keys/id_rsa ") || !strings.Contains(lines[1], "signs with ssh-rsa (SHA-1) for legacy") {
+		t.Errorf("RSA key line = %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "keys/id_ed25519 ") || !strings.Contains(lines[2], "ok") {
+		t.Errorf("ed25519 key line = %q", lines[2])
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/cmdutil"
+	"github.com/spencercjh/sshctx/internal/knownhosts"
+	"github.com/spencercjh/sshctx/internal/printer"
+	"github.com/spencercjh/sshctx/internal/sshagent"
+	"github.com/spencercjh/sshctx/internal/sshclient"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// hostKeyCheckTimeout limits how long checking whether a host key changed may take.
+const hostKeyCheckTimeout = 3 * time.Second
+
+// KnownHostsOp describes inspecting and cleaning up known_hosts files.
+type KnownHostsOp struct {
+	Action string // "status", "prune" or "forget"
+	Target string // host to forget
+	DryRun bool   // only list what prune would remove
+	Yes    bool   // prune without asking
+	Hashed bool   // prune hashed entries too
+}
+
+// knownHostsEntry is an entry of a known_hosts file.
+type knownHostsEntry struct {
+	file *knownhosts.File
+	knownhosts.Entry
+}
+
+func (e knownHostsEntry) String() string {
+	names := strings.Join(e.Patterns, ",")
+	if e.Hashed() {
+		names = "(hashed)"
+	}
+	return fmt.Sprintf("%s:%d %s %s", tildePath(e.file.Path), e.Line, names, e.Key.Type())
+}
+
+func (op KnownHostsOp) Run(stdout, stderr io.Writer) error {
+	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.DefaultLoader)
+
+	defer func(sshConfig *sshconfig.SSHConfig) {
+		_ = sshConfig.Close()
+	}(sc)
+
+	if err := sc.Parse(); err != nil {
+		return errors.Wrap(err, "sshconfig error")
+	}
+	files := map[string]*knownhosts.File{}
+	switch op.Action {
+	case "status":
+		return knownHostsStatus(stdout, stderr, sc, files)
+	case "prune":
+		return op.prune(stdout, stderr, sc, files)
+	case "forget":
+		return forgetHost(stdout, stderr, sc, files, op.Target)
+	}
+	return errors.Errorf("unsupported known-hosts action '%s'", op.Action)
+}
+
+// knownHostsStatus prints for each host whether known_hosts has its key.
+func knownHostsStatus(stdout, stderr io.Writer, sc *sshconfig.SSHConfig, files map[string]*knownhosts.File) error {
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "HOST\tNAME\tSTATUS\tENTRIES")
+	for _, h := range sc.Hosts {
+		name := hostKeyName(sc, h)
+		var entries []knownHostsEntry
+		for _, path := range append(sc.UserKnownHostsFiles(h), sc.GlobalKnownHostsFiles(h)...) {
+			f, err := loadKnownHosts(stderr, files, path)
+			if err != nil {
+				continue
+			}
+			for _, e := range f.Entries {
+				if e.Marker != "@revoked" && e.Matches(name) {
+					entries = append(entries, knownHostsEntry{file: f, Entry: e})
+				}
+			}
+		}
+
+		status := printer.WarningColor.Sprint("unknown")
+		var where []string
+		for _, e := range entries {
+			status = printer.SuccessColor.Sprint("known")
+			desc := fmt.Sprintf("%s:%d %s", tildePath(e.file.Path), e.Line, e.Key.Type())
+			switch {
+			case e.Marker == "@cert-authority":
+				desc += " (CA)"
+			case e.Hashed():
+				desc += " (hashed)"
+			}
+			where = append(where, desc)
+		}
+		if len(where) == 0 {
+			where = []string{"-"}
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", h.DisplayName, name, status, strings.Join(where, ", "))
+	}
+	return w.Flush()
+}
+
+// prune removes the entries of known_hosts for hosts that are no longer in sshconfig.
+func (op KnownHostsOp) prune(stdout, stderr io.Writer, sc *sshconfig.SSHConfig, files map[string]*knownhosts.File) error {
+	names := configuredHostKeyNames(sc)
+	var paths []string
+	seen := map[string]bool{}
+	for _, h := range append([]sshconfig.Host{{}}, sc.Hosts...) {
+		for _, path := range sc.UserKnownHostsFiles(h) {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+
+	stale := map[*knownhosts.File][]knownhosts.Entry{}
+	count, keptHashed := 0, 0
+	for _, path := range paths {
+		f, err := loadKnownHosts(stderr, files, path)
+		if err != nil {
+			continue
+		}
+	entries:
+		for _, e := range f.Entries {
+			if !e.IsSpecific() {
+				continue
+			}
+			for _, name := range names {
+				if e.Matches(name) {
+					continue entries
+				}
+			}
+			// hashed entries can't be told from hosts reached without ssh_config, like github.com
+			if e.Hashed() && !op.Hashed {
+				keptHashed++
+				continue
+			}
+			stale[f] = append(stale[f], e)
+			count++
+			_, _ = fmt.Fprintln(stdout, knownHostsEntry{file: f, Entry: e})
+		}
+	}
+	if keptHashed > 0 {
+		_, _ = fmt.Fprintf(stderr, "%s kept %d hashed entries of other hosts, pass --hashed to prune them too\n",
+			printer.WarningColor.Sprint("note:"), keptHashed)
+	}
+	if count == 0 {
+		_ = printer.Success(stderr, "No stale entries in known_hosts.")
+		return nil
+	}
+	if op.DryRun {
+		return nil
+	}
+	if !op.Yes {
+		if !isatty.IsTerminal(os.Stdin.Fd()) {
+			return errors.New("refusing to prune known_hosts without confirmation, pass --yes")
+		}
+		_, _ = fmt.Fprintf(stderr, "%s Remove these %d entries of hosts not in ssh_config? [y/N] ", printer.WarningColor.Sprint("?"), count)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			return nil
+		}
+	}
+	for f, entries := range stale {
+		if err := f.Remove(entries); err != nil {
+			return err
+		}
+		_ = printer.Success(stderr, "Removed %d entries from %s, the previous contents are in %s.old.",
+			len(entries), tildePath(f.Path), tildePath(f.Path))
+	}
+	return nil
+}
+
+// forgetHost removes the entries of a host from known_hosts, e.g. after it was rebuilt.
+func forgetHost(stdout, stderr io.Writer, sc *sshconfig.SSHConfig, files map[string]*knownhosts.File, target string) error {
+	h, err := sc.Lookup(target)
+	names := []string{target}
+	if err == nil {
+		names = []string{hostKeyName(sc, h), knownhosts.Normalize(h.Host, h.EffectivePort())}
+	} else {
+		h = sshconfig.Host{DisplayName: target, Host: target}
+	}
+
+	removed := 0
+	for _, path := range sc.UserKnownHostsFiles(h) {
+		f, err := loadKnownHosts(stderr, files, path)
+		if err != nil {
+			continue
+		}
+		var entries []knownhosts.Entry
+		for _, e := range f.Entries {
+			for _, name := range names {
+				if e.Marker == "" && e.MatchesExactly(name) {
+					entries = append(entries, e)
+					_, _ = fmt.Fprintln(stdout, knownHostsEntry{file: f, Entry: e})
+					break
+				}
+			}
+		}
+		if err := f.Remove(entries); err != nil {
+			return err
+		}
+		removed += len(entries)
+	}
+	if removed == 0 {
+		return errors.Errorf("no known_hosts entries for %s", target)
+	}
+	_ = printer.Success(stderr, "Removed %d entries of %s from known_hosts.", removed, target)
+	return nil
+}
+
+// loadKnownHosts reads a known_hosts file once. Missing files are skipped silently.
+func loadKnownHosts(stderr io.Writer, files map[string]*knownhosts.File, path string) (*knownhosts.File, error) {
+	if f, ok := files[path]; ok {
+		return f, nil
+	}
+	f, err := knownhosts.Load(path)
+	if err != nil {
+		if !cmdutil.IsNotFoundErr(err) {
+			_ = printer.Warning(stderr, "%v", err)
+		}
+		return nil, err
+	}
+	files[path] = f
+	return f, nil
+}
+
+// hostKeyName returns the name ssh(1) looks up the key of the host with in
+// known_hosts: its HostKeyAlias, or its Hostname and port.
+func hostKeyName(sc *sshconfig.SSHConfig, h sshconfig.Host) string {
+	if alias := sc.Option(h, "HostKeyAlias"); alias != "" {
+		return alias
+	}
+	return knownhosts.Normalize(h.Host, h.EffectivePort())
+}
+
+// configuredHostKeyNames returns the names of all hosts and their jump hosts in known_hosts.
+func configuredHostKeyNames(sc *sshconfig.SSHConfig) []string {
+	var names []string
+	for _, h := range sc.Hosts {
+		names = append(names, hostKeyName(sc, h))
+		cfg, err := sshclient.Resolve(sc, h)
+		if err != nil {
+			continue
+		}
+		for _, j := range cfg.Jumps {
+			if j.HostKeyAlias != "" {
+				names = append(names, j.HostKeyAlias)
+				continue
+			}
+			host, port, err := net.SplitHostPort(j.Addr)
+			if err != nil {
+				continue
+			}
+			n, _ := strconv.Atoi(port)
+			names = append(names, knownhosts.Normalize(host, n))
+		}
+	}
+	return names
+}
+
+// changedHostKey returns the host whose key changed when connecting to h
+// failed, h itself or one of its jump hosts, or "" if no key changed.
+func changedHostKey(h sshconfig.Host) string {
+	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.DefaultLoader)
+
+	defer func(sshConfig *sshconfig.SSHConfig) {
+		_ = sshConfig.Close()
+	}(sc)
+
+	if err := sc.Parse(); err != nil {
+		return ""
+	}
+	cfg, err := sshclient.Resolve(sc, h)
+	if err != nil {
+		return ""
+	}
+	for _, hop := range append(append([]*sshclient.Config{}, cfg.Jumps...), cfg) {
+		if hop.Timeout == 0 || hop.Timeout > hostKeyCheckTimeout {
+			hop.Timeout = hostKeyCheckTimeout
+		}
+	}
+	// the agent may be needed to authenticate to jump hosts
+	var keys agent.Agent
+	if c, err := sshagent.Dial(); err == nil {
+		defer func() { _ = c.Close() }()
+		keys = c.Agent()
+	}
+	var hostKeyErr *sshclient.HostKeyError
+	if errors.As(sshclient.VerifyHostKey(cfg, keys), &hostKeyErr) && hostKeyErr.Changed {
+		return hostKeyErr.Host
+	}
+	return ""
+}
+
+// forgetHint tells how to accept the new key of a host that was rebuilt.
+func forgetHint(host string) string {
+	return fmt.Sprintf("The host key of %s changed. If it was rebuilt, run `%s known-hosts forget %s` and connect again.",
+		host, selfName(), host)
+}
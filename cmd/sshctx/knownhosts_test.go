@@ -0,0 +1,121 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// setupKnownHosts writes an sshconfig and a known_hosts file with a line per
+// name to a new home directory and returns the path of known_hosts.
+func setupKnownHosts(t *testing.T, config string, names ...string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("SSHCTX", "")
+	if err := os.MkdirAll(filepath.Join(home, ".ssh"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(home, ".ssh", "config")
+	if err := os.WriteFile(configPath, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SSHCONFIG", configPath)
+
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	key, _ := ssh.NewPublicKey(pub)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(knownhosts.Line([]string{name}, key) + "\n")
+	}
+	path := filepath.Join(home, ".ssh", "known_hosts")
+	if err := os.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const knownHostsTestConfig = `Host web
+    Hostname 10.0.0.1
+    ProxyJump bastion.example.com
+
+Host db
+    Hostname 10.0.0.2
+    Port 2222
+`
+
+func TestKnownHostsOp_prune(t *testing.T) {
+	path := setupKnownHosts(t, knownHostsTestConfig,
+		"10.0.0.1", "[10.0.0.2]:2222", "bastion.example.com", "10.0.0.2", knownhosts.HashHostname("old.example.com"), "*.example.com")
+
+	var stdout, stderr bytes.Buffer
+	if err := (KnownHostsOp{Action: "prune", DryRun: true}).Run(&stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(stdout.String(), "\n"); got != 1 || !strings.Contains(stdout.String(), ":4 10.0.0.2 ") ||
+		!strings.Contains(stderr.String(), "kept 1 hashed entries") {
+		t.Errorf("prune --dry-run listed:\n%s\nwant the entry of 10.0.0.2 on port 22 and to keep old.example.com", stdout.String())
+	}
+
+	stdout.Reset()
+	if err := (KnownHostsOp{Action: "prune", DryRun: true, Hashed: true}).Run(&stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(stdout.String(), "\n"); got != 2 || !strings.Contains(stdout.String(), ":5 (hashed) ") {
+		t.Errorf("prune --dry-run --hashed listed:\n%s\nwant the entries of 10.0.0.2 on port 22 and old.example.com", stdout.String())
+	}
+
+	if err := (KnownHostsOp{Action: "prune", Yes: true}).Run(&stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if got := strings.Count(string(data), "\n"); got != 5 {
+		t.Errorf("known_hosts after prune =\n%s", data)
+	}
+	if err := (KnownHostsOp{Action: "prune", Yes: true, Hashed: true}).Run(&stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(path)
+	if got := strings.Count(string(data), "\n"); got != 4 {
+		t.Errorf("known_hosts after prune --hashed =\n%s", data)
+	}
+}
+
+func TestKnownHostsOp_forget(t *testing.T) {
+	path := setupKnownHosts(t, knownHostsTestConfig, "10.0.0.1", "[10.0.0.2]:2222", knownhosts.HashHostname("[10.0.0.2]:2222"))
+
+	var stdout, stderr bytes.Buffer
+	if err := (KnownHostsOp{Action: "forget", Target: "db"}).Run(&stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 1 || !strings.HasPrefix(lines[0], "10.0.0.1 ") {
+		t.Errorf("known_hosts after forget db =\n%s", data)
+	}
+
+	if err := (KnownHostsOp{Action: "forget", Target: "db"}).Run(&stdout, &stderr); err == nil {
+		t.Error("forgetting a host without entries succeeded")
+	}
+}
@@ -15,21 +15,42 @@
 package main
 
 import (
+	"encoding/json"
 	"facette.io/natsort"
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/spencercjh/sshctx/internal/env"
 	"github.com/spencercjh/sshctx/internal/printer"
+	"github.com/spencercjh/sshctx/internal/probe"
 	"github.com/spencercjh/sshctx/internal/sshconfig"
+	"github.com/spencercjh/sshctx/internal/usage"
+	"gopkg.in/yaml.v3"
 	"io"
 	"os"
+	"regexp"
 	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Formats of `-o`, which like `--format` only list the hosts of sshconfig.
+const (
+	outputJSON = "json" // the hostView of every host
+	outputYAML = "yaml"
+	outputName = "name" // DisplayNames, one per line
+	outputWide = "wide" // aligned table
 )
 
 // ListOp describes listing contexts.
-type ListOp struct{}
+type ListOp struct {
+	WithStatus bool   // annotate each host with whether it's reachable
+	Output     string // one of the output formats, the picker lines if empty
+	formatOptions
+	filterOptions
+}
 
-func (op ListOp) Run(stdout, _ io.Writer) error {
+func (op ListOp) Run(stdout, stderr io.Writer) error {
 	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.DefaultLoader)
 
 	defer func(sshConfig *sshconfig.SSHConfig) {
@@ -44,6 +65,7 @@ func (op ListOp) Run(stdout, _ io.Writer) error {
 		return natsort.Compare(sc.Hosts[i].Host, sc.Hosts[j].Host)
 	})
 
+	var hosts []sshconfig.Host
 	for _, h := range sc.Hosts {
 		str := h.ToSSHParameter()
 		_, ok := os.LookupEnv(env.StrictMode)
@@ -51,11 +73,163 @@ func (op ListOp) Run(stdout, _ io.Writer) error {
 			_ = printer.Warning(stdout, "%s is an illegal ssh parameter", str)
 			continue
 		}
-		str = "💻: " + h.DisplayName + "#" + str
+		hosts = append(hosts, h)
+	}
+	hosts, err := op.apply(sc, hosts)
+	if err != nil {
+		return err
+	}
+	if op.Output != "" || op.formatted() {
+		var results []probe.Result
+		if op.WithStatus {
+			results = probeHosts(probe.New(defaultCheckTimeout), hosts)
+		}
+		views, err := hostViews(sc, hosts, results)
+		if err != nil {
+			return err
+		}
+		if op.Output != "" {
+			return printHosts(stdout, views, op.Output)
+		}
+		tmpl, err := op.template()
+		if err != nil {
+			return err
+		}
+		for _, v := range views {
+			if err := tmpl.execute(stdout, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	discovered, err := discoveredHosts(sc)
+	if err != nil {
+		return err
+	}
+	if discovered, err = op.apply(sc, discovered); err != nil {
+		return err
+	}
+	configured := len(hosts)
+	hosts = append(hosts, discovered...)
+	var results []probe.Result
+	if op.WithStatus {
+		results = probeHosts(probe.New(defaultCheckTimeout), hosts)
+	}
+
+	for i, h := range hosts {
+		icon := "💻"
+		if i >= configured {
+			icon = discoveredIcon
+		}
+		str := icon + ": " + h.DisplayName + "#" + h.ToSSHParameter()
 		if h == sc.PreviousHost {
 			str = printer.ActiveItemColor.Sprint(str)
 		}
+		if op.WithStatus {
+			str += " " + statusAnnotation(results[i])
+		}
 		_, _ = fmt.Fprintf(stdout, "%s\n", str)
 	}
+	targets, err := providerTargets(sc, stderr, op.filterOptions)
+	if err != nil {
+		return err
+	}
+	for _, t := range targets {
+		_, _ = fmt.Fprintf(stdout, "%s\n", providerItem(t))
+	}
 	return nil
 }
+
+// hostViews collects what is known about hosts. results are the
+// reachability of the hosts, if probed.
+func hostViews(sc *sshconfig.SSHConfig, hosts []sshconfig.Host, results []probe.Result) ([]hostView, error) {
+	source, err := sshconfig.GetSSHConfigPath()
+	if err != nil {
+		return nil, errors.Wrap(err, "Can't determine sshconfig path")
+	}
+	lastUsed, err := usage.Load()
+	if err != nil {
+		return nil, err
+	}
+	views := []hostView{}
+	for i, h := range hosts {
+		v := newHostView(sc, h, source, lastUsed)
+		if results != nil {
+			v = v.withStatus(results[i])
+		}
+		views = append(views, v)
+	}
+	return views, nil
+}
+
+// printHosts prints hosts in a machine-readable format, or a table for `wide`.
+func printHosts(stdout io.Writer, views []hostView, format string) error {
+	if format == outputName {
+		for _, v := range views {
+			if _, err := fmt.Fprintln(stdout, v.DisplayName); err != nil {
+				return errors.Wrap(err, "write error")
+			}
+		}
+		return nil
+	}
+
+	switch format {
+	case outputJSON:
+		data, err := json.MarshalIndent(views, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "can't marshal hosts")
+		}
+		_, err = fmt.Fprintf(stdout, "%s\n", data)
+		return errors.Wrap(err, "write error")
+	case outputYAML:
+		data, err := yaml.Marshal(views)
+		if err != nil {
+			return errors.Wrap(err, "can't marshal hosts")
+		}
+		_, err = stdout.Write(data)
+		return errors.Wrap(err, "write error")
+	case outputWide:
+		return printWide(stdout, views)
+	}
+	return errors.Errorf("unsupported output format '%s'", format)
+}
+
+// printWide prints the hosts as an aligned table without colors.
+func printWide(stdout io.Writer, views []hostView) error {
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	header := "NAME\tHOSTNAME\tUSER\tPORT\tTAGS\tLAST USED"
+	if len(views) > 0 && views[0].Status != nil {
+		header += "\tSTATUS"
+	}
+	_, _ = fmt.Fprintln(w, header)
+	for _, v := range views {
+		tags, lastUsed := "-", "-"
+		if len(v.Tags) > 0 {
+			tags = strings.Join(v.Tags, ",")
+		}
+		if v.LastUsed != nil {
+			lastUsed = v.LastUsed.Local().Format("2006-01-02 15:04")
+		}
+		line := fmt.Sprintf("%s\t%s\t%s\t%d\t%s\t%s", v.DisplayName, v.Host, v.User, v.Port, tags, lastUsed)
+		if v.Status != nil {
+			status := "down"
+			if v.Status.Up {
+				status = fmt.Sprintf("up %dms", v.Status.LatencyMS)
+			}
+			line += "\t" + status
+		}
+		_, _ = fmt.Fprintln(w, line)
+	}
+	return errors.Wrap(w.Flush(), "write error")
+}
+
+// statusAnnotationRegexp matches the annotation added by `--with-status` to a line of the list.
+var statusAnnotationRegexp = regexp.MustCompile(`\s+\[(up [^\]]*|down)\]$`)
+
+// statusAnnotation describes the reachability of a host in the list, e.g. `[up 12ms]`.
+func statusAnnotation(r probe.Result) string {
+	if !r.Up {
+		return printer.ErrorColor.Sprint("[down]")
+	}
+	return printer.SuccessColor.Sprintf("[up %s]", r.Latency.Round(time.Millisecond))
+}
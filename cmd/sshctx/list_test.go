@@ -0,0 +1,104 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spencercjh/sshctx/internal/usage"
+	"gopkg.in/yaml.v3"
+)
+
+const listTestConfig = `Host web
+    Hostname 10.0.0.1
+    User deploy
+    #sshctx: tags=prod, frontend
+
+Host db
+    Hostname 10.0.0.2
+    Port 2222
+    IdentityFile ~/.ssh/db
+`
+
+func TestListOp_output(t *testing.T) {
+	setupKnownHosts(t, listTestConfig)
+	t.Setenv("USER", "me")
+	used := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	if err := usage.Record("web", used); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := (ListOp{Output: outputJSON}).Run(&stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	var views []hostView
+	if err := json.Unmarshal(stdout.Bytes(), &views); err != nil {
+		t.Fatalf("invalid json %s: %v", stdout.String(), err)
+	}
+	if len(views) != 2 {
+		t.Fatalf("got %d hosts, want 2", len(views))
+	}
+	web, db := views[0], views[1]
+	if web.DisplayName != "web" || web.User != "deploy" || web.Port != 22 || web.Line != 1 ||
+		strings.Join(web.Tags, ",") != "prod,frontend" || web.LastUsed == nil || !web.LastUsed.Equal(used) ||
+		web.Source != os.Getenv("SSHCONFIG") {
+		t.Errorf("web = %+v", web)
+	}
+	if db.Port != 2222 || db.User != "me" || db.Line != 6 || db.LastUsed != nil || db.Options["identityfile"][0] != "~/.ssh/db" {
+		t.Errorf("db = %+v", db)
+	}
+
+	stdout.Reset()
+	if err := (ListOp{Output: outputYAML}).Run(&stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	views = nil
+	if err := yaml.Unmarshal(stdout.Bytes(), &views); err != nil || len(views) != 2 || views[0].Host != "10.0.0.1" {
+		t.Errorf("yaml output = %s, %v", stdout.String(), err)
+	}
+
+	stdout.Reset()
+	if err := (ListOp{Output: outputName}).Run(&stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "web\ndb\n" {
+		t.Errorf("name output = %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if err := (ListOp{Output: outputWide}).Run(&stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "NAME ") || !strings.Contains(lines[1], "prod,frontend") ||
+		strings.ContainsAny(stdout.String(), "\x1b💻") {
+		t.Errorf("wide output =\n%s", stdout.String())
+	}
+}
+
+func TestParseListArgs(t *testing.T) {
+	if op, ok := parseArgs([]string{"--with-status", "-o", "wide"}).(ListOp); !ok || !op.WithStatus || op.Output != outputWide {
+		t.Errorf("parseArgs(--with-status -o wide) = %#v", op)
+	}
+	if _, ok := parseArgs([]string{"-o", "xml"}).(UnsupportedOp); !ok {
+		t.Error("parseArgs(-o xml) accepted an unsupported format")
+	}
+}
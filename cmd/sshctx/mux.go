@@ -0,0 +1,203 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/printer"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+// MuxOp describes managing ControlMaster connections.
+type MuxOp struct {
+	Action string // ls, stop or warm
+	Target string // DisplayName for stop, selector for warm
+	All    bool   // stop all masters
+}
+
+// muxState is the ControlMaster state of a host.
+type muxState int
+
+const (
+	muxNoSocket muxState = iota
+	muxLive
+	muxStale
+)
+
+// master is the ControlMaster of a host.
+type master struct {
+	host sshconfig.Host
+	path string // expanded ControlPath
+}
+
+func (m master) args(extra ...string) []string {
+	args := append([]string{"-S", m.path}, extra...)
+	return append(args, m.host.ToSSHArgs()...)
+}
+
+// state checks the master with `ssh -O check` if its socket exists.
+func (m master) state() muxState {
+	if _, err := os.Stat(m.path); err != nil {
+		return muxNoSocket
+	}
+	cmd := exec.Command("ssh", m.args("-O", "check")...)
+	if err := cmd.Run(); err != nil {
+		return muxStale
+	}
+	return muxLive
+}
+
+func (op MuxOp) Run(stdout, stderr io.Writer) error {
+	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.DefaultLoader)
+
+	defer func(sshConfig *sshconfig.SSHConfig) {
+		_ = sshConfig.Close()
+	}(sc)
+
+	if err := sc.Parse(); err != nil {
+		return errors.Wrap(err, "sshconfig error")
+	}
+
+	switch op.Action {
+	case "ls":
+		return muxList(stdout, masters(sc, sc.Hosts))
+	case "stop":
+		hosts := sc.Hosts
+		if !op.All {
+			h, err := sc.Lookup(op.Target)
+			if err != nil {
+				return err
+			}
+			hosts = []sshconfig.Host{h}
+		}
+		return muxStop(stderr, masters(sc, hosts), op.All)
+	default:
+		hosts, err := sshconfig.Select(sc.Hosts, op.Target)
+		if err != nil {
+			return err
+		}
+		return muxWarm(stderr, sc, hosts)
+	}
+}
+
+// masters returns the ControlMaster of each host that has a ControlPath.
+func masters(sc *sshconfig.SSHConfig, hosts []sshconfig.Host) []master {
+	var ms []master
+	for _, h := range hosts {
+		p := sc.Option(h, "ControlPath")
+		if p == "" || strings.EqualFold(p, "none") {
+			continue
+		}
+		ms = append(ms, master{host: h, path: sc.ExpandTokens(h, p)})
+	}
+	return ms
+}
+
+func muxList(stdout io.Writer, ms []master) error {
+	if len(ms) == 0 {
+		return errors.New("no host has a ControlPath")
+	}
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "HOST\tCONTROLPATH\tSTATUS")
+	for _, m := range ms {
+		var status string
+		switch m.state() {
+		case muxLive:
+			status = printer.SuccessColor.Sprint("live")
+		case muxStale:
+			status = printer.ErrorColor.Sprint("stale")
+		default:
+			status = "-"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", m.host.DisplayName, m.path, status)
+	}
+	return w.Flush()
+}
+
+// muxStop closes live masters and removes stale sockets.
+func muxStop(stderr io.Writer, ms []master, all bool) error {
+	if len(ms) == 0 {
+		return errors.New("no ControlPath configured")
+	}
+	stopped := 0
+	for _, m := range ms {
+		switch m.state() {
+		case muxLive:
+			cmd := exec.Command("ssh", m.args("-O", "exit")...)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				return errors.Wrapf(err, "failed to stop master of %s: %s", m.host.DisplayName, strings.TrimSpace(string(out)))
+			}
+			stopped++
+			_ = printer.Success(stderr, "Stopped master of %s.", m.host.DisplayName)
+		case muxStale:
+			if err := os.Remove(m.path); err != nil {
+				return errors.Wrapf(err, "failed to remove stale socket of %s", m.host.DisplayName)
+			}
+			_ = printer.Success(stderr, "Removed stale socket %s.", m.path)
+		default:
+			if !all {
+				return fmt.Errorf("no master running for %s", m.host.DisplayName)
+			}
+		}
+	}
+	if all && stopped == 0 {
+		_ = printer.Success(stderr, "No master running.")
+	}
+	return nil
+}
+
+// muxWarm opens a master in the background for each host that doesn't have a live one.
+func muxWarm(stderr io.Writer, sc *sshconfig.SSHConfig, hosts []sshconfig.Host) error {
+	ms := masters(sc, hosts)
+	if len(ms) == 0 {
+		return errors.New("no ControlPath configured for the selected hosts")
+	}
+	var failed []string
+	for _, m := range ms {
+		if m.state() == muxLive {
+			_ = printer.Success(stderr, "Master of %s is already running.", m.host.DisplayName)
+			continue
+		}
+		// a stale socket would make ssh refuse to create the master
+		_ = os.Remove(m.path)
+
+		persist := sc.Option(m.host, "ControlPersist")
+		if persist == "" || strings.EqualFold(persist, "no") {
+			persist = "yes"
+		}
+		cmd := exec.Command("ssh", m.args("-f", "-N", "-o", "ControlMaster=yes", "-o", "ControlPersist="+persist)...)
+		// authentication may need the terminal
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = stderr
+		cmd.Stderr = stderr
+		if err := cmd.Run(); err != nil {
+			failed = append(failed, m.host.DisplayName)
+			_ = printer.Error(stderr, "failed to open master of %s: %v", m.host.DisplayName, err)
+			continue
+		}
+		_ = printer.Success(stderr, "Opened master of %s.", printer.SuccessColor.Sprint(m.host.DisplayName))
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("failed to open masters of: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -22,7 +22,10 @@ import (
 	"github.com/pkg/errors"
 )
 
-type PreviousOp struct{}
+// PreviousOp describes showing the previous successfully connected host.
+type PreviousOp struct {
+	formatOptions
+}
 
 func (op PreviousOp) Run(stdout, _ io.Writer) error {
 	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.DefaultLoader)
@@ -37,6 +40,17 @@ func (op PreviousOp) Run(stdout, _ io.Writer) error {
 	if sc.PreviousHost == sshconfig.EmptyHost {
 		return errors.New("No previous host in sshctx")
 	}
+	if op.formatted() {
+		tmpl, err := op.template()
+		if err != nil {
+			return err
+		}
+		views, err := hostViews(sc, []sshconfig.Host{sc.PreviousHost}, nil)
+		if err != nil {
+			return err
+		}
+		return tmpl.execute(stdout, views[0])
+	}
 	_ = printer.Success(stdout, "Previous host: %s#%s", sc.PreviousHost.DisplayName, sc.PreviousHost.ToSSHParameter())
 	return nil
 }
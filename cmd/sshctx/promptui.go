@@ -27,7 +27,9 @@ import (
 	"strings"
 )
 
-type PromptuiOp struct{}
+type PromptuiOp struct {
+	filterOptions
+}
 
 func (op PromptuiOp) Run(stdout, stderr io.Writer) error {
 	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.DefaultLoader)
@@ -44,8 +46,12 @@ func (op PromptuiOp) Run(stdout, stderr io.Writer) error {
 		return natsort.Compare(sc.Hosts[i].Host, sc.Hosts[j].Host)
 	})
 
+	hosts, err := op.apply(sc, sc.Hosts)
+	if err != nil {
+		return err
+	}
 	items := []string{}
-	for _, h := range sc.Hosts {
+	for _, h := range hosts {
 		str := h.ToSSHParameter()
 		_, ok := os.LookupEnv(env.StrictMode)
 		if ok && !env.SSHParameterRegexp.MatchString(str) {
@@ -58,6 +64,27 @@ func (op PromptuiOp) Run(stdout, stderr io.Writer) error {
 		}
 		items = append(items, str)
 	}
+	discovered, err := discoveredHosts(sc)
+	if err != nil {
+		return err
+	}
+	if discovered, err = op.apply(sc, discovered); err != nil {
+		return err
+	}
+	for _, h := range discovered {
+		str := discoveredIcon + ": " + h.DisplayName + "#" + h.ToSSHParameter()
+		if h == sc.PreviousHost {
+			str = printer.ActiveItemColor.Sprint(str)
+		}
+		items = append(items, str)
+	}
+	targets, err := providerTargets(sc, stderr, op.filterOptions)
+	if err != nil {
+		return err
+	}
+	for _, t := range targets {
+		items = append(items, providerItem(t))
+	}
 
 	prompt := promptui.Select{
 		Label: "Select a host to connect",
@@ -72,7 +99,7 @@ func (op PromptuiOp) Run(stdout, stderr io.Writer) error {
 	if choice == "" {
 		return errors.New("you did not choose any of the options")
 	}
-	displayName, sshPara, err := connectTarget(choice, stderr)
+	displayName, sshPara, err := connectTarget(choice, connectOptions{}, stderr)
 	if err != nil {
 		return errors.Wrap(err, "failed to switch host")
 	}
@@ -0,0 +1,278 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/cmdutil"
+	"github.com/spencercjh/sshctx/internal/printer"
+	"github.com/spencercjh/sshctx/internal/provider"
+	"github.com/spencercjh/sshctx/internal/settings"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+// Icons marking the machines and containers of the providers in the list.
+const (
+	vagrantIcon = "📦"
+	dockerIcon  = "🐳"
+)
+
+// providerTargets returns the machines and containers of the providers
+// enabled in settings that match the filter. A provider that fails is left
+// out. Listing saves the ssh_config of each Vagrant project that changed, so
+// that a line of the list can be connected to later by another sshctx.
+func providerTargets(sc *sshconfig.SSHConfig, stderr io.Writer, opts filterOptions) ([]provider.Target, error) {
+	s, err := settings.Load()
+	if err != nil {
+		return nil, err
+	}
+	projects, err := vagrantProjects(s)
+	if err != nil {
+		return nil, err
+	}
+	var targets []provider.Target
+	for _, p := range projects {
+		machines, err := provider.VagrantMachines(p.dir)
+		if err != nil {
+			_ = printer.Warning(stderr, "%v", err)
+			continue
+		}
+		if len(machines) == 0 {
+			continue
+		}
+		if err := saveVagrantConfig(p.configPath, machines[0].Config); err != nil {
+			return nil, err
+		}
+		for i := range machines {
+			machines[i].Project = p.name
+		}
+		targets = append(targets, machines...)
+	}
+	if s.Providers.Docker {
+		containers, err := provider.DockerContainers()
+		if err != nil {
+			_ = printer.Warning(stderr, "%v", err)
+		}
+		targets = append(targets, containers...)
+	}
+	if !opts.filtered() {
+		return targets, nil
+	}
+
+	hosts := make([]sshconfig.Host, len(targets))
+	for i, t := range targets {
+		hosts[i] = sshconfig.Host{DisplayName: t.DisplayName(), Host: t.Host, Username: t.User, Port: t.Port}
+		if t.Provider == provider.Docker {
+			hosts[i].Host = t.ID
+		}
+	}
+	matched, err := opts.apply(sc, hosts)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{}
+	for _, h := range matched {
+		names[h.DisplayName] = true
+	}
+	var filtered []provider.Target
+	for _, t := range targets {
+		if names[t.DisplayName()] {
+			filtered = append(filtered, t)
+		}
+	}
+	return filtered, nil
+}
+
+// vagrantProject is a Vagrant project of the settings.
+type vagrantProject struct {
+	dir        string // absolute directory of the project
+	name       string // name in the list, unique among the projects
+	configPath string // where its ssh_config is saved
+}
+
+// vagrantProjects returns the Vagrant projects of the settings. They are named
+// after their directory, with as many parent directories as it takes to tell
+// apart projects in directories of the same name, e.g. `work/app` and `src/app`.
+func vagrantProjects(s *settings.Settings) ([]vagrantProject, error) {
+	dataDir, err := sshconfig.GetSSHCtxDataDir()
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	seen := map[string]bool{}
+	for _, dir := range s.Providers.Vagrant {
+		if dir == "~" || strings.HasPrefix(dir, "~/") {
+			dir = cmdutil.HomeDir() + dir[1:]
+		}
+		if dir, err = filepath.Abs(dir); err != nil {
+			return nil, errors.Wrap(err, "can't determine vagrant project path")
+		}
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	depths := make([]int, len(dirs))
+	names := make([]string, len(dirs))
+	for {
+		for i, dir := range dirs {
+			names[i] = lastPathElems(dir, depths[i]+1)
+		}
+		count := map[string]int{}
+		for _, name := range names {
+			count[name]++
+		}
+		grown := false
+		for i, name := range names {
+			if count[name] > 1 && names[i] != filepath.ToSlash(dirs[i]) {
+				depths[i]++
+				grown = true
+			}
+		}
+		if !grown {
+			break
+		}
+	}
+
+	projects := make([]vagrantProject, len(dirs))
+	for i, dir := range dirs {
+		sum := sha256.Sum256([]byte(dir))
+		file := filepath.Base(dir) + "-" + hex.EncodeToString(sum[:8]) + ".config"
+		projects[i] = vagrantProject{dir: dir, name: names[i], configPath: filepath.Join(dataDir, "vagrant", file)}
+	}
+	return projects, nil
+}
+
+// lastPathElems returns the last n elements of path joined with slashes.
+func lastPathElems(path string, n int) string {
+	elems := strings.Split(filepath.ToSlash(path), "/")
+	if n > len(elems) {
+		n = len(elems)
+	}
+	return strings.Join(elems[len(elems)-n:], "/")
+}
+
+// saveVagrantConfig writes the ssh_config of a Vagrant project to path unless it's already there.
+func saveVagrantConfig(path, config string) error {
+	if data, err := os.ReadFile(path); err == nil && string(data) == config {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.Wrap(err, "can't create vagrant config dir")
+	}
+	return errors.Wrap(cmdutil.WriteFileAtomic(path, []byte(config), 0600), "can't save vagrant ssh-config")
+}
+
+// providerItem returns the line of the list for a target,
+// `📦: project/machine#user@host -p port` or `🐳: name#container-id`.
+func providerItem(t provider.Target) string {
+	if t.Provider == provider.Docker {
+		return dockerIcon + ": " + t.Name + "#" + t.ID
+	}
+	h := sshconfig.Host{Host: t.Host, Username: t.User, Port: t.Port}
+	return vagrantIcon + ": " + t.DisplayName() + "#" + h.ToSSHParameter()
+}
+
+// isProviderItem returns whether target is a line of the list for a
+// machine or container of a provider.
+func isProviderItem(target string) bool {
+	return strings.HasPrefix(target, vagrantIcon) || strings.HasPrefix(target, dockerIcon)
+}
+
+// providerCommand returns the name and the command line opening a shell on
+// the target of a line of the list.
+func providerCommand(target string) (string, []string, error) {
+	displayName, ref, err := extract(target)
+	if err != nil {
+		return "", nil, err
+	}
+	if strings.HasPrefix(target, dockerIcon) {
+		return displayName, provider.Target{Provider: provider.Docker, ID: ref}.Command(""), nil
+	}
+	i := strings.LastIndex(displayName, "/")
+	if i == -1 {
+		return "", nil, fmt.Errorf("invalid vagrant machine: %s", displayName)
+	}
+	s, err := settings.Load()
+	if err != nil {
+		return "", nil, err
+	}
+	projects, err := vagrantProjects(s)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, p := range projects {
+		if p.name != displayName[:i] {
+			continue
+		}
+		if _, err := os.Stat(p.configPath); err != nil {
+			return "", nil, errors.Wrapf(err, "no ssh-config saved for vagrant project %s", p.name)
+		}
+		return displayName, provider.Target{Provider: provider.Vagrant, Name: displayName[i+1:]}.Command(p.configPath), nil
+	}
+	return "", nil, fmt.Errorf("no vagrant project %s in settings", displayName[:i])
+}
+
+// providerHost returns the host passed to the hooks for a line of the list.
+// Containers only have a name.
+func providerHost(target string) (sshconfig.Host, error) {
+	displayName, ref, err := extract(target)
+	if err != nil {
+		return sshconfig.EmptyHost, err
+	}
+	if strings.HasPrefix(target, dockerIcon) {
+		return sshconfig.Host{DisplayName: displayName}, nil
+	}
+	return hostFromSSHParameter(displayName, ref)
+}
+
+// connectProviderTarget opens a shell on the machine or container of a line
+// of the list and returns its name. Hooks run like for any other host.
+func connectProviderTarget(target string, opts connectOptions, stderr io.Writer) (string, error) {
+	displayName, argv, err := providerCommand(target)
+	if err != nil {
+		return "", err
+	}
+	h, err := providerHost(target)
+	if err != nil {
+		return "", err
+	}
+	s, err := settings.Load()
+	if err != nil {
+		return "", err
+	}
+	pre, post, err := s.HooksFor(h)
+	if err != nil {
+		return "", err
+	}
+	if err := runPreConnect(stderr, pre, h); err != nil {
+		return "", err
+	}
+	_ = printer.Success(stderr, "Switched to target %s.", printer.SuccessColor.Sprint(displayName))
+	start := time.Now()
+	err = runClient(stderr, displayName, argv, opts.Record || opts.RecordInput, opts.RecordInput, opts)
+	runPostConnect(stderr, post, h, err, start)
+	return displayName, err
+}
@@ -0,0 +1,163 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/spencercjh/sshctx/internal/settings"
+)
+
+// setupProviders enables the providers in settings with fake vagrant and
+// docker commands, returning the directory of the Vagrant project.
+func setupProviders(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake commands are shell scripts")
+	}
+	setupKnownHosts(t, knownHostsTestConfig)
+	t.Setenv("SSHCTX_SETTINGS", "")
+	home := os.Getenv("HOME")
+	project := filepath.Join(home, "vms", "lab")
+	bin := filepath.Join(home, "bin")
+	for _, dir := range []string{project, bin, filepath.Join(home, ".sshctx")} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		filepath.Join(home, ".sshctx", "settings.yaml"): "providers:\n  vagrant: [~/vms/lab]\n  docker: true\n",
+		filepath.Join(bin, "vagrant"):                   "#!/bin/sh\nprintf 'Host default\\n  HostName 127.0.0.1\\n  User vagrant\\n  Port 2222\\n'\n",
+		filepath.Join(bin, "docker"):                    "#!/bin/sh\necho '{\"ID\":\"3f2a1b9c0d12\",\"Names\":\"web-1\"}'\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return project
+}
+
+func TestListOp_providers(t *testing.T) {
+	setupProviders(t)
+
+	var stdout, stderr bytes.Buffer
+	if err := (ListOp{}).Run(&stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	vagrantItem, dockerItem := "📦: lab/default#vagrant@127.0.0.1 -p 2222", "🐳: web-1#3f2a1b9c0d12"
+	if lines := strings.Split(strings.TrimSpace(stdout.String()), "\n"); len(lines) != 4 ||
+		lines[2] != vagrantItem || lines[3] != dockerItem {
+		t.Errorf("output = %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if err := (ListOp{filterOptions: filterOptions{Filter: "name=web*"}}).Run(&stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(stdout.String(), "\n"+dockerItem+"\n") || strings.Contains(stdout.String(), vagrantItem) {
+		t.Errorf("filtered output = %q", stdout.String())
+	}
+
+	// machines connect through the ssh-config vagrant generated
+	configs, _ := filepath.Glob(filepath.Join(os.Getenv("HOME"), ".sshctx", "vagrant", "lab-*.config"))
+	if len(configs) != 1 {
+		t.Fatalf("saved ssh-configs = %q, want one", configs)
+	}
+	config := configs[0]
+	if data, err := os.ReadFile(config); err != nil || !strings.HasPrefix(string(data), "Host default\n") {
+		t.Errorf("saved ssh-config = %q, %v", data, err)
+	}
+	for item, want := range map[string]string{
+		vagrantItem: "ssh -F " + config + " -t -t default\n",
+		dockerItem:  "docker exec -it 3f2a1b9c0d12 sh\n",
+	} {
+		stdout.Reset()
+		if err := printClientCommand(&stdout, item); err != nil {
+			t.Fatal(err)
+		}
+		if stdout.String() != want {
+			t.Errorf("printClientCommand(%q) = %q, want %q", item, stdout.String(), want)
+		}
+	}
+	if _, err := resolveTarget(dockerItem); err == nil {
+		t.Error("resolveTarget() resolved a container to an ssh host")
+	}
+}
+
+func TestSwitchOp_providerHooks(t *testing.T) {
+	setupProviders(t)
+	home := os.Getenv("HOME")
+	log := filepath.Join(home, "hooks.log")
+	settings := "providers:\n  docker: true\nhooks:\n" +
+		"  - pre_connect: echo \"$SSHCTX_HOOK $SSHCTX_HOST\" >> " + log + "\n" +
+		"    post_connect: echo \"$SSHCTX_HOOK $SSHCTX_HOST $SSHCTX_EXIT_CODE\" >> " + log + "\n"
+	if err := os.WriteFile(filepath.Join(home, ".sshctx", "settings.yaml"), []byte(settings), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := (SwitchOp{Target: "🐳: web-1#3f2a1b9c0d12"}).Run(&stdout, &stderr); err != nil {
+		t.Fatalf("Run() error = %v, stderr = %s", err, stderr.String())
+	}
+	data, err := os.ReadFile(log)
+	if want := "pre_connect web-1\npost_connect web-1 0\n"; err != nil || string(data) != want {
+		t.Errorf("hooks ran %q, %v, want %q", data, err, want)
+	}
+
+	// the exit of a failed shell is passed to the hooks and returned
+	if err := os.WriteFile(filepath.Join(home, "bin", "docker"), []byte("#!/bin/sh\nexit 3\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := (SwitchOp{Target: "🐳: web-1#3f2a1b9c0d12"}).Run(&stdout, &stderr); err == nil {
+		t.Error("Run() should fail when docker exec fails")
+	}
+	data, _ = os.ReadFile(log)
+	if !strings.HasSuffix(string(data), "post_connect web-1 3\n") {
+		t.Errorf("hooks ran %q, want the exit code 3", data)
+	}
+}
+
+func TestVagrantProjects(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	s := &settings.Settings{}
+	s.Providers.Vagrant = []string{"~/work/app", "~/src/app", "~/vms/lab", "~/work/app"}
+
+	projects, err := vagrantProjects(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	paths := map[string]bool{}
+	for _, p := range projects {
+		names = append(names, p.name)
+		paths[p.configPath] = true
+	}
+	if want := []string{"work/app", "src/app", "lab"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %q, want %q", names, want)
+	}
+	if len(paths) != 3 {
+		t.Errorf("config paths = %v, want one per project", paths)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/cmdutil"
+	"github.com/spencercjh/sshctx/internal/printer"
+)
+
+const (
+	// defaultReconnectAttempts is how many times `--reconnect` reconnects when no `--attempts` is given.
+	defaultReconnectAttempts = 5
+	// sshNetworkErrorExitCode is the exit code of ssh when the connection itself failed.
+	sshNetworkErrorExitCode = 255
+)
+
+// connectWithReconnect runs connect and runs it again with exponential
+// backoff while ssh fails with a network error, until Ctrl-C is pressed.
+func connectWithReconnect(stderr io.Writer, displayName string, attempts int, connect func() error) error {
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	defer signal.Stop(interrupted)
+
+	if attempts == 0 {
+		attempts = defaultReconnectAttempts
+	}
+	backoff := &cmdutil.Backoff{Initial: time.Second, Max: 30 * time.Second}
+	return reconnectLoop(stderr, displayName, attempts, backoff, interrupted, connect)
+}
+
+// reconnectLoop calls connect until it doesn't fail with a network error or
+// attempts reconnects were made. It stops waiting for the next attempt as
+// soon as something is received from interrupted.
+func reconnectLoop(stderr io.Writer, displayName string, attempts int, backoff *cmdutil.Backoff,
+	interrupted <-chan os.Signal, connect func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := connect()
+		select {
+		case <-interrupted:
+			return err
+		default:
+		}
+		if code, ok := exitCode(err); !ok || code != sshNetworkErrorExitCode {
+			return err
+		}
+		if attempt == attempts {
+			return errors.Wrapf(err, "gave up after %d reconnects", attempts)
+		}
+
+		delay := backoff.Next()
+		_, _ = fmt.Fprintf(stderr, "%s connection to %s lost, attempt %d/%d in %s\n",
+			printer.WarningColor.Sprint("reconnecting:"), displayName, attempt+1, attempts, delay)
+		select {
+		case <-interrupted:
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// exitCode returns the exit code of the process that returned err. It
+// returns false when the process didn't exit by itself, e.g. it couldn't
+// be started or was killed by a signal.
+func exitCode(err error) (int, bool) {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 0, false
+	}
+	code := exitErr.ExitCode()
+	return code, code >= 0
+}
+
+// exitCodeOf returns the exit code of the process that returned err:
+// 0 for no error and -1 if the process didn't exit by itself.
+func exitCodeOf(err error) int {
+	if err == nil {
+		return 0
+	}
+	if code, ok := exitCode(err); ok {
+		return code
+	}
+	return -1
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spencercjh/sshctx/internal/cmdutil"
+)
+
+func TestReconnectLoop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	// exits returns a connect func whose ssh exits with the given codes in turn.
+	exits := func(codes ...string) (func() error, *int) {
+		calls := new(int)
+		return func() error {
+			code := codes[*calls]
+			*calls++
+			return exec.Command("sh", "-c", "exit "+code).Run()
+		}, calls
+	}
+	backoff := func() *cmdutil.Backoff {
+		return &cmdutil.Backoff{Initial: time.Millisecond, Max: 2 * time.Millisecond}
+	}
+
+	tests := []struct {
+		name      string
+		codes     []string
+		attempts  int
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "clean-exit", codes: []string{"0"}, attempts: 3, wantCalls: 1},
+		{name: "remote-exit-code", codes: []string{"1"}, attempts: 3, wantCalls: 1, wantErr: true},
+		{name: "recovers", codes: []string{"255", "255", "0"}, attempts: 3, wantCalls: 3},
+		{name: "gives-up", codes: []string{"255", "255", "255"}, attempts: 2, wantCalls: 3, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connect, calls := exits(tt.codes...)
+			var stderr bytes.Buffer
+			err := reconnectLoop(&stderr, "test", tt.attempts, backoff(), make(chan os.Signal), connect)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("reconnectLoop() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if *calls != tt.wantCalls {
+				t.Errorf("connected %d times, want %d", *calls, tt.wantCalls)
+			}
+			if got := strings.Count(stderr.String(), "reconnecting:"); got != tt.wantCalls-1 {
+				t.Errorf("logged %d attempts, want %d:\n%s", got, tt.wantCalls-1, stderr.String())
+			}
+		})
+	}
+
+	t.Run("interrupted", func(t *testing.T) {
+		connect, calls := exits("255", "255")
+		interrupted := make(chan os.Signal, 1)
+		interrupted <- os.Interrupt
+		err := reconnectLoop(new(bytes.Buffer), "test", 3, backoff(), interrupted, connect)
+		if err == nil || *calls != 1 {
+			t.Errorf("reconnectLoop() = %v after %d connects, want to stop after the first", err, *calls)
+		}
+	})
+}
@@ -0,0 +1,135 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"text/tabwriter"
+	"time"
+
+	"github.com/creack/pty"
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/printer"
+	"github.com/spencercjh/sshctx/internal/recording"
+	"golang.org/x/term"
+)
+
+// RecordingsOp describes listing or replaying recorded sessions.
+type RecordingsOp struct {
+	Action string // ls or play
+	Target string // host for ls, recording file or host for play
+}
+
+func (op RecordingsOp) Run(stdout, _ io.Writer) error {
+	dir, err := recording.Dir()
+	if err != nil {
+		return errors.Wrap(err, "Can't determine recordings directory")
+	}
+	if op.Action == "play" {
+		return playRecording(stdout, dir, op.Target)
+	}
+
+	entries, err := recording.List(dir, op.Target)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "HOST\tSTARTED\tDURATION\tFILE")
+	for _, e := range entries {
+		duration := "-"
+		if d, err := e.Duration(); err == nil {
+			duration = d.String()
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Host, e.Start.Format("2006-01-02 15:04:05"), duration, e.Path)
+	}
+	return w.Flush()
+}
+
+// playRecording replays the recording file target, or the latest recording
+// of the host target.
+func playRecording(stdout io.Writer, dir, target string) error {
+	path := target
+	if _, err := os.Stat(path); err != nil {
+		entries, err := recording.List(dir, target)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return errors.Errorf("no recording file or recordings of host: %s", target)
+		}
+		path = entries[len(entries)-1].Path
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open recording")
+	}
+	defer func() { _ = f.Close() }()
+	return recording.Play(stdout, f, time.Sleep)
+}
+
+// runRecorded runs cmd on a pseudo-terminal and records the session of host
+// to a new file in the recordings directory.
+func runRecorded(stderr io.Writer, host string, cmd *exec.Cmd, recordInput bool) error {
+	dir, err := recording.Dir()
+	if err != nil {
+		return errors.Wrap(err, "Can't determine recordings directory")
+	}
+	f, err := recording.Create(dir, host, time.Now())
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	stdinFd := int(os.Stdin.Fd())
+	size := &pty.Winsize{Cols: 80, Rows: 24}
+	if cols, rows, err := term.GetSize(stdinFd); err == nil {
+		size = &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)}
+	}
+	rec, err := recording.NewWriter(f, recording.Header{
+		Width:     int(size.Cols),
+		Height:    int(size.Rows),
+		Timestamp: time.Now().Unix(),
+		Title:     host,
+		Env:       map[string]string{"TERM": os.Getenv("TERM"), "SHELL": os.Getenv("SHELL")},
+	})
+	if err != nil {
+		return err
+	}
+	s, err := recording.Start(cmd, rec, recordInput, size)
+	if err != nil {
+		return err
+	}
+
+	_ = printer.Success(stderr, "Recording session to %s.", f.Name())
+	if term.IsTerminal(stdinFd) {
+		state, err := term.MakeRaw(stdinFd)
+		if err != nil {
+			return errors.Wrap(err, "failed to put terminal into raw mode")
+		}
+		defer func() {
+			_ = term.Restore(stdinFd, state)
+		}()
+	}
+	stop := notifyResize(func() {
+		if cols, rows, err := term.GetSize(stdinFd); err == nil {
+			_ = s.Resize(&pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)})
+		}
+	})
+	defer stop()
+	return s.Run(os.Stdin, os.Stdout)
+}
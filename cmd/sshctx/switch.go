@@ -17,32 +17,58 @@ package main
 import (
 	"fmt"
 	"github.com/pkg/errors"
-	"github.com/spencercjh/sshctx/internal/env"
+	"github.com/spencercjh/sshctx/internal/cmdutil"
+	"github.com/spencercjh/sshctx/internal/hooks"
 	"github.com/spencercjh/sshctx/internal/printer"
 	"github.com/spencercjh/sshctx/internal/sshconfig"
+	"github.com/spencercjh/sshctx/internal/usage"
 	"gopkg.in/yaml.v3"
 	"io"
-	"io/ioutil"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
-	"sync"
+	"time"
 )
 
 // SwitchOp indicates intention to switch contexts.
 type SwitchOp struct {
-	Target string // - or DisplayName or `💻: DisplayName#user@host`
+	Target      string // - or DisplayName or a line of the list like `💻: DisplayName#user@host`
+	Wait        bool   // wait until the host accepts SSH connections before connecting
+	WaitTimeout time.Duration
+	DryRun      bool   // print the command line instead of connecting
+	Tmux        string // connect in a tmux window, split or tiled panes, Target is a selector for tiled
+	Sync        bool   // synchronize the tiled tmux panes
+	connectOptions
+}
+
+// connectOptions change how the connection to a host is made.
+type connectOptions struct {
+	Reconnect         bool // reconnect when ssh exits because of a network error
+	ReconnectAttempts int
+	Record            bool // record the session under ~/.sshctx/recordings
+	RecordInput       bool // record what is typed too, implies Record
 }
 
 func (op SwitchOp) Run(stdout, stderr io.Writer) error {
+	if op.DryRun {
+		return printClientCommand(stdout, op.Target)
+	}
+	if op.Tmux != "" {
+		return openInTmux(stderr, op)
+	}
+	if op.Wait {
+		if err := waitForTarget(stderr, op.Target, op.WaitTimeout); err != nil {
+			return err
+		}
+	}
 	var displayName string
 	var sshPara string
 	var err error
 	if op.Target == "-" {
-		displayName, sshPara, err = connectPrevious(stderr)
+		displayName, sshPara, err = connectPrevious(op.connectOptions, stderr)
 	} else {
-		displayName, sshPara, err = connectTarget(op.Target, stderr)
+		displayName, sshPara, err = connectTarget(op.Target, op.connectOptions, stderr)
 	}
 	if err != nil {
 		return errors.Wrap(err, "failed to connect host")
@@ -53,29 +79,45 @@ func (op SwitchOp) Run(stdout, stderr io.Writer) error {
 	return nil
 }
 
-func deleteEmpty(s []string) []string {
-	var r []string
-	for _, str := range s {
-		if str != "" {
-			r = append(r, str)
+// hostFromSSHParameter parses `user@host -p port` back into a host. The user
+// and the port are optional; the host isn't checked like in STRICT_MODE.
+func hostFromSSHParameter(displayName, sshPara string) (sshconfig.Host, error) {
+	fields := strings.Fields(sshPara)
+	h := sshconfig.Host{DisplayName: displayName}
+	switch {
+	case len(fields) == 3 && fields[1] == "-p":
+		port, err := strconv.Atoi(fields[2])
+		if err != nil || port <= 0 {
+			return sshconfig.EmptyHost, fmt.Errorf("illegal SSH parameter: %s", sshPara)
 		}
+		h.Port = port
+	case len(fields) != 1:
+		return sshconfig.EmptyHost, fmt.Errorf("illegal SSH parameter: %s", sshPara)
+	}
+	h.Host = fields[0]
+	if i := strings.LastIndex(h.Host, "@"); i != -1 {
+		h.Username, h.Host = h.Host[:i], h.Host[i+1:]
+	}
+	if h.Host == "" {
+		return sshconfig.EmptyHost, fmt.Errorf("illegal SSH parameter: %s", sshPara)
 	}
-	return r
+	return h, nil
 }
 
+// savePreviousHost remembers the host connected to. Machines and containers
+// of providers have no sshPara and are only recorded as used.
 func savePreviousHost(stdin io.Writer, displayName, sshPara string) error {
-	matches := env.SSHParameterRegexp.FindStringSubmatch(sshPara)
-	matches = deleteEmpty(matches)
-	var host map[string]sshconfig.Host
-	switch {
-	case len(matches) == 5:
-		port, _ := strconv.Atoi(matches[4])
-		host = map[string]sshconfig.Host{"previous": {Host: matches[2], DisplayName: displayName, Username: matches[1], Port: port}}
-	case len(matches) == 3:
-		host = map[string]sshconfig.Host{"previous": {Host: matches[2], DisplayName: displayName, Username: matches[1]}}
-	default:
-		return fmt.Errorf("illegal SSH parameter: %s", sshPara)
+	if sshPara == "" {
+		if err := usage.Record(displayName, time.Now()); err != nil {
+			_ = printer.Warning(stdin, "%v", err)
+		}
+		return nil
 	}
+	h, err := hostFromSSHParameter(displayName, sshPara)
+	if err != nil {
+		return err
+	}
+	host := map[string]sshconfig.Host{"previous": h}
 
 	data, err := yaml.Marshal(&host)
 
@@ -84,15 +126,19 @@ func savePreviousHost(stdin io.Writer, displayName, sshPara string) error {
 	}
 
 	sshCtxDataPath, _ := sshconfig.GetSSHCtxDataPath()
-	if err := ioutil.WriteFile(sshCtxDataPath, data, 0600); err != nil {
+	if err := cmdutil.WriteFileAtomic(sshCtxDataPath, data, 0600); err != nil {
 		return errors.Wrap(err, "failed to write host to sshctxData file")
 	}
+	if err := usage.Record(displayName, time.Now()); err != nil {
+		_ = printer.Warning(stdin, "%v", err)
+	}
 	_ = printer.Success(stdin, "Saved previous host successfully: %v", host)
 	return nil
 }
 
-// extract displayName and sshPara from `💻: DisplayName#SSHPara`
+// extract displayName and sshPara from `💻: DisplayName#SSHPara` or `🔭: DisplayName#SSHPara`
 func extract(target string) (string, string, error) {
+	target = statusAnnotationRegexp.ReplaceAllString(target, "")
 	sshParaBeginIndex := strings.IndexAny(target, "#")
 	if sshParaBeginIndex == -1 {
 		return "", "", errors.New("invalid target")
@@ -104,22 +150,32 @@ func extract(target string) (string, string, error) {
 }
 
 // connectTarget
-func connectTarget(target string, stderr io.Writer) (string, string, error) {
+func connectTarget(target string, opts connectOptions, stderr io.Writer) (string, string, error) {
+	// sshctx 📦: project/machine#user@host or 🐳: name#container-id from LIST op
+	if isProviderItem(target) {
+		displayName, err := connectProviderTarget(target, opts, stderr)
+		return displayName, "", err
+	}
+
 	// sshctx DisplayName
-	if !strings.HasPrefix(target, "💻") {
-		return connectTargetWithDisplayNameOnly(target, stderr)
+	if !isListItem(target) {
+		return connectTargetWithDisplayNameOnly(target, opts, stderr)
 	}
 
-	// sshctx 💻: DisplayName#user@host from LIST op
+	// sshctx 💻: DisplayName#user@host or 🔭: DisplayName#user@host from LIST op
 	displayName, sshPara, err := extract(target)
 	if err != nil {
 		return "", "", err
 	}
-	return connectTargetWithDisplayNameAndSSHParameter(displayName, sshPara, stderr)
+	h, err := hostFromSSHParameter(displayName, sshPara)
+	if err != nil {
+		return "", "", err
+	}
+	return connectHost(h, opts, stderr)
 }
 
 // connectTargetWithDisplayNameOnly
-func connectTargetWithDisplayNameOnly(displayName string, stderr io.Writer) (string, string, error) {
+func connectTargetWithDisplayNameOnly(displayName string, opts connectOptions, stderr io.Writer) (string, string, error) {
 	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.DefaultLoader)
 
 	defer func(sshConfig *sshconfig.SSHConfig) {
@@ -138,31 +194,87 @@ func connectTargetWithDisplayNameOnly(displayName string, stderr io.Writer) (str
 	if targetHost == sshconfig.EmptyHost {
 		return "", "", fmt.Errorf("no config for host: %s", displayName)
 	}
-	return connectTargetWithDisplayNameAndSSHParameter(targetHost.DisplayName, targetHost.ToSSHParameter(), stderr)
+	return connectHost(targetHost, opts, stderr)
 }
 
-// connectTargetWithDisplayNameAndSSHParameter actual ssh cmd
-func connectTargetWithDisplayNameAndSSHParameter(displayName string, sshPara string, stderr io.Writer) (string, string, error) {
+// connectHost actual ssh cmd, returning the display name and the SSH parameter of the host
+func connectHost(h sshconfig.Host, opts connectOptions, stderr io.Writer) (string, string, error) {
+	displayName := h.DisplayName
+	conn, err := resolveConnection(h, opts)
+	if err != nil {
+		return "", "", err
+	}
+	if err := runPreConnect(stderr, conn.PreConnect, h); err != nil {
+		return "", "", err
+	}
+	for _, w := range conn.Warnings {
+		_, _ = fmt.Fprintf(stderr, "%s %s\n", printer.WarningColor.Sprint("warning:"), w)
+	}
+	ensureAgentKey(stderr, conn.IdentityFiles, conn.AgentLifetime)
 	_ = printer.Success(stderr, "Switched to target %s.", printer.SuccessColor.Sprint(displayName))
 
-	var waitGroup sync.WaitGroup
-	waitGroup.Add(1)
-	go func() {
-		cmd := exec.Command("ssh", "-t", "-t", sshPara)
+	start := time.Now()
+	err = runClient(stderr, displayName, conn.Argv, conn.Record, conn.RecordInput, opts)
+	if err != nil {
+		if code, ok := exitCode(err); ok && code == sshNetworkErrorExitCode {
+			if host := changedHostKey(h); host != "" {
+				_, _ = fmt.Fprintf(stderr, "%s %s\n", printer.WarningColor.Sprint("hint:"), forgetHint(host))
+			}
+		}
+	}
+	runPostConnect(stderr, conn.PostConnect, h, err, start)
+	return displayName, h.ToSSHParameter(), err
+}
+
+// runPreConnect runs the pre_connect hooks of h, a failing one aborts connecting.
+func runPreConnect(stderr io.Writer, commands []string, h sshconfig.Host) error {
+	if err := hooks.Run(commands, hooks.Env(hooks.PreConnect, h), os.Stdin, os.Stdout, stderr); err != nil {
+		return errors.Wrap(err, "aborted connecting")
+	}
+	return nil
+}
+
+// runPostConnect runs the post_connect hooks of h after a client started at
+// start exited with err.
+func runPostConnect(stderr io.Writer, commands []string, h sshconfig.Host, err error, start time.Time) {
+	if len(commands) == 0 {
+		return
+	}
+	env := append(hooks.Env(hooks.PostConnect, h),
+		"SSHCTX_EXIT_CODE="+strconv.Itoa(exitCodeOf(err)),
+		"SSHCTX_DURATION="+strconv.Itoa(int(time.Since(start).Seconds())))
+	if err := hooks.Run(commands, env, os.Stdin, os.Stdout, stderr); err != nil {
+		_ = printer.Error(stderr, "%v", err)
+	}
+}
+
+// runClient runs the command line connecting to a host in the terminal,
+// recording and reconnecting as asked.
+func runClient(stderr io.Writer, displayName string, argv []string, record, recordInput bool, opts connectOptions) error {
+	connect := func() error {
+		cmd := exec.Command(argv[0], argv[1:]...)
+		if record {
+			return runRecorded(stderr, displayName, cmd, recordInput)
+		}
 		cmd.Stdin = os.Stdin
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = stderr
-		if err := cmd.Run(); err != nil {
-			_ = printer.Error(stderr, "Failed to connect to target %s because: %v.", printer.ErrorColor.Sprint(displayName), err)
-		}
-		waitGroup.Done()
-	}()
-	waitGroup.Wait()
-	return displayName, sshPara, nil
+		return cmd.Run()
+	}
+	var err error
+	if opts.Reconnect {
+		err = connectWithReconnect(stderr, displayName, opts.ReconnectAttempts, connect)
+	} else {
+		err = connect()
+	}
+	if err != nil {
+		_ = printer.Error(stderr, "Failed to connect to target %s because: %v.", printer.ErrorColor.Sprint(displayName), err)
+	}
+	return err
 }
 
 // connectPrevious switches to previously connected host.
-func connectPrevious(stderr io.Writer) (string, string, error) {
+func connectPrevious(opts connectOptions, stderr io.Writer) (string, string, error) {
 	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.DefaultLoader)
 
 	defer func(sshConfig *sshconfig.SSHConfig) {
@@ -177,5 +289,58 @@ func connectPrevious(stderr io.Writer) (string, string, error) {
 		return "", "", errors.New("No previous host")
 	}
 
-	return connectTargetWithDisplayNameAndSSHParameter(sc.PreviousHost.DisplayName, sc.PreviousHost.ToSSHParameter(), stderr)
+	return connectHost(sc.PreviousHost, opts, stderr)
+}
+
+// printClientCommand prints the command line connecting to the target.
+func printClientCommand(stdout io.Writer, target string) error {
+	if isProviderItem(target) {
+		_, argv, err := providerCommand(target)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(stdout, cmdutil.ShellQuote(argv))
+		return err
+	}
+	h, err := resolveTarget(target)
+	if err != nil {
+		return errors.Wrap(err, "failed to resolve host")
+	}
+	conn, err := resolveConnection(h, connectOptions{})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, cmdutil.ShellQuote(conn.Argv))
+	return err
+}
+
+// resolveTarget finds the host a SwitchOp target refers to without connecting.
+func resolveTarget(target string) (sshconfig.Host, error) {
+	if strings.HasPrefix(target, dockerIcon) {
+		return sshconfig.EmptyHost, errors.New("containers aren't reached over ssh")
+	}
+	if isListItem(target) || isProviderItem(target) {
+		displayName, sshPara, err := extract(target)
+		if err != nil {
+			return sshconfig.EmptyHost, err
+		}
+		return hostFromSSHParameter(displayName, sshPara)
+	}
+
+	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.ReadOnlyLoader)
+
+	defer func(sshConfig *sshconfig.SSHConfig) {
+		_ = sshConfig.Close()
+	}(sc)
+
+	if err := sc.Parse(); err != nil {
+		return sshconfig.EmptyHost, errors.Wrap(err, "sshconfig error")
+	}
+	if target == "-" {
+		if sc.PreviousHost == sshconfig.EmptyHost {
+			return sshconfig.EmptyHost, errors.New("No previous host")
+		}
+		return sc.PreviousHost, nil
+	}
+	return sc.Lookup(target)
 }
@@ -0,0 +1,133 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+// fakeSSH puts an ssh on PATH running script, which gets the arguments of ssh.
+func fakeSSH(t *testing.T, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake commands are shell scripts")
+	}
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "ssh"), []byte("#!/bin/sh\n"+script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+const switchTestConfig = `Host web
+    Hostname 10.0.0.1
+    User root
+`
+
+func TestSwitchOp_connectFails(t *testing.T) {
+	setupKnownHosts(t, switchTestConfig)
+	t.Setenv("SSHCTX_SETTINGS", "")
+	fakeSSH(t, "echo 'Connection refused' >&2\nexit 255\n")
+
+	var stdout, stderr bytes.Buffer
+	err := (SwitchOp{Target: "web"}).Run(&stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "exit status 255") {
+		t.Fatalf("Run() error = %v, want the exit error of ssh", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(os.Getenv("HOME"), ".sshctx", "config.yaml"))
+	if bytes.Contains(data, []byte("web")) {
+		t.Errorf("failed connection saved as previous host: %s", data)
+	}
+}
+
+func TestHostFromSSHParameter(t *testing.T) {
+	tests := []struct {
+		name    string
+		sshPara string
+		want    sshconfig.Host
+		wantErr bool
+	}{
+		{name: "ip", sshPara: "root@10.0.0.1 -p 2222", want: sshconfig.Host{DisplayName: "h", Host: "10.0.0.1", Username: "root", Port: 2222}},
+		{name: "short-name", sshPara: "root@web1", want: sshconfig.Host{DisplayName: "h", Host: "web1", Username: "root"}},
+		{name: "dashed-user", sshPara: "deploy-user@localhost", want: sshconfig.Host{DisplayName: "h", Host: "localhost", Username: "deploy-user"}},
+		{name: "no-user", sshPara: "@web1 -p 22", want: sshconfig.Host{DisplayName: "h", Host: "web1", Port: 22}},
+		{name: "no-host", sshPara: "root@", wantErr: true},
+		{name: "bad-port", sshPara: "root@web1 -p x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := hostFromSSHParameter("h", tt.sshPara)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("hostFromSSHParameter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("hostFromSSHParameter() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSwitchOp_hostNames(t *testing.T) {
+	setupKnownHosts(t, `Host web
+    Hostname web1
+    User deploy-user
+
+Host local
+    Hostname localhost
+`)
+	t.Setenv("SSHCTX_SETTINGS", "")
+	args := filepath.Join(t.TempDir(), "args")
+	fakeSSH(t, "echo \"$@\" >> "+args+"\n")
+
+	for _, target := range []string{"web", "local", "💻: web#deploy-user@web1"} {
+		var stdout, stderr bytes.Buffer
+		if err := (SwitchOp{Target: target}).Run(&stdout, &stderr); err != nil {
+			t.Fatalf("Run(%s) error = %v", target, err)
+		}
+	}
+	data, err := os.ReadFile(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "-t -t deploy-user@web1\n-t -t localhost\n-t -t deploy-user@web1\n"
+	if string(data) != want {
+		t.Errorf("ssh args = %q, want %q", data, want)
+	}
+}
+
+func TestSwitchOp_dryRunCreatesNoData(t *testing.T) {
+	setupKnownHosts(t, switchTestConfig)
+	t.Setenv("SSHCTX_SETTINGS", "")
+
+	for _, target := range []string{"web", "💻: web#root@10.0.0.1"} {
+		var stdout, stderr bytes.Buffer
+		if err := (SwitchOp{Target: target, DryRun: true}).Run(&stdout, &stderr); err != nil {
+			t.Fatalf("Run(%s) error = %v", target, err)
+		}
+		if got := strings.TrimSpace(stdout.String()); got != "ssh -t -t root@10.0.0.1" {
+			t.Errorf("Run(%s) printed %q", target, got)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(os.Getenv("HOME"), ".sshctx", "config.yaml")); !os.IsNotExist(err) {
+		t.Errorf("--dry-run created the sshctx data file: %v", err)
+	}
+}
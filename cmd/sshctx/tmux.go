@@ -0,0 +1,136 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/printer"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+	"github.com/spencercjh/sshctx/internal/tmux"
+)
+
+// openInTmux opens the connection of op in a new tmux window or pane.
+// The target of op is a selector for the tiled layout.
+func openInTmux(stderr io.Writer, op SwitchOp) error {
+	if !tmux.Inside() {
+		return errors.New("'--tmux' only works inside tmux")
+	}
+	c := new(tmux.Client)
+
+	if op.Tmux == tmux.Tiled {
+		sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.DefaultLoader)
+
+		defer func(sshConfig *sshconfig.SSHConfig) {
+			_ = sshConfig.Close()
+		}(sc)
+
+		if err := sc.Parse(); err != nil {
+			return errors.Wrap(err, "sshconfig error")
+		}
+		hosts, err := sshconfig.Select(sc.Hosts, op.Target)
+		if err != nil {
+			return err
+		}
+		panes := make([]tmux.Pane, len(hosts))
+		for i, h := range hosts {
+			if panes[i], err = tmuxPane(h.DisplayName, h.DisplayName, op); err != nil {
+				return err
+			}
+		}
+		if err := c.OpenTiled(op.Target, panes, op.Sync); err != nil {
+			return err
+		}
+		_ = printer.Success(stderr, "Opened %d hosts in tmux.", len(hosts))
+		return nil
+	}
+
+	title, err := tmuxTitle(op.Target)
+	if err != nil {
+		return err
+	}
+	p, err := tmuxPane(title, op.Target, op)
+	if err != nil {
+		return err
+	}
+	if op.Tmux == tmux.Split {
+		err = c.OpenSplit(p)
+	} else {
+		err = c.OpenWindow(p)
+	}
+	if err != nil {
+		return err
+	}
+	_ = printer.Success(stderr, "Switched to target %s in tmux.", printer.SuccessColor.Sprint(title))
+	return nil
+}
+
+// tmuxTitle returns the name of the host a SwitchOp target refers to.
+func tmuxTitle(target string) (string, error) {
+	if isProviderItem(target) {
+		displayName, _, err := extract(target)
+		return displayName, err
+	}
+	h, err := resolveTarget(target)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to resolve host")
+	}
+	return h.DisplayName, nil
+}
+
+// tmuxPane returns the pane named title running sshctx itself to connect to
+// target, so that it gets the hooks, the checks and the options of op like
+// any other connection.
+func tmuxPane(title, target string, op SwitchOp) (tmux.Pane, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return tmux.Pane{}, errors.Wrap(err, "can't find the sshctx executable")
+	}
+	argv := []string{self}
+	if op.Wait {
+		argv = append(argv, "--wait")
+		if op.WaitTimeout != 0 {
+			argv = append(argv, "--timeout", op.WaitTimeout.String())
+		}
+	}
+	if op.Reconnect {
+		argv = append(argv, "--reconnect")
+		if op.ReconnectAttempts != 0 {
+			argv = append(argv, "--attempts", strconv.Itoa(op.ReconnectAttempts))
+		}
+	}
+	if op.Record {
+		argv = append(argv, "--record")
+	}
+	if op.RecordInput {
+		argv = append(argv, "--record-input")
+	}
+	return tmux.Pane{Title: title, Argv: append(argv, target), Env: tmuxEnv()}, nil
+}
+
+// tmuxEnv returns the variables picking the files of sshctx, which panes
+// wouldn't get from the tmux server.
+func tmuxEnv() []string {
+	var env []string
+	for _, name := range []string{"SSHCONFIG", "SSHCTX", "SSHCTX_SETTINGS"} {
+		if v := os.Getenv(name); v != "" {
+			env = append(env, name+"="+v)
+		}
+	}
+	return env
+}
@@ -0,0 +1,60 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spencercjh/sshctx/internal/tmux"
+)
+
+func TestTmuxPane(t *testing.T) {
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SSHCONFIG", "/tmp/ssh config")
+	t.Setenv("SSHCTX", "")
+	t.Setenv("SSHCTX_SETTINGS", "/tmp/settings.yaml")
+	env := []string{"SSHCONFIG=/tmp/ssh config", "SSHCTX_SETTINGS=/tmp/settings.yaml"}
+	tests := []struct {
+		name string
+		op   SwitchOp
+		want []string
+	}{
+		{name: "plain", op: SwitchOp{}, want: []string{self, "web"}},
+		{
+			name: "options",
+			op: SwitchOp{Wait: true, WaitTimeout: time.Minute, connectOptions: connectOptions{
+				Reconnect: true, ReconnectAttempts: 3, Record: true, RecordInput: true,
+			}},
+			want: []string{self, "--wait", "--timeout", "1m0s", "--reconnect", "--attempts", "3", "--record", "--record-input", "web"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tmuxPane("web", "web", tt.op)
+			if err != nil {
+				t.Fatalf("tmuxPane() error = %v", err)
+			}
+			if want := (tmux.Pane{Title: "web", Argv: tt.want, Env: env}); !reflect.DeepEqual(got, want) {
+				t.Errorf("tmuxPane() = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/printer"
+	"github.com/spencercjh/sshctx/internal/settings"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+	"github.com/spencercjh/sshctx/internal/tunnel"
+)
+
+// TunnelOp describes starting, stopping or inspecting tunnels defined in settings.
+type TunnelOp struct {
+	Action string // up, down or status
+	Name   string // empty for the status of all tunnels
+}
+
+func (op TunnelOp) Run(stdout, stderr io.Writer) error {
+	s, err := settings.Load()
+	if err != nil {
+		return errors.Wrap(err, "settings error")
+	}
+	m, err := tunnel.NewManager()
+	if err != nil {
+		return err
+	}
+	reaped, err := m.Reap()
+	if err != nil {
+		return errors.Wrap(err, "failed to reap dead tunnels")
+	}
+	for _, name := range reaped {
+		_ = printer.Warning(stderr, "tunnel %s was dead, removed its pid file", name)
+	}
+
+	switch op.Action {
+	case "up":
+		return tunnelUp(stderr, s, m, op.Name)
+	case "down":
+		if err := m.Down(op.Name); err != nil {
+			return err
+		}
+		_ = printer.Success(stderr, "Tunnel %s is down.", op.Name)
+		return nil
+	default:
+		return tunnelStatus(stdout, s, m, op.Name)
+	}
+}
+
+func tunnelUp(stderr io.Writer, s *settings.Settings, m *tunnel.Manager, name string) error {
+	t, err := s.Tunnel(name)
+	if err != nil {
+		return err
+	}
+	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.DefaultLoader)
+
+	defer func(sshConfig *sshconfig.SSHConfig) {
+		_ = sshConfig.Close()
+	}(sc)
+
+	if err := sc.Parse(); err != nil {
+		return errors.Wrap(err, "sshconfig error")
+	}
+	h, err := sc.Lookup(t.Host)
+	if err != nil {
+		return err
+	}
+	pid, err := m.Up(t, h)
+	if err != nil {
+		return err
+	}
+	_ = printer.Success(stderr, "Tunnel %s is up (pid %d): localhost:%d → %s via %s.",
+		t.Name, pid, t.LocalPort, forwardTarget(t), printer.SuccessColor.Sprint(h.DisplayName))
+	return nil
+}
+
+func tunnelStatus(stdout io.Writer, s *settings.Settings, m *tunnel.Manager, name string) error {
+	tunnels := s.Tunnels
+	if name != "" {
+		t, err := s.Tunnel(name)
+		if err != nil {
+			return err
+		}
+		tunnels = []settings.Tunnel{t}
+	}
+	if len(tunnels) == 0 {
+		return errors.New("no tunnels in settings")
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tHOST\tLOCAL\tREMOTE\tSTATUS")
+	for _, t := range tunnels {
+		st := m.Status(t)
+		var status string
+		switch {
+		case st.PID == 0:
+			status = "down"
+		case st.Healthy:
+			status = printer.SuccessColor.Sprintf("up (pid %d)", st.PID)
+		default:
+			status = printer.ErrorColor.Sprintf("unhealthy (pid %d)", st.PID)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", t.Name, t.Host, strconv.Itoa(t.LocalPort), forwardTarget(t), status)
+	}
+	return w.Flush()
+}
+
+func forwardTarget(t settings.Tunnel) string {
+	if t.Socks {
+		return "SOCKS"
+	}
+	return t.Remote
+}
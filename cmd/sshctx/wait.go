@@ -0,0 +1,97 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/cmdutil"
+	"github.com/spencercjh/sshctx/internal/printer"
+	"github.com/spencercjh/sshctx/internal/probe"
+)
+
+const (
+	// defaultWaitTimeout is how long `--wait` waits when no `--timeout` is given.
+	defaultWaitTimeout = 5 * time.Minute
+	// waitProbeTimeout is how long each attempt of `--wait` may take.
+	waitProbeTimeout = 5 * time.Second
+)
+
+var spinnerFrames = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+// waitForTarget polls the SSH port of the target until it sends a banner.
+func waitForTarget(stderr io.Writer, target string, timeout time.Duration) error {
+	h, err := resolveTarget(target)
+	if err != nil {
+		return errors.Wrap(err, "failed to resolve host")
+	}
+	if timeout == 0 {
+		timeout = defaultWaitTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	addr := h.Addr()
+	stopSpinner := spin(stderr, fmt.Sprintf("Waiting for %s (%s)", h.DisplayName, addr))
+	backoff := &cmdutil.Backoff{Initial: time.Second, Max: 15 * time.Second}
+	r, err := probe.New(waitProbeTimeout).Wait(ctx, addr, backoff)
+	elapsed := stopSpinner()
+	if err != nil {
+		return errors.Errorf("%s (%s) is still unreachable after %s: %v", h.DisplayName, addr, timeout, r.Err)
+	}
+	_ = printer.Success(stderr, "%s is up after %s: %s", h.DisplayName, elapsed, r.Banner)
+	return nil
+}
+
+// spin shows a spinner with the elapsed time after msg until the returned
+// function is called. It only prints msg once when stderr isn't a terminal.
+func spin(w io.Writer, msg string) func() time.Duration {
+	start := time.Now()
+	if !isatty.IsTerminal(os.Stderr.Fd()) {
+		_, _ = fmt.Fprintf(w, "%s...\n", msg)
+		return func() time.Duration {
+			return time.Since(start).Round(time.Second)
+		}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			_, _ = fmt.Fprintf(w, "\r%c %s %s ", spinnerFrames[i%len(spinnerFrames)], msg, time.Since(start).Round(time.Second))
+			select {
+			case <-done:
+				// clear the spinner line
+				_, _ = fmt.Fprint(w, "\r\033[K")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() time.Duration {
+		close(done)
+		<-stopped
+		return time.Since(start).Round(time.Second)
+	}
+}
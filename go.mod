@@ -4,13 +4,19 @@ go 1.17
 
 require (
 	facette.io/natsort v0.0.0-20181210072756-2cd4dd1e2dcb
+	github.com/creack/pty v1.1.18
 	github.com/fatih/color v1.9.0
 	github.com/google/go-cmp v0.5.6
 	github.com/manifoldco/promptui v0.9.0
-	github.com/mattn/go-colorable v0.1.4 // indirect
 	github.com/mattn/go-isatty v0.0.12
 	github.com/pkg/errors v0.9.1
-	golang.org/x/sys v0.0.0-20200116001909-b77594299b42 // indirect
+	golang.org/x/crypto v0.1.0
+	golang.org/x/sys v0.1.0
+	golang.org/x/term v0.1.0
 	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c
+)
+
+require (
 	github.com/chzyer/readline v0.0.0-20180603132655-2972be24d48e // indirect
-)
\ No newline at end of file
+	github.com/mattn/go-colorable v0.1.4 // indirect
+)
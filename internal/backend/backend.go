@@ -0,0 +1,129 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+// Presets of the backends sshctx knows the command line of.
+const (
+	SSH     = "ssh"
+	Mosh    = "mosh"
+	AutoSSH = "autossh"
+	ET      = "et"
+)
+
+// Data is what a backend template is rendered with.
+type Data struct {
+	DisplayName string
+	Host        string
+	Username    string
+	Port        int // 22 when the host doesn't set a port
+}
+
+// Command returns the command line connecting to h with the backend.
+// A backend is the name of a preset, empty for ssh, or a Go template of a
+// command line like `mosh --ssh="ssh -p {{.Port}}" {{.Username}}@{{.Host}}`,
+// rendered with Data and split into arguments like a shell would.
+func Command(backend string, h sshconfig.Host) ([]string, error) {
+	dest := h.Destination()
+	switch backend {
+	case "", SSH:
+		return append([]string{"ssh", "-t", "-t"}, h.ToSSHArgs()...), nil
+	case Mosh:
+		if h.Port > 0 {
+			return []string{"mosh", "--ssh=ssh -p " + strconv.Itoa(h.Port), dest}, nil
+		}
+		return []string{"mosh", dest}, nil
+	case AutoSSH:
+		args := []string{"autossh", "-M", "0", "-o", "ServerAliveInterval=30", "-o", "ServerAliveCountMax=3", "-t", "-t"}
+		return append(args, h.ToSSHArgs()...), nil
+	case ET:
+		if h.Port > 0 {
+			return []string{"et", "--ssh-option", "Port=" + strconv.Itoa(h.Port), dest}, nil
+		}
+		return []string{"et", dest}, nil
+	}
+	if !strings.Contains(backend, "{{") {
+		return nil, fmt.Errorf("unknown backend %q, want one of ssh, mosh, autossh, et or a template", backend)
+	}
+	return render(backend, h)
+}
+
+func render(backend string, h sshconfig.Host) ([]string, error) {
+	tmpl, err := template.New("backend").Option("missingkey=error").Parse(backend)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid backend template")
+	}
+	data := Data{DisplayName: h.DisplayName, Host: h.Host, Username: h.Username, Port: h.EffectivePort()}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, errors.Wrap(err, "failed to render backend template")
+	}
+	args, err := splitArgs(buf.String())
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid backend command %q", buf.String())
+	}
+	if len(args) == 0 {
+		return nil, errors.New("backend template renders an empty command")
+	}
+	return args, nil
+}
+
+// splitArgs splits a command line on whitespace like a shell, without any
+// expansion: single and double quotes keep their content as one argument.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+func TestCommand(t *testing.T) {
+	web := sshconfig.Host{DisplayName: "web", Host: "10.0.0.1", Username: "root", Port: 2222}
+	db := sshconfig.Host{DisplayName: "db", Host: "10.0.0.2", Username: "admin"}
+	tests := []struct {
+		name    string
+		backend string
+		host    sshconfig.Host
+		want    []string
+		wantErr bool
+	}{
+		{name: "default", host: db, want: []string{"ssh", "-t", "-t", "admin@10.0.0.2"}},
+		{name: "ssh-port", backend: "ssh", host: web, want: []string{"ssh", "-t", "-t", "-p", "2222", "root@10.0.0.1"}},
+		{name: "mosh", backend: "mosh", host: db, want: []string{"mosh", "admin@10.0.0.2"}},
+		{name: "mosh-port", backend: "mosh", host: web, want: []string{"mosh", "--ssh=ssh -p 2222", "root@10.0.0.1"}},
+		{name: "autossh", backend: "autossh", host: web, want: []string{"autossh", "-M", "0",
+			"-o", "ServerAliveInterval=30", "-o", "ServerAliveCountMax=3", "-t", "-t", "-p", "2222", "root@10.0.0.1"}},
+		{name: "et", backend: "et", host: db, want: []string{"et", "admin@10.0.0.2"}},
+		{name: "et-port", backend: "et", host: web, want: []string{"et", "--ssh-option", "Port=2222", "root@10.0.0.1"}},
+		{name: "template", backend: `mosh --ssh="ssh -p {{.Port}}" {{.Username}}@{{.Host}} -- tmux new -As '{{.DisplayName}} main'`,
+			host: db, want: []string{"mosh", "--ssh=ssh -p 22", "admin@10.0.0.2", "--", "tmux", "new", "-As", "db main"}},
+		{name: "unknown", backend: "telnet", host: db, wantErr: true},
+		{name: "bad-field", backend: "ssh {{.Hostname}}", host: db, wantErr: true},
+		{name: "unterminated-quote", backend: `ssh "{{.Host}}`, host: db, wantErr: true},
+		{name: "empty", backend: "{{/* nothing */}}", host: db, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Command(tt.backend, tt.host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Command() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Command() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
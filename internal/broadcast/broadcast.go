@@ -0,0 +1,417 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broadcast
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// HotKey (Ctrl-]) introduces a broadcast command. It's followed by:
+//
+//	1-9 : send input to the n-th host only and show its output
+//	a   : send input to all hosts
+//	n   : move input to the next host
+//	q   : close all sessions and quit
+//
+// Pressing HotKey twice sends a literal HotKey.
+const HotKey = 0x1d
+
+const (
+	allHosts = -1
+	// maxPending bounds the output kept for a host while it's hidden.
+	maxPending = 64 << 10
+	// minPaneRows is the smallest pane of the split view, a header and a line.
+	minPaneRows = 3
+)
+
+var labelColor = color.New(color.FgCyan, color.Bold)
+
+// Broadcaster mirrors input to a set of sessions and multiplexes their output.
+//
+// Once the size of the terminal is known, see Resize, it's split into a pane
+// per host while input goes to all hosts, each showing the latest output of
+// its host as plain text. When input goes to a single host, that host gets
+// the whole terminal above a bar of tabs naming the hosts.
+//
+// Without a size, or when the panes would be too small, the output of every
+// host is shown with a `[name]` prefix on each line instead, and only the
+// output of the single host input goes to; the output of the others is kept
+// and flushed when they're visible again.
+type Broadcaster struct {
+	sessions []*Session
+	out      io.Writer
+
+	mu          sync.Mutex
+	focus       int
+	rows, cols  int
+	screens     []*screen
+	pending     [][]byte
+	atLineStart []bool
+	last        int
+	quit        chan struct{}
+	quitOnce    sync.Once
+}
+
+// New creates a Broadcaster writing the output of the sessions to out.
+func New(out io.Writer, sessions ...*Session) *Broadcaster {
+	b := &Broadcaster{
+		sessions:    sessions,
+		out:         out,
+		focus:       allHosts,
+		screens:     make([]*screen, len(sessions)),
+		pending:     make([][]byte, len(sessions)),
+		atLineStart: make([]bool, len(sessions)),
+		last:        allHosts,
+		quit:        make(chan struct{}),
+	}
+	for i := range b.atLineStart {
+		b.screens[i] = new(screen)
+		b.atLineStart[i] = true
+	}
+	return b
+}
+
+// Resize sets the size of the terminal, resizing the sessions to the pane
+// or the tab they're shown in, and draws the view again.
+func (b *Broadcaster) Resize(rows, cols int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rows, b.cols = rows, cols
+	b.layout()
+}
+
+// Run mirrors in to the sessions until all of them exit or the user quits.
+func (b *Broadcaster) Run(in io.Reader) error {
+	var wg sync.WaitGroup
+	for i, s := range b.sessions {
+		wg.Add(1)
+		go func(i int, s *Session) {
+			defer wg.Done()
+			buf := make([]byte, 4096)
+			for {
+				n, err := s.Read(buf)
+				if n > 0 {
+					b.output(i, buf[:n])
+				}
+				if err != nil {
+					break
+				}
+			}
+			if err := s.Wait(); err != nil {
+				b.exited(i, fmt.Sprintf("%s exited: %v", s.Name, err))
+			} else {
+				b.exited(i, fmt.Sprintf("%s exited", s.Name))
+			}
+		}(i, s)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	go b.input(in)
+
+	select {
+	case <-done:
+	case <-b.quit:
+		for _, s := range b.sessions {
+			_ = s.Close()
+		}
+		<-done
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.split() || b.tabbed() {
+		// give the terminal back with the cursor on its last line
+		_, _ = fmt.Fprintf(b.out, "\x1b[r\x1b[%d;1H\r\n", b.rows)
+	}
+	return nil
+}
+
+// input reads keystrokes from in, handles hot keys and forwards the rest.
+func (b *Broadcaster) input(in io.Reader) {
+	buf := make([]byte, 1024)
+	prefix := false
+	for {
+		n, err := in.Read(buf)
+		var chunk []byte
+		for _, c := range buf[:n] {
+			switch {
+			case prefix:
+				prefix = false
+				if c == HotKey {
+					chunk = append(chunk, c)
+					continue
+				}
+				b.send(chunk)
+				chunk = nil
+				if b.command(c) {
+					return
+				}
+			case c == HotKey:
+				prefix = true
+			default:
+				chunk = append(chunk, c)
+			}
+		}
+		b.send(chunk)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// command runs a hot key command and reports whether the user quit.
+func (b *Broadcaster) command(c byte) bool {
+	switch {
+	case c == 'q':
+		b.quitOnce.Do(func() { close(b.quit) })
+		return true
+	case c == 'a':
+		b.setFocus(allHosts)
+	case c == 'n':
+		b.mu.Lock()
+		next := b.focus + 1
+		if next >= len(b.sessions) {
+			next = allHosts
+		}
+		b.mu.Unlock()
+		b.setFocus(next)
+	case c >= '1' && c <= '9' && int(c-'1') < len(b.sessions):
+		b.setFocus(int(c - '1'))
+	}
+	return false
+}
+
+func (b *Broadcaster) send(p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	b.mu.Lock()
+	focus := b.focus
+	b.mu.Unlock()
+	for i, s := range b.sessions {
+		if focus == allHosts || focus == i {
+			// a session that has exited just doesn't get the input
+			_, _ = s.Write(p)
+		}
+	}
+}
+
+func (b *Broadcaster) setFocus(focus int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.focus = focus
+	if b.rows > 0 && b.splittable() {
+		b.layout()
+		return
+	}
+	if focus == allHosts {
+		b.writeStatus("input: all hosts")
+		for i := range b.sessions {
+			b.flush(i)
+		}
+		return
+	}
+	b.writeStatus(fmt.Sprintf("input: %s", b.sessions[focus].Name))
+	b.flush(focus)
+}
+
+// splittable reports whether the terminal is large enough for a pane per
+// host. b.mu must be held.
+func (b *Broadcaster) splittable() bool {
+	return len(b.sessions) > 0 && b.rows/len(b.sessions) >= minPaneRows
+}
+
+// split reports whether the split view is shown. b.mu must be held.
+func (b *Broadcaster) split() bool {
+	return b.rows > 0 && b.splittable() && b.focus == allHosts
+}
+
+// tabbed reports whether a single host is shown above the tabs. b.mu must be held.
+func (b *Broadcaster) tabbed() bool {
+	return b.rows > 0 && b.splittable() && b.focus != allHosts
+}
+
+// paneRows returns the first row and the height of the pane of the i-th
+// session. b.mu must be held.
+func (b *Broadcaster) paneRows(i int) (int, int) {
+	height := b.rows / len(b.sessions)
+	if i == len(b.sessions)-1 {
+		return i*height + 1, b.rows - i*height
+	}
+	return i*height + 1, height
+}
+
+// layout resizes the sessions to the current view and draws it. b.mu must be held.
+func (b *Broadcaster) layout() {
+	switch {
+	case b.split():
+		_, _ = io.WriteString(b.out, "\x1b[r\x1b[H\x1b[2J")
+		for i, s := range b.sessions {
+			_, height := b.paneRows(i)
+			_ = s.Resize(height-1, b.cols)
+			b.drawPane(i)
+		}
+	case b.tabbed():
+		for i, s := range b.sessions {
+			if i == b.focus {
+				_ = s.Resize(b.rows-1, b.cols)
+			}
+		}
+		// keep the output of the host above the tabs on the last row
+		_, _ = fmt.Fprintf(b.out, "\x1b[r\x1b[H\x1b[2J\x1b[1;%dr", b.rows-1)
+		lines := b.screens[b.focus].tail(b.rows - 1)
+		_, _ = io.WriteString(b.out, strings.Join(lines, "\r\n"))
+		b.drawTabs()
+	default:
+		for _, s := range b.sessions {
+			if b.rows > 0 {
+				_ = s.Resize(b.rows, b.cols)
+			}
+		}
+	}
+}
+
+// drawPane draws the header and the latest output of the i-th session in
+// its pane, leaving the cursor after the output. b.mu must be held.
+func (b *Broadcaster) drawPane(i int) {
+	top, height := b.paneRows(i)
+	var buf bytes.Buffer
+	title := fmt.Sprintf("─ %d: %s ", i+1, b.sessions[i].Name)
+	if pad := b.cols - len([]rune(title)); pad > 0 {
+		title += strings.Repeat("─", pad)
+	}
+	fmt.Fprintf(&buf, "\x1b[%d;1H%s\x1b[K", top, labelColor.Sprint(truncate(title, b.cols)))
+	lines := b.screens[i].tail(height - 1)
+	for j := 0; j < height-1; j++ {
+		line := ""
+		if j < len(lines) {
+			line = truncate(lines[j], b.cols)
+		}
+		fmt.Fprintf(&buf, "\x1b[%d;1H%s\x1b[K", top+1+j, line)
+	}
+	row := top + len(lines)
+	col := b.screens[i].column()
+	if col > b.cols {
+		col = b.cols
+	}
+	fmt.Fprintf(&buf, "\x1b[%d;%dH", row, col)
+	_, _ = b.out.Write(buf.Bytes())
+}
+
+// drawTabs draws the names of the hosts on the last row, highlighting the
+// one input goes to. b.mu must be held.
+func (b *Broadcaster) drawTabs() {
+	var tabs strings.Builder
+	for i, s := range b.sessions {
+		tab := fmt.Sprintf(" %d: %s ", i+1, s.Name)
+		if i == b.focus {
+			tab = "\x1b[7m" + tab + "\x1b[27m"
+		}
+		tabs.WriteString(tab)
+	}
+	tabs.WriteString(" Ctrl-] a: all hosts")
+	// save and restore the cursor of the host around the tabs
+	_, _ = fmt.Fprintf(b.out, "\x1b7\x1b[%d;1H%s\x1b[K\x1b8", b.rows, tabs.String())
+}
+
+func (b *Broadcaster) output(i int, p []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.screens[i].write(p)
+	switch {
+	case b.focus == i:
+		_, _ = b.out.Write(p)
+	case b.split():
+		b.drawPane(i)
+	case b.tabbed():
+		// shown from its screen when the split view or its tab is back
+	case b.focus == allHosts:
+		b.writeLabeled(i, p)
+	default:
+		b.pending[i] = append(b.pending[i], p...)
+		if over := len(b.pending[i]) - maxPending; over > 0 {
+			b.pending[i] = b.pending[i][over:]
+		}
+	}
+}
+
+// flush writes the output kept for a hidden session. b.mu must be held.
+func (b *Broadcaster) flush(i int) {
+	if len(b.pending[i]) == 0 {
+		return
+	}
+	if b.focus == i {
+		_, _ = b.out.Write(b.pending[i])
+	} else {
+		b.writeLabeled(i, b.pending[i])
+	}
+	b.pending[i] = nil
+}
+
+// writeLabeled writes p prefixing every line with the session name. b.mu must be held.
+func (b *Broadcaster) writeLabeled(i int, p []byte) {
+	var buf bytes.Buffer
+	if b.last != i && b.last != allHosts && !b.atLineStart[b.last] {
+		buf.WriteString("\r\n")
+		b.atLineStart[b.last] = true
+	}
+	label := labelColor.Sprintf("[%s] ", b.sessions[i].Name)
+	for _, c := range p {
+		if b.atLineStart[i] {
+			buf.WriteString(label)
+			b.atLineStart[i] = false
+		}
+		buf.WriteByte(c)
+		if c == '\n' {
+			b.atLineStart[i] = true
+		}
+	}
+	b.last = i
+	_, _ = b.out.Write(buf.Bytes())
+}
+
+// exited tells that the i-th session has exited, in its pane when the
+// terminal is split.
+func (b *Broadcaster) exited(i int, msg string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.split() && !b.tabbed() {
+		b.writeStatus(msg)
+		return
+	}
+	b.screens[i].write([]byte("\r\n[sshctx] " + msg + "\r\n"))
+	if b.split() {
+		b.drawPane(i)
+	} else if b.focus == i {
+		_, _ = io.WriteString(b.out, "\r\n"+labelColor.Sprint("[sshctx] ")+msg+"\r\n")
+	}
+}
+
+// writeStatus writes a message of sshctx itself on its own line. b.mu must be held.
+func (b *Broadcaster) writeStatus(msg string) {
+	if b.last != allHosts && !b.atLineStart[b.last] {
+		b.atLineStart[b.last] = true
+		_, _ = io.WriteString(b.out, "\r\n")
+	}
+	_, _ = io.WriteString(b.out, labelColor.Sprint("[sshctx] ")+msg+"\r\n")
+}
@@ -0,0 +1,175 @@
+package broadcast
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSSH creates an executable that echoes its input, standing in for ssh(1).
+func fakeSSH(t *testing.T) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("pseudo-terminals are not supported on windows")
+	}
+	path := filepath.Join(t.TempDir(), "ssh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\nexec cat\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func waitFor(t *testing.T, out *syncBuffer, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if strings.Contains(out.String(), want) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("output doesn't contain %q; output=%q", want, out.String())
+}
+
+func TestSession_echo(t *testing.T) {
+	s, err := Start("a", exec.Command(fakeSSH(t)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = s.Close()
+		_ = s.Wait()
+	}()
+
+	var out syncBuffer
+	go func() { _, _ = io.Copy(&out, s) }()
+	if _, err := s.Write([]byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, &out, "hello")
+}
+
+func TestBroadcaster_Run(t *testing.T) {
+	ssh := fakeSSH(t)
+	var sessions []*Session
+	for _, name := range []string{"a", "b"} {
+		s, err := Start(name, exec.Command(ssh), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, s)
+	}
+
+	var out syncBuffer
+	in, typing := io.Pipe()
+	b := New(&out, sessions...)
+	done := make(chan error)
+	go func() { done <- b.Run(in) }()
+
+	_, _ = typing.Write([]byte("ping\n"))
+	waitFor(t, &out, "[a] ping")
+	waitFor(t, &out, "[b] ping")
+
+	_, _ = typing.Write([]byte{HotKey, '1'})
+	waitFor(t, &out, "[sshctx] input: a")
+	_, _ = typing.Write([]byte("solo\n"))
+	waitFor(t, &out, "solo")
+
+	_, _ = typing.Write([]byte{HotKey, 'a'})
+	waitFor(t, &out, "[sshctx] input: all hosts")
+	_, _ = typing.Write([]byte("pong\n"))
+	waitFor(t, &out, "[b] pong")
+	if strings.Contains(out.String(), "[b] solo") {
+		t.Errorf("input for a single host reached other hosts; output=%q", out.String())
+	}
+
+	_, _ = typing.Write([]byte{HotKey, 'q'})
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() didn't return after quit")
+	}
+}
+
+func TestSession_Resize(t *testing.T) {
+	fakeSSH(t)
+	s, err := Start("a", exec.Command("sh", "-c", "read x; stty size"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = s.Close()
+		_ = s.Wait()
+	}()
+
+	var out syncBuffer
+	go func() { _, _ = io.Copy(&out, s) }()
+	if err := s.Resize(10, 30); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = s.Write([]byte("\n"))
+	waitFor(t, &out, "10 30")
+}
+
+func TestBroadcaster_split(t *testing.T) {
+	ssh := fakeSSH(t)
+	var sessions []*Session
+	for _, name := range []string{"a", "b"} {
+		s, err := Start(name, exec.Command(ssh), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, s)
+	}
+
+	var out syncBuffer
+	in, typing := io.Pipe()
+	b := New(&out, sessions...)
+	b.Resize(8, 30)
+	done := make(chan error)
+	go func() { done <- b.Run(in) }()
+
+	// a pane of 4 rows per host: a header and the output
+	waitFor(t, &out, "\x1b[1;1H"+labelColor.Sprint("─ 1: a ─"))
+	waitFor(t, &out, "\x1b[5;1H"+labelColor.Sprint("─ 2: b ─"))
+	_, _ = typing.Write([]byte("ping\n"))
+	waitFor(t, &out, "\x1b[6;1Hping")
+	waitFor(t, &out, "\x1b[2;1Hping")
+
+	// a single host gets the terminal above the tabs
+	_, _ = typing.Write([]byte{HotKey, '2'})
+	waitFor(t, &out, "\x1b[1;7r")
+	waitFor(t, &out, "\x1b[7m 2: b \x1b[27m")
+	_, _ = typing.Write([]byte{HotKey, 'q'})
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() didn't return after quit")
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broadcast
+
+import "unicode/utf8"
+
+// maxLines bounds the lines a screen keeps.
+const maxLines = 500
+
+// screen keeps the recent output of a session as plain text lines for the
+// split view. Escape sequences are dropped, a carriage return followed by
+// more text overwrites the line and backspace erases the last character.
+type screen struct {
+	lines [][]byte
+	cur   []byte
+	cr    bool
+	esc   int // state of the escape sequence being skipped
+}
+
+// States of the escape sequences of a screen.
+const (
+	escNone = iota
+	escStart
+	escCSI
+	escOSC
+	escOSCEnd
+	escCharset
+)
+
+func (s *screen) write(p []byte) {
+	for _, c := range p {
+		switch s.esc {
+		case escStart:
+			switch c {
+			case '[':
+				s.esc = escCSI
+			case ']':
+				s.esc = escOSC
+			case '(', ')', '#', '%':
+				s.esc = escCharset
+			default:
+				s.esc = escNone
+			}
+			continue
+		case escCSI:
+			if c >= 0x40 && c <= 0x7e {
+				s.esc = escNone
+			}
+			continue
+		case escOSC:
+			if c == 0x07 {
+				s.esc = escNone
+			} else if c == 0x1b {
+				s.esc = escOSCEnd
+			}
+			continue
+		case escOSCEnd, escCharset:
+			s.esc = escNone
+			continue
+		}
+		switch {
+		case c == 0x1b:
+			s.esc = escStart
+		case c == '\n':
+			s.lines = append(s.lines, s.cur)
+			if len(s.lines) > maxLines {
+				s.lines = s.lines[len(s.lines)-maxLines:]
+			}
+			s.cur, s.cr = nil, false
+		case c == '\r':
+			s.cr = true
+		case c == '\b':
+			if _, size := utf8.DecodeLastRune(s.cur); size > 0 {
+				s.cur = s.cur[:len(s.cur)-size]
+			}
+		case c == '\t':
+			s.text(' ')
+			for utf8.RuneCount(s.cur)%8 != 0 {
+				s.cur = append(s.cur, ' ')
+			}
+		case c < 0x20 || c == 0x7f:
+		default:
+			s.text(c)
+		}
+	}
+}
+
+// text adds a printable byte to the current line.
+func (s *screen) text(c byte) {
+	if s.cr {
+		s.cur, s.cr = nil, false
+	}
+	s.cur = append(s.cur, c)
+}
+
+// tail returns the last n lines, ending with the current one.
+func (s *screen) tail(n int) []string {
+	if n < 1 {
+		return nil
+	}
+	lines := make([]string, 0, n)
+	start := len(s.lines) + 1 - n
+	if start < 0 {
+		start = 0
+	}
+	for _, l := range s.lines[start:] {
+		lines = append(lines, string(l))
+	}
+	return append(lines, string(s.cur))
+}
+
+// column returns the 1-based column after the current line.
+func (s *screen) column() int {
+	return utf8.RuneCount(s.cur) + 1
+}
+
+// truncate cuts s to at most n characters.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:n])
+}
@@ -0,0 +1,36 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broadcast
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScreen(t *testing.T) {
+	var s screen
+	s.write([]byte("$ ls\r\n\x1b[01;34mdir\x1b[0m\tfile\r\n10%\r50%\r100%\r\n\x1b]0;title\x07$ ab"))
+	s.write([]byte("c\b\bx"))
+	want := []string{"$ ls", "dir     file", "100%", "$ ax"}
+	if got := s.tail(10); !reflect.DeepEqual(got, want) {
+		t.Errorf("tail() = %q, want %q", got, want)
+	}
+	if got := s.tail(2); !reflect.DeepEqual(got, want[2:]) {
+		t.Errorf("tail(2) = %q, want %q", got, want[2:])
+	}
+	if got := s.column(); got != 5 {
+		t.Errorf("column() = %d, want 5", got)
+	}
+}
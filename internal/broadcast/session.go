@@ -0,0 +1,68 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broadcast
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/creack/pty"
+	"github.com/pkg/errors"
+)
+
+// Session is a process (usually ssh) running on its own pseudo-terminal.
+type Session struct {
+	Name string
+	cmd  *exec.Cmd
+	pty  *os.File
+}
+
+// Start runs cmd on a new pseudo-terminal of the given size.
+// A nil size leaves the pseudo-terminal at its default size.
+func Start(name string, cmd *exec.Cmd, size *pty.Winsize) (*Session, error) {
+	f, err := pty.StartWithSize(cmd, size)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to start session for %s", name)
+	}
+	return &Session{Name: name, cmd: cmd, pty: f}, nil
+}
+
+// Read reads the output of the session.
+func (s *Session) Read(p []byte) (int, error) {
+	return s.pty.Read(p)
+}
+
+// Write sends input to the session as if it was typed.
+func (s *Session) Write(p []byte) (int, error) {
+	return s.pty.Write(p)
+}
+
+// Resize changes the size of the pseudo-terminal, which the process is
+// told about with SIGWINCH.
+func (s *Session) Resize(rows, cols int) error {
+	return pty.Setsize(s.pty, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
+}
+
+// Wait waits for the process of the session to exit.
+func (s *Session) Wait() error {
+	return s.cmd.Wait()
+}
+
+// Close kills the process and closes the pseudo-terminal.
+// Wait must still be called to release the process.
+func (s *Session) Close() error {
+	_ = s.cmd.Process.Kill()
+	return s.pty.Close()
+}
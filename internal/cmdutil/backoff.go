@@ -0,0 +1,37 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdutil
+
+import "time"
+
+// Backoff computes exponentially growing delays between retries,
+// doubling from Initial up to Max.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+	next    time.Duration
+}
+
+// Next returns the delay to wait before the next retry.
+func (b *Backoff) Next() time.Duration {
+	if b.next == 0 {
+		b.next = b.Initial
+	}
+	d := b.next
+	if b.next *= 2; b.next > b.Max {
+		b.next = b.Max
+	}
+	return d
+}
@@ -0,0 +1,39 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdutil
+
+import (
+	"regexp"
+	"strings"
+)
+
+// unsafeShellChars are the characters that need quoting in a POSIX shell.
+var unsafeShellChars = regexp.MustCompile(`[^\w@%+=:,./-]`)
+
+// ShellQuote joins args into a command line a POSIX shell splits back into args.
+func ShellQuote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		switch {
+		case a == "":
+			quoted[i] = "''"
+		case unsafeShellChars.MatchString(a):
+			quoted[i] = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+		default:
+			quoted[i] = a
+		}
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,34 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdutil
+
+import "testing"
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"ssh", "-t", "-t", "-p", "2222", "root@10.0.0.1"}, want: "ssh -t -t -p 2222 root@10.0.0.1"},
+		{args: []string{"mosh", "--ssh=ssh -p 2222", "me@laptop"}, want: "mosh '--ssh=ssh -p 2222' me@laptop"},
+		{args: []string{"scp", "it's", "$HOME", ""}, want: `scp 'it'\''s' '$HOME' ''`},
+		{args: []string{"rsync", "a.txt", "root@10.0.0.1:~/dir/"}, want: "rsync a.txt 'root@10.0.0.1:~/dir/'"},
+	}
+	for _, tt := range tests {
+		if got := ShellQuote(tt.args); got != tt.want {
+			t.Errorf("ShellQuote(%q) = %s, want %s", tt.args, got, tt.want)
+		}
+	}
+}
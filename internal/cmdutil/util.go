@@ -16,6 +16,7 @@ package cmdutil
 
 import (
 	"os"
+	"path/filepath"
 
 	"github.com/pkg/errors"
 )
@@ -38,3 +39,24 @@ func IsNotFoundErr(err error) bool {
 	}
 	return false
 }
+
+// WriteFileAtomic writes data to a temporary file next to path and renames it
+// to path, so that sshctx processes writing the file at once never mix their data.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
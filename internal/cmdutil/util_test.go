@@ -16,6 +16,8 @@ package cmdutil
 
 import (
 	"github.com/spencercjh/sshctx/internal/testutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -77,3 +79,20 @@ func Test_homeDir(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte("a much longer old content\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomic(path, []byte("new\n"), 0600); err != nil {
+		t.Fatalf("WriteFileAtomic() error = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new\n" {
+		t.Errorf("content = %q, want %q", data, "new\n")
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Errorf("WriteFileAtomic() left %d files, want 1", len(files))
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spencercjh/sshctx/internal/cmdutil"
+	"github.com/spencercjh/sshctx/internal/knownhosts"
+)
+
+// Sources of discovered hosts.
+const (
+	SourceKnownHosts = "known_hosts"
+	SourceHistory    = "history"
+)
+
+// sshOptionsWithArgument are the ssh(1) options taking an argument.
+const sshOptionsWithArgument = "BbcDEeFIiJLlmOopQRSWw"
+
+var (
+	// zshExtendedHistoryRegexp matches the `: <start>:<elapsed>;` prefix of EXTENDED_HISTORY.
+	zshExtendedHistoryRegexp = regexp.MustCompile(`^: \d+:\d+;`)
+	// commandSeparatorRegexp splits a shell line into the commands it runs.
+	commandSeparatorRegexp = regexp.MustCompile(`;|&&|\|\|?`)
+	// nameRegexp matches hostnames and user names worth offering, excluding
+	// variables, command substitutions and patterns.
+	nameRegexp = regexp.MustCompile(`^[\w][\w.-]*$`)
+)
+
+// Host is a host found outside of sshconfig.
+type Host struct {
+	Host   string
+	User   string // empty if unknown
+	Port   int    // 0 if unknown
+	Source string
+}
+
+// Name returns the host as it would be typed: `[user@]host[:port]`.
+func (h Host) Name() string {
+	name := h.Host
+	if h.User != "" {
+		name = h.User + "@" + name
+	}
+	if h.Port > 0 && h.Port != 22 {
+		name += ":" + strconv.Itoa(h.Port)
+	}
+	return name
+}
+
+// KnownHosts returns the hosts with plain hostnames in the known_hosts
+// files. Only the first name of each entry is used, since ssh(1) appends
+// the IP address of the host with CheckHostIP. Missing files are skipped.
+func KnownHosts(paths ...string) []Host {
+	var hosts []Host
+	for _, path := range paths {
+		f, err := knownhosts.Load(path)
+		if err != nil {
+			continue
+		}
+		for _, e := range f.Entries {
+			if !e.IsSpecific() || e.Hashed() {
+				continue
+			}
+			host, port := splitKnownHostsName(e.Patterns[0])
+			if nameRegexp.MatchString(host) {
+				hosts = append(hosts, Host{Host: host, Port: port, Source: SourceKnownHosts})
+			}
+		}
+	}
+	return hosts
+}
+
+// splitKnownHostsName parses `host` or `[host]:port`.
+func splitKnownHostsName(name string) (string, int) {
+	if !strings.HasPrefix(name, "[") {
+		return name, 0
+	}
+	end := strings.Index(name, "]:")
+	if end == -1 {
+		return strings.Trim(name, "[]"), 0
+	}
+	port, _ := strconv.Atoi(name[end+2:])
+	return name[1:end], port
+}
+
+// HistoryFiles returns the shell history files of the user: $HISTFILE,
+// ~/.bash_history and ~/.zsh_history.
+func HistoryFiles() []string {
+	var files []string
+	if v := os.Getenv("HISTFILE"); v != "" {
+		files = append(files, v)
+	}
+	if home := cmdutil.HomeDir(); home != "" {
+		for _, name := range []string{".bash_history", ".zsh_history"} {
+			if path := filepath.Join(home, name); path != os.Getenv("HISTFILE") {
+				files = append(files, path)
+			}
+		}
+	}
+	return files
+}
+
+// History returns the destinations of the ssh commands in the shell history
+// files, in bash format or zsh format with or without EXTENDED_HISTORY.
+// Missing files are skipped.
+func History(paths ...string) []Host {
+	var hosts []Host
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		s := bufio.NewScanner(f)
+		s.Buffer(nil, 1024*1024)
+		for s.Scan() {
+			line := zshExtendedHistoryRegexp.ReplaceAllString(s.Text(), "")
+			for _, command := range commandSeparatorRegexp.Split(line, -1) {
+				if h, ok := parseCommand(strings.Fields(command)); ok {
+					hosts = append(hosts, h)
+				}
+			}
+		}
+		_ = f.Close()
+	}
+	return hosts
+}
+
+// parseCommand returns the destination of a command if it runs ssh,
+// possibly behind environment variables or sudo.
+func parseCommand(words []string) (Host, bool) {
+	for len(words) > 0 {
+		switch w := words[0]; {
+		case w == "sudo" || w == "exec" || w == "command" || w == "time" || w == "nohup":
+			words = words[1:]
+		case strings.Contains(w, "=") && !strings.HasPrefix(w, "-"):
+			words = words[1:]
+		case w == "ssh" || strings.HasSuffix(w, "/ssh"):
+			return ParseSSHArgs(words[1:])
+		default:
+			return Host{}, false
+		}
+	}
+	return Host{}, false
+}
+
+// ParseSSHArgs returns the destination of ssh(1) called with args, taking
+// the user and port from -l, -p and -o into account.
+func ParseSSHArgs(args []string) (Host, bool) {
+	var user, port, dest string
+args:
+	for i := 0; i < len(args); i++ {
+		a := unquote(args[i])
+		if a == "--" {
+			if i+1 < len(args) {
+				dest = unquote(args[i+1])
+			}
+			break
+		}
+		if !strings.HasPrefix(a, "-") || a == "-" {
+			dest = a
+			break
+		}
+		for j := 1; j < len(a); j++ {
+			if strings.IndexByte(sshOptionsWithArgument, a[j]) == -1 {
+				continue
+			}
+			value := a[j+1:]
+			if value == "" {
+				if i+1 >= len(args) {
+					break args
+				}
+				i++
+				value = unquote(args[i])
+			}
+			switch a[j] {
+			case 'l':
+				user = value
+			case 'p':
+				port = value
+			case 'o':
+				if k, v, ok := splitOption(value); ok {
+					switch strings.ToLower(k) {
+					case "user":
+						user = v
+					case "port":
+						port = v
+					}
+				}
+			}
+			break
+		}
+	}
+	if dest == "" {
+		return Host{}, false
+	}
+	if strings.HasPrefix(dest, "ssh://") {
+		dest = strings.TrimPrefix(dest, "ssh://")
+		if i := strings.LastIndex(dest, ":"); i != -1 {
+			dest, port = dest[:i], dest[i+1:]
+		}
+	}
+	return destination(dest, user, port, SourceHistory)
+}
+
+// ParseName parses a host as returned by Name, `[user@]host[:port]`.
+func ParseName(name string) (Host, bool) {
+	var port string
+	if i := strings.LastIndex(name, ":"); i != -1 {
+		name, port = name[:i], name[i+1:]
+	}
+	return destination(name, "", port, "")
+}
+
+// destination validates `[user@]host`, the user and the port of a host
+// defaulting to the ones given separately.
+func destination(dest, user, port, source string) (Host, bool) {
+	if i := strings.LastIndex(dest, "@"); i != -1 {
+		user, dest = dest[:i], dest[i+1:]
+	}
+	if !nameRegexp.MatchString(dest) || (user != "" && !nameRegexp.MatchString(user)) {
+		return Host{}, false
+	}
+	h := Host{Host: dest, User: user, Source: source}
+	if port != "" {
+		n, err := strconv.Atoi(port)
+		if err != nil || n <= 0 || n > 65535 {
+			return Host{}, false
+		}
+		h.Port = n
+	}
+	return h, true
+}
+
+// splitOption splits `Key=value` as given to -o.
+func splitOption(o string) (string, string, bool) {
+	i := strings.IndexAny(o, "= ")
+	if i == -1 {
+		return "", "", false
+	}
+	return strings.TrimSpace(o[:i]), strings.TrimSpace(o[i+1:]), true
+}
+
+func unquote(s string) string {
+	return strings.Trim(s, `"'`)
+}
@@ -0,0 +1,110 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestParseSSHArgs(t *testing.T) {
+	tests := []struct {
+		args   string
+		want   Host
+		wantOK bool
+	}{
+		{"web.example.com", Host{Host: "web.example.com", Source: SourceHistory}, true},
+		{"root@10.0.0.1 uptime", Host{Host: "10.0.0.1", User: "root", Source: SourceHistory}, true},
+		{"-p 2222 -A deploy@10.0.0.1", Host{Host: "10.0.0.1", User: "deploy", Port: 2222, Source: SourceHistory}, true},
+		{"-p2222 -l deploy 10.0.0.1", Host{Host: "10.0.0.1", User: "deploy", Port: 2222, Source: SourceHistory}, true},
+		{"-i ~/.ssh/id_ed25519 -oPort=2200 -o User=admin 10.0.0.1", Host{Host: "10.0.0.1", User: "admin", Port: 2200, Source: SourceHistory}, true},
+		{"-vJ bastion 'me@10.0.0.1'", Host{Host: "10.0.0.1", User: "me", Source: SourceHistory}, true},
+		{"ssh://me@10.0.0.1:2022", Host{Host: "10.0.0.1", User: "me", Port: 2022, Source: SourceHistory}, true},
+		{"-- 10.0.0.1", Host{Host: "10.0.0.1", Source: SourceHistory}, true},
+		{"$HOST", Host{}, false},
+		{"-p", Host{}, false},
+		{"-p x 10.0.0.1", Host{}, false},
+		{"", Host{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			got, ok := ParseSSHArgs(strings.Fields(tt.args))
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("ParseSSHArgs(%q) = %+v, %v, want %+v, %v", tt.args, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseName(t *testing.T) {
+	h, ok := ParseName("me@10.0.0.1:2022")
+	if want := (Host{Host: "10.0.0.1", User: "me", Port: 2022}); !ok || h != want {
+		t.Errorf("ParseName() = %+v, %v, want %+v", h, ok, want)
+	}
+	if h.Name() != "me@10.0.0.1:2022" {
+		t.Errorf("Name() = %s", h.Name())
+	}
+	if _, ok := ParseName("me@10.0.0.1:x"); ok {
+		t.Error("ParseName() accepted an invalid port")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	dir := t.TempDir()
+	bash := filepath.Join(dir, ".bash_history")
+	zsh := filepath.Join(dir, ".zsh_history")
+	_ = os.WriteFile(bash, []byte("ls -l\nssh root@10.0.0.1\ncd /tmp && sudo ssh -p 2222 db.example.com\n"), 0600)
+	_ = os.WriteFile(zsh, []byte(": 1700000000:0;git status\n: 1700000001:3;TERM=xterm /usr/bin/ssh me@web.example.com | tee log\necho ssh nope.example.com\n"), 0600)
+
+	var got []string
+	for _, h := range History(bash, zsh, filepath.Join(dir, "missing")) {
+		got = append(got, h.Name())
+	}
+	want := []string{"root@10.0.0.1", "db.example.com:2222", "me@web.example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("History() = %v, want %v", got, want)
+	}
+}
+
+func TestKnownHosts(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	key, _ := ssh.NewPublicKey(pub)
+	lines := []string{
+		knownhosts.Line([]string{"web.example.com", "10.0.0.1"}, key),
+		knownhosts.Line([]string{"[db.example.com]:2222"}, key),
+		knownhosts.Line([]string{knownhosts.HashHostname("secret.example.com")}, key),
+		knownhosts.Line([]string{"*.example.com"}, key),
+		"@cert-authority " + knownhosts.Line([]string{"*.corp"}, key),
+	}
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	_ = os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+
+	var got []string
+	for _, h := range KnownHosts(path) {
+		got = append(got, h.Name())
+	}
+	want := []string{"web.example.com", "db.example.com:2222"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("KnownHosts() = %v, want %v", got, want)
+	}
+}
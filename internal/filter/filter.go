@@ -0,0 +1,263 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Fields are the values of a host filters look at, by lower case field name.
+// A field can have several values, like IdentityFile or the tags.
+type Fields map[string][]string
+
+// Expr is a parsed filter expression.
+type Expr interface {
+	Match(f Fields) bool
+}
+
+type (
+	// term matches `field=pattern`.
+	term struct{ field, pattern string }
+	// has matches `has:field`.
+	has struct{ field string }
+	not struct{ e Expr }
+	and struct{ l, r Expr }
+	or  struct{ l, r Expr }
+	// search matches any value containing text.
+	search struct{ text string }
+)
+
+func (t term) Match(f Fields) bool {
+	for _, v := range f[t.field] {
+		if globMatch(t.pattern, strings.ToLower(v)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h has) Match(f Fields) bool {
+	for _, v := range f[h.field] {
+		if v != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (n not) Match(f Fields) bool { return !n.e.Match(f) }
+func (a and) Match(f Fields) bool { return a.l.Match(f) && a.r.Match(f) }
+func (o or) Match(f Fields) bool  { return o.l.Match(f) || o.r.Match(f) }
+
+func (s search) Match(f Fields) bool {
+	for _, values := range f {
+		for _, v := range values {
+			if strings.Contains(strings.ToLower(v), s.text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Search returns an expression matching the hosts with any field containing
+// text, ignoring case.
+func Search(text string) Expr {
+	return search{text: strings.ToLower(text)}
+}
+
+// Parse parses a filter expression. Terms are `field=pattern` with `*` and
+// `?` wildcards, `field!=pattern` and `has:field`, where field is name,
+// hostname, user, port, tag or any ssh_config keyword. Terms are combined
+// with `and`, `or`, `not` and parentheses; terms next to each other must all
+// match. Names, patterns and keywords ignore case.
+func Parse(s string) (Expr, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, errors.Errorf("invalid filter: unexpected '%s'", p.tokens[p.pos])
+	}
+	return e, nil
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) or() (Expr, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "or") {
+		p.pos++
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l = or{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) and() (Expr, error) {
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch t := p.peek(); {
+		case t == "" || t == ")" || strings.EqualFold(t, "or"):
+			return l, nil
+		case strings.EqualFold(t, "and"):
+			p.pos++
+		}
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l = and{l, r}
+	}
+}
+
+func (p *parser) unary() (Expr, error) {
+	t := p.peek()
+	p.pos++
+	switch {
+	case t == "":
+		return nil, errors.New("invalid filter: unexpected end")
+	case strings.EqualFold(t, "not"):
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return not{e}, nil
+	case t == "(":
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, errors.New("invalid filter: missing ')'")
+		}
+		p.pos++
+		return e, nil
+	case t == ")" || strings.EqualFold(t, "and") || strings.EqualFold(t, "or"):
+		return nil, errors.Errorf("invalid filter: unexpected '%s'", t)
+	}
+	return parseTerm(t)
+}
+
+func parseTerm(t string) (Expr, error) {
+	if strings.HasPrefix(strings.ToLower(t), "has:") {
+		field := strings.ToLower(t[len("has:"):])
+		if field == "" {
+			return nil, errors.Errorf("invalid filter: '%s' requires a field", t)
+		}
+		return has{field: field}, nil
+	}
+	i := strings.Index(t, "=")
+	if i <= 0 {
+		return nil, errors.Errorf("invalid filter: '%s' is not field=pattern or has:field", t)
+	}
+	field, pattern := strings.ToLower(t[:i]), strings.ToLower(t[i+1:])
+	if strings.HasSuffix(field, "!") {
+		return not{term{field: strings.TrimSuffix(field, "!"), pattern: pattern}}, nil
+	}
+	return term{field: field, pattern: pattern}, nil
+}
+
+// tokenize splits s at spaces and parentheses. Double quotes keep spaces
+// and parentheses in a value, e.g. `proxycommand="ssh -W %h:%p jump"`.
+func tokenize(s string) ([]string, error) {
+	var tokens []string
+	var cur strings.Builder
+	inToken, quoted := false, false
+	flush := func() {
+		if inToken {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+			inToken = false
+		}
+	}
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inToken = true
+		case quoted:
+			cur.WriteRune(r)
+		case r == ' ' || r == '\t' || r == '\n':
+			flush()
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, string(r))
+		default:
+			cur.WriteRune(r)
+			inToken = true
+		}
+	}
+	if quoted {
+		return nil, errors.New("invalid filter: missing '\"'")
+	}
+	flush()
+	if len(tokens) == 0 {
+		return nil, errors.New("empty filter")
+	}
+	return tokens, nil
+}
+
+// globMatch matches the `*` and `?` wildcards. Unlike path.Match, `*` also
+// matches `/`, so patterns work for paths and commands.
+func globMatch(pattern, value string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(value); i >= 0; i-- {
+				if globMatch(pattern[1:], value[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if value == "" {
+				return false
+			}
+		default:
+			if value == "" || value[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, value = pattern[1:], value[1:]
+	}
+	return value == ""
+}
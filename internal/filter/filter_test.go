@@ -0,0 +1,83 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import "testing"
+
+var (
+	web = Fields{
+		"name": {"web-1"}, "hostname": {"10.115.0.1"}, "user": {"root"}, "port": {"22"},
+		"tag": {"prod", "frontend"}, "proxyjump": {"bastion"}, "identityfile": {"~/.ssh/web", "~/.ssh/id_ed25519"},
+	}
+	db = Fields{
+		"name": {"db-1"}, "hostname": {"10.116.0.2"}, "user": {"postgres"}, "port": {"2222"}, "tag": {"prod"},
+	}
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr    string
+		web, db bool
+	}{
+		{"user=root", true, false},
+		{"USER=Root", true, false},
+		{"hostname=10.115.*", true, false},
+		{"hostname=10.11?.0.*", true, true},
+		{"tag=prod", true, true},
+		{"tag=front*", true, false},
+		{"has:ProxyJump", true, false},
+		{"not has:proxyjump", false, true},
+		{"user!=root", false, true},
+		{"identityfile=~/.ssh/web", true, false},
+		{"tag=prod port=2222", false, true},
+		{"tag=prod and port=2222", false, true},
+		{"user=root or port=2222", true, true},
+		{"tag=prod and (user=root or port=22)", true, false},
+		{"not (user=root or user=postgres)", false, false},
+		{"name=\"web-1\"", true, false},
+		{"nope=*", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := e.Match(web); got != tt.web {
+				t.Errorf("Match(web) = %v, want %v", got, tt.web)
+			}
+			if got := e.Match(db); got != tt.db {
+				t.Errorf("Match(db) = %v, want %v", got, tt.db)
+			}
+		})
+	}
+}
+
+func TestParse_invalid(t *testing.T) {
+	for _, expr := range []string{"", "root", "user=root and", "(user=root", "user=root)", "or user=root", "has:", "=root", `name="web`} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded", expr)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	if !Search("10.115").Match(web) || Search("10.115").Match(db) {
+		t.Error("Search(10.115) should only match web")
+	}
+	if !Search("BASTION").Match(web) || !Search("postgres").Match(db) {
+		t.Error("Search should match option values ignoring case")
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hooks
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+// Names of the hooks, also passed to them as SSHCTX_HOOK.
+const (
+	PreConnect  = "pre_connect"
+	PostConnect = "post_connect"
+)
+
+// Env returns the environment variables describing h to a hook.
+func Env(hook string, h sshconfig.Host) []string {
+	return []string{
+		"SSHCTX_HOOK=" + hook,
+		"SSHCTX_HOST=" + h.DisplayName,
+		"SSHCTX_HOSTNAME=" + h.Host,
+		"SSHCTX_USER=" + h.Username,
+		"SSHCTX_PORT=" + strconv.Itoa(h.EffectivePort()),
+	}
+}
+
+// Run runs the hook commands in order with the shell, adding env to their
+// environment. It stops at the first command that fails.
+func Run(commands []string, env []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	for _, c := range commands {
+		cmd := shell(c)
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Stdin = stdin
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		if err := cmd.Run(); err != nil {
+			return errors.Wrapf(err, "hook %q failed", c)
+		}
+	}
+	return nil
+}
+
+func shell(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
@@ -0,0 +1,39 @@
+package hooks
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks use sh")
+	}
+	h := sshconfig.Host{DisplayName: "web", Host: "10.0.0.1", Username: "root"}
+	env := append(Env(PostConnect, h), "SSHCTX_EXIT_CODE=0")
+
+	var out bytes.Buffer
+	err := Run([]string{
+		`echo "$SSHCTX_HOOK $SSHCTX_HOST $SSHCTX_USER@$SSHCTX_HOSTNAME:$SSHCTX_PORT"`,
+		`echo "exit $SSHCTX_EXIT_CODE"`,
+	}, env, nil, &out, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "post_connect web root@10.0.0.1:22\nexit 0\n"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	err = Run([]string{"echo first", "exit 2", "echo never"}, Env(PreConnect, h), nil, &out, &out)
+	if err == nil || !strings.Contains(err.Error(), `"exit 2"`) {
+		t.Errorf("Run() = %v, want the failing hook", err)
+	}
+	if out.String() != "first\n" {
+		t.Errorf("output = %q, hooks after the failing one should not run", out.String())
+	}
+}
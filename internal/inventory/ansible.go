@@ -0,0 +1,423 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// ansibleRangeRegexp matches host ranges like `web[01:10]` or `db-[a:c]`.
+	ansibleRangeRegexp = regexp.MustCompile(`\[([0-9]+|[a-z]):([0-9]+|[a-z])\]`)
+	// proxyJumpRegexp finds the jump host in ansible_ssh_common_args.
+	proxyJumpRegexp = regexp.MustCompile(`(?:ProxyJump[= ]|-J ?)([^\s'"]+)`)
+)
+
+// ansibleCollector gathers the variables and groups of hosts, in the order
+// the hosts first appear.
+type ansibleCollector struct {
+	order []string
+	vars  map[string]map[string]string
+	tags  map[string][]string
+}
+
+func newAnsibleCollector() *ansibleCollector {
+	return &ansibleCollector{vars: map[string]map[string]string{}, tags: map[string][]string{}}
+}
+
+// add merges vars into those of the host, overriding the ones it had.
+func (c *ansibleCollector) add(name string, vars map[string]string, groups ...string) {
+	if _, ok := c.vars[name]; !ok {
+		c.order = append(c.order, name)
+		c.vars[name] = map[string]string{}
+	}
+	for k, v := range vars {
+		c.vars[name][k] = v
+	}
+	for _, g := range groups {
+		if g != "all" && g != "ungrouped" {
+			c.tags[name] = append(c.tags[name], g)
+		}
+	}
+}
+
+// hosts converts the hosts using the ssh connection variables of Ansible.
+func (c *ansibleCollector) hosts() ([]Host, []string) {
+	var hosts []Host
+	var warnings []string
+	for _, name := range c.order {
+		vars := c.vars[name]
+		if conn := first(vars, "ansible_connection"); conn != "" && conn != "ssh" && conn != "smart" && conn != "paramiko" {
+			warnings = append(warnings, fmt.Sprintf("%s: skipped, it uses the %s connection", name, conn))
+			continue
+		}
+		h := Host{
+			Name:     name,
+			Hostname: first(vars, "ansible_host", "ansible_ssh_host"),
+			User:     first(vars, "ansible_user", "ansible_ssh_user", "ansible_ssh_user_name"),
+			Port:     parsePort(first(vars, "ansible_port", "ansible_ssh_port")),
+		}
+		if h.Hostname == "" {
+			h.Hostname = name
+		}
+		if key := first(vars, "ansible_ssh_private_key_file", "ansible_private_key_file"); key != "" {
+			h.IdentityFiles = []string{key}
+		}
+		if m := proxyJumpRegexp.FindStringSubmatch(first(vars, "ansible_ssh_common_args") + " " + first(vars, "ansible_ssh_extra_args")); m != nil {
+			h.ProxyJump = m[1]
+		}
+		h.AddTags(c.tags[name]...)
+		if first(vars, "ansible_password", "ansible_ssh_pass", "ansible_ssh_password") != "" {
+			warnings = append(warnings, fmt.Sprintf("%s: the password isn't imported, ssh_config can't hold passwords", name))
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, warnings
+}
+
+func first(vars map[string]string, keys ...string) string {
+	for _, k := range keys {
+		if v := vars[k]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// ParseAnsible reads the hosts of an Ansible inventory in INI or YAML format,
+// with warnings about what can't be imported. Groups, including the groups a
+// group is a child of, become tags.
+func ParseAnsible(r io.Reader) ([]Host, []string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "can't read inventory")
+	}
+	if isAnsibleYAML(data) {
+		return parseAnsibleYAML(data)
+	}
+	return parseAnsibleINI(data)
+}
+
+// isAnsibleYAML reports whether the inventory starts like YAML rather than INI.
+func isAnsibleYAML(data []byte) bool {
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		return line == "---" || strings.HasSuffix(line, ":")
+	}
+	return false
+}
+
+func parseAnsibleINI(data []byte) ([]Host, []string, error) {
+	type group struct {
+		hosts    []string
+		vars     map[string]string
+		children []string
+	}
+	groups := map[string]*group{}
+	var hostOrder []string
+	hostVars := map[string]map[string]string{}
+	get := func(name string) *group {
+		if g, ok := groups[name]; ok {
+			return g
+		}
+		g := &group{vars: map[string]string{}}
+		groups[name] = g
+		return g
+	}
+
+	section, kind := "ungrouped", "hosts"
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section, kind = strings.Trim(line, "[]"), "hosts"
+			if i := strings.Index(section, ":"); i != -1 {
+				section, kind = section[:i], section[i+1:]
+			}
+			get(section)
+			continue
+		}
+		switch kind {
+		case "vars":
+			if k, v, ok := splitVar(line); ok {
+				get(section).vars[k] = v
+			}
+		case "children":
+			get(section).children = append(get(section).children, strings.Fields(line)[0])
+		case "hosts":
+			words, err := splitQuoted(line)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "line %d", n)
+			}
+			vars := map[string]string{}
+			for _, w := range words[1:] {
+				if k, v, ok := splitVar(w); ok {
+					vars[k] = v
+				}
+			}
+			for _, name := range expandAnsibleRange(words[0]) {
+				if _, ok := hostVars[name]; !ok {
+					hostOrder = append(hostOrder, name)
+					hostVars[name] = map[string]string{}
+				}
+				for k, v := range vars {
+					hostVars[name][k] = v
+				}
+				get(section).hosts = append(get(section).hosts, name)
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "can't read inventory")
+	}
+
+	// the groups of each host, outer groups first so inner ones override their vars
+	parents := map[string][]string{}
+	for name, g := range groups {
+		for _, child := range g.children {
+			parents[child] = append(parents[child], name)
+		}
+	}
+	var ancestors func(name string, seen map[string]bool) []string
+	ancestors = func(name string, seen map[string]bool) []string {
+		if seen[name] {
+			return nil
+		}
+		seen[name] = true
+		var all []string
+		for _, p := range parents[name] {
+			all = append(all, ancestors(p, seen)...)
+		}
+		return append(all, name)
+	}
+	c := newAnsibleCollector()
+	for _, name := range hostOrder {
+		var memberOf []string
+		for gName, g := range groups {
+			for _, h := range g.hosts {
+				if h == name {
+					memberOf = append(memberOf, gName)
+					break
+				}
+			}
+		}
+		sortGroups(memberOf, data)
+		vars := map[string]string{}
+		for k, v := range get("all").vars {
+			vars[k] = v
+		}
+		var tags []string
+		seen := map[string]bool{}
+		for _, g := range memberOf {
+			for _, a := range ancestors(g, seen) {
+				for k, v := range get(a).vars {
+					vars[k] = v
+				}
+				tags = append(tags, a)
+			}
+		}
+		for k, v := range hostVars[name] {
+			vars[k] = v
+		}
+		c.add(name, vars, tags...)
+	}
+	hosts, warnings := c.hosts()
+	return hosts, warnings, nil
+}
+
+// sortGroups orders group names by where their section starts in the
+// inventory, so tags keep the order of the file.
+func sortGroups(names []string, data []byte) {
+	pos := func(name string) int {
+		if i := bytes.Index(data, []byte("["+name)); i != -1 {
+			return i
+		}
+		return -1
+	}
+	for i := 1; i < len(names); i++ {
+		for j := i; j > 0 && pos(names[j]) < pos(names[j-1]); j-- {
+			names[j], names[j-1] = names[j-1], names[j]
+		}
+	}
+}
+
+// splitVar splits `key=value`.
+func splitVar(s string) (string, string, bool) {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(s[:i]), strings.Trim(strings.TrimSpace(s[i+1:]), `'"`), true
+}
+
+// splitQuoted splits a line at spaces outside of single or double quotes,
+// keeping the quotes.
+func splitQuoted(line string) ([]string, error) {
+	var words []string
+	var cur strings.Builder
+	var quote rune
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			cur.WriteRune(r)
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			cur.WriteRune(r)
+		case r == ' ' || r == '\t':
+			if cur.Len() > 0 {
+				words = append(words, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if cur.Len() > 0 {
+		words = append(words, cur.String())
+	}
+	return words, nil
+}
+
+// expandAnsibleRange expands the first range of a host pattern, e.g.
+// `web[01:03]` to web01, web02 and web03.
+func expandAnsibleRange(pattern string) []string {
+	loc := ansibleRangeRegexp.FindStringSubmatchIndex(pattern)
+	if loc == nil {
+		return []string{pattern}
+	}
+	from, to := pattern[loc[2]:loc[3]], pattern[loc[4]:loc[5]]
+	prefix, suffix := pattern[:loc[0]], pattern[loc[1]:]
+	var names []string
+	if a, err := strconv.Atoi(from); err == nil {
+		b, err := strconv.Atoi(to)
+		if err != nil {
+			return []string{pattern}
+		}
+		for i := a; i <= b; i++ {
+			names = append(names, expandAnsibleRange(fmt.Sprintf("%s%0*d%s", prefix, len(from), i, suffix))...)
+		}
+		return names
+	}
+	if len(to) != 1 {
+		return []string{pattern}
+	}
+	for c := from[0]; c <= to[0]; c++ {
+		names = append(names, expandAnsibleRange(prefix+string(c)+suffix)...)
+	}
+	return names
+}
+
+func parseAnsibleYAML(data []byte) ([]Host, []string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, errors.Wrap(err, "can't parse inventory")
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, errors.New("inventory is not a map of groups")
+	}
+	c := newAnsibleCollector()
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		walkAnsibleGroup(c, root.Content[i].Value, root.Content[i+1], nil, map[string]string{}, 0)
+	}
+	hosts, warnings := c.hosts()
+	return hosts, warnings, nil
+}
+
+// walkAnsibleGroup collects the hosts of a group and its children, which
+// inherit its vars and groups.
+func walkAnsibleGroup(c *ansibleCollector, name string, g *yaml.Node, groups []string, vars map[string]string, depth int) {
+	if g.Kind != yaml.MappingNode || depth > 20 {
+		return
+	}
+	groups = append(append([]string{}, groups...), name)
+	merged := map[string]string{}
+	for k, v := range vars {
+		merged[k] = v
+	}
+	if v := mappingValue(g, "vars"); v != nil {
+		for k, val := range scalarMap(v) {
+			merged[k] = val
+		}
+	}
+	if hosts := mappingValue(g, "hosts"); hosts != nil && hosts.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(hosts.Content); i += 2 {
+			hostVars := map[string]string{}
+			for k, v := range merged {
+				hostVars[k] = v
+			}
+			for k, v := range scalarMap(hosts.Content[i+1]) {
+				hostVars[k] = v
+			}
+			for _, h := range expandAnsibleRange(hosts.Content[i].Value) {
+				c.add(h, hostVars, groups...)
+			}
+		}
+	}
+	if children := mappingValue(g, "children"); children != nil && children.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(children.Content); i += 2 {
+			walkAnsibleGroup(c, children.Content[i].Value, children.Content[i+1], groups, merged, depth+1)
+		}
+	}
+}
+
+// mappingValue returns the value of key in a mapping node.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	if m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// scalarMap returns the scalar values of a mapping node.
+func scalarMap(m *yaml.Node) map[string]string {
+	values := map[string]string{}
+	if m.Kind != yaml.MappingNode {
+		return values
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i+1].Kind == yaml.ScalarNode {
+			values[m.Content[i].Value] = m.Content[i+1].Value
+		}
+	}
+	return values
+}
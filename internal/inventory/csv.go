@@ -0,0 +1,104 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// csvColumns maps the accepted column names to the fields of Host.
+var csvColumns = map[string]string{
+	"name": "name", "alias": "name",
+	"hostname": "hostname", "host": "hostname", "address": "hostname", "ip": "hostname",
+	"user": "user", "username": "user",
+	"port":          "port",
+	"tags":          "tags",
+	"identity_file": "identityfile", "identityfile": "identityfile", "key": "identityfile",
+	"proxy_jump": "proxyjump", "proxyjump": "proxyjump",
+}
+
+// ParseCSV reads hosts from CSV with a header line, like the one written by
+// `export --format csv`. A hostname column is required. Tags are separated
+// by commas. Rows without a name are named after their hostname.
+func ParseCSV(r io.Reader) ([]Host, []string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "can't read csv header")
+	}
+	columns := make([]string, len(header))
+	hasHostname := false
+	for i, c := range header {
+		columns[i] = csvColumns[strings.ToLower(strings.TrimSpace(c))]
+		hasHostname = hasHostname || columns[i] == "hostname"
+	}
+	if !hasHostname {
+		return nil, nil, errors.New("csv has no hostname column")
+	}
+
+	var hosts []Host
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, errors.Wrap(err, "can't read csv")
+		}
+		var h Host
+		for i, v := range row {
+			if i >= len(columns) {
+				break
+			}
+			v = strings.TrimSpace(v)
+			switch columns[i] {
+			case "name":
+				h.Name = v
+			case "hostname":
+				h.Hostname = v
+			case "user":
+				h.User = v
+			case "port":
+				h.Port = parsePort(v)
+			case "tags":
+				h.AddTags(splitList(v)...)
+			case "identityfile":
+				if v != "" {
+					h.IdentityFiles = []string{v}
+				}
+			case "proxyjump":
+				h.ProxyJump = v
+			}
+		}
+		if h.Hostname == "" {
+			continue
+		}
+		if h.Name == "" {
+			h.Name = h.Hostname
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, nil, nil
+}
+
+// splitList splits a comma or space separated list.
+func splitList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == ';' })
+}
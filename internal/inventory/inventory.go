@@ -0,0 +1,144 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+)
+
+// ImportedFromKey is the metadata key marking the Host blocks written by
+// `import`, so importing the same file again updates them.
+const ImportedFromKey = "imported-from"
+
+// Host is a host read from the inventory of another tool.
+type Host struct {
+	Name          string
+	Hostname      string
+	User          string
+	Port          int
+	IdentityFiles []string
+	ProxyJump     string
+	Tags          []string
+}
+
+// AddTags adds tags the host doesn't have yet.
+func (h *Host) AddTags(tags ...string) {
+	for _, t := range tags {
+		if t != "" && !h.HasTag(t) {
+			h.Tags = append(h.Tags, t)
+		}
+	}
+}
+
+// HasTag reports whether the host has the tag.
+func (h *Host) HasTag(tag string) bool {
+	for _, t := range h.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Block returns the Host block of sshconfig for the host, marked as
+// imported from source when it isn't empty.
+func (h Host) Block(source string) string {
+	var b strings.Builder
+	b.WriteString("Host " + h.Name + "\n")
+	b.WriteString("    Hostname " + quoteValue(h.Hostname) + "\n")
+	if h.User != "" {
+		b.WriteString("    User " + quoteValue(h.User) + "\n")
+	}
+	if h.Port > 0 && h.Port != 22 {
+		b.WriteString("    Port " + strconv.Itoa(h.Port) + "\n")
+	}
+	for _, f := range h.IdentityFiles {
+		b.WriteString("    IdentityFile " + quoteValue(f) + "\n")
+	}
+	if h.ProxyJump != "" {
+		b.WriteString("    ProxyJump " + quoteValue(h.ProxyJump) + "\n")
+	}
+	if len(h.Tags) > 0 {
+		b.WriteString("    #sshctx: tags=" + strings.Join(h.Tags, ",") + "\n")
+	}
+	if source != "" {
+		b.WriteString("    #sshctx: " + ImportedFromKey + "=" + source + "\n")
+	}
+	return b.String()
+}
+
+// quoteValue quotes an option value with whitespace, like a key in
+// `C:\Users\John Doe`, which ssh would otherwise split into arguments.
+func quoteValue(v string) string {
+	if strings.IndexFunc(v, unicode.IsSpace) >= 0 {
+		return `"` + v + `"`
+	}
+	return v
+}
+
+// parsePort parses a port, 0 if it is empty or invalid.
+func parsePort(s string) int {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || port <= 0 || port > 65535 {
+		return 0
+	}
+	return port
+}
+
+// sessionName turns the name of a saved session or a folder into a Host name
+// or tag, which can't have spaces, wildcards or commas.
+func sessionName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || strings.ContainsRune("*?!,", r) {
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(s))
+}
+
+// readText reads the text of an export of a Windows client, which may be
+// UTF-16 like regedit writes it or start with a byte order mark.
+func readText(r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", errors.Wrap(err, "can't read export")
+	}
+	var le bool
+	switch {
+	case bytes.HasPrefix(data, []byte{0xef, 0xbb, 0xbf}):
+		return string(data[3:]), nil
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		le = true
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+	default:
+		return string(data), nil
+	}
+	u := make([]uint16, 0, len(data)/2)
+	for i := 2; i+1 < len(data); i += 2 {
+		if le {
+			u = append(u, uint16(data[i])|uint16(data[i+1])<<8)
+		} else {
+			u = append(u, uint16(data[i])<<8|uint16(data[i+1]))
+		}
+	}
+	return string(utf16.Decode(u)), nil
+}
@@ -0,0 +1,320 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+const ansibleINI = `# inventory
+bastion ansible_host=203.0.113.1
+
+[web]
+web[01:02] ansible_user=deploy
+
+[db]
+db1 ansible_host=10.0.1.1 ansible_port=2222 ansible_ssh_common_args='-o ProxyJump=bastion'
+win1 ansible_connection=winrm
+
+[prod:children]
+web
+db
+
+[prod:vars]
+ansible_user=admin
+ansible_ssh_private_key_file=~/.ssh/prod
+
+[all:vars]
+ansible_password=secret
+`
+
+const ansibleYAML = `all:
+  hosts:
+    bastion:
+      ansible_host: 203.0.113.1
+  vars:
+    ansible_password: secret
+  children:
+    prod:
+      vars:
+        ansible_user: admin
+        ansible_ssh_private_key_file: ~/.ssh/prod
+      children:
+        web:
+          hosts:
+            web[01:02]:
+              ansible_user: deploy
+        db:
+          hosts:
+            db1:
+              ansible_host: 10.0.1.1
+              ansible_port: 2222
+              ansible_ssh_common_args: -J bastion
+            win1:
+              ansible_connection: winrm
+`
+
+func TestParseAnsible(t *testing.T) {
+	want := []Host{
+		{Name: "bastion", Hostname: "203.0.113.1"},
+		{Name: "web01", Hostname: "web01", User: "deploy", IdentityFiles: []string{"~/.ssh/prod"}, Tags: []string{"prod", "web"}},
+		{Name: "web02", Hostname: "web02", User: "deploy", IdentityFiles: []string{"~/.ssh/prod"}, Tags: []string{"prod", "web"}},
+		{Name: "db1", Hostname: "10.0.1.1", User: "admin", Port: 2222, IdentityFiles: []string{"~/.ssh/prod"}, ProxyJump: "bastion", Tags: []string{"prod", "db"}},
+	}
+	for name, inventory := range map[string]string{"ini": ansibleINI, "yaml": ansibleYAML} {
+		t.Run(name, func(t *testing.T) {
+			hosts, warnings, err := ParseAnsible(strings.NewReader(inventory))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(hosts, want) {
+				t.Errorf("ParseAnsible() =\n%+v\nwant\n%+v", hosts, want)
+			}
+			// the password of every host and the skipped winrm host
+			if len(warnings) != 5 || !strings.Contains(strings.Join(warnings, "\n"), "win1: skipped") {
+				t.Errorf("warnings = %q", warnings)
+			}
+		})
+	}
+}
+
+func TestExpandAnsibleRange(t *testing.T) {
+	got := expandAnsibleRange("db-[a:b][08:10].lan")
+	want := []string{"db-a08.lan", "db-a09.lan", "db-a10.lan", "db-b08.lan", "db-b09.lan", "db-b10.lan"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expandAnsibleRange() = %v, want %v", got, want)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	hosts, _, err := ParseCSV(strings.NewReader("Name, IP, user, port, tags\nweb,10.0.0.1,deploy,22,\"prod,web\"\n,10.0.0.2,,x,\n,,,,\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Host{
+		{Name: "web", Hostname: "10.0.0.1", User: "deploy", Port: 22, Tags: []string{"prod", "web"}},
+		{Name: "10.0.0.2", Hostname: "10.0.0.2"},
+	}
+	if !reflect.DeepEqual(hosts, want) {
+		t.Errorf("ParseCSV() = %+v, want %+v", hosts, want)
+	}
+	if _, _, err := ParseCSV(strings.NewReader("name,user\nweb,root\n")); err == nil {
+		t.Error("ParseCSV() accepted a csv without hostname")
+	}
+}
+
+func TestParseEtcHosts(t *testing.T) {
+	hosts, _, err := ParseEtcHosts(strings.NewReader(`127.0.0.1 localhost
+::1 localhost ip6-localhost
+fe00::0 ip6-localnet
+ff02::1 ip6-allnodes
+# 10.0.0.9 old
+10.0.0.1	web.lan web # frontend
+2001:db8::1 v6.lan
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Host{{Name: "web.lan", Hostname: "10.0.0.1"}, {Name: "v6.lan", Hostname: "2001:db8::1"}}
+	if !reflect.DeepEqual(hosts, want) {
+		t.Errorf("ParseEtcHosts() = %+v, want %+v", hosts, want)
+	}
+}
+
+func TestHost_Block(t *testing.T) {
+	h := Host{Name: "db1", Hostname: "10.0.1.1", User: "admin", Port: 2222, IdentityFiles: []string{"~/.ssh/prod"},
+		ProxyJump: "bastion", Tags: []string{"prod", "db"}}
+	want := `Host db1
+    Hostname 10.0.1.1
+    User admin
+    Port 2222
+    IdentityFile ~/.ssh/prod
+    ProxyJump bastion
+    #sshctx: tags=prod,db
+    #sshctx: imported-from=/tmp/hosts.ini
+`
+	if got := h.Block("/tmp/hosts.ini"); got != want {
+		t.Errorf("Block() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHost_Block_quotesWhitespace(t *testing.T) {
+	hosts, _, err := ParsePuTTY(strings.NewReader(`Windows Registry Editor Version 5.00
+
+[HKEY_CURRENT_USER\Software\SimonTatham\PuTTY\Sessions\web]
+"HostName"="10.0.0.1"
+"UserName"="deploy"
+"PublicKeyFile"="C:\\Users\\John Doe\\key.ppk"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `Host web
+    Hostname 10.0.0.1
+    User deploy
+    IdentityFile "C:\Users\John Doe\key"
+`
+	if len(hosts) != 1 {
+		t.Fatalf("ParsePuTTY() = %+v", hosts)
+	}
+	if got := hosts[0].Block(""); got != want {
+		t.Errorf("Block() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+const puttyReg = `Windows Registry Editor Version 5.00
+
+[HKEY_CURRENT_USER\Software\SimonTatham\PuTTY\Sessions]
+
+[HKEY_CURRENT_USER\Software\SimonTatham\PuTTY\Sessions\Default%20Settings]
+"HostName"=""
+
+[HKEY_CURRENT_USER\Software\SimonTatham\PuTTY\Sessions\prod%20web]
+"HostName"="deploy@10.0.0.1"
+"PortNumber"=dword:00000016
+"Protocol"="ssh"
+"PublicKeyFile"="C:\\Users\\me\\.ssh\\prod.ppk"
+"PortForwardings"="L8080=localhost:80"
+
+[HKEY_CURRENT_USER\Software\SimonTatham\PuTTY\Sessions\db]
+"HostName"="10.0.0.2"
+"UserName"="admin"
+"PortNumber"=dword:000008ae
+"Protocol"="ssh"
+"ProxyMethod"=dword:00000006
+"ProxyHost"="bastion.example.com"
+"ProxyUsername"="jump"
+"ProxyPort"=dword:00000016
+
+[HKEY_CURRENT_USER\Software\SimonTatham\PuTTY\Sessions\switch]
+"HostName"="10.0.0.3"
+"Protocol"="telnet"
+`
+
+func TestParsePuTTY(t *testing.T) {
+	// regedit writes UTF-16 with a byte order mark
+	var utf16le bytes.Buffer
+	utf16le.Write([]byte{0xff, 0xfe})
+	for _, u := range utf16.Encode([]rune(strings.ReplaceAll(puttyReg, "\n", "\r\n"))) {
+		utf16le.Write([]byte{byte(u), byte(u >> 8)})
+	}
+	want := []Host{
+		{Name: "prod-web", Hostname: "10.0.0.1", User: "deploy", Port: 22, IdentityFiles: []string{`C:\Users\me\.ssh\prod`}},
+		{Name: "db", Hostname: "10.0.0.2", User: "admin", Port: 2222, ProxyJump: "jump@bastion.example.com"},
+	}
+	for name, data := range map[string][]byte{"utf-8": []byte(puttyReg), "utf-16": utf16le.Bytes()} {
+		t.Run(name, func(t *testing.T) {
+			hosts, warnings, err := ParsePuTTY(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(hosts, want) {
+				t.Errorf("ParsePuTTY() =\n%+v\nwant\n%+v", hosts, want)
+			}
+			// the .ppk key, the port forwarding and the telnet session
+			if len(warnings) != 3 || !strings.Contains(warnings[0], "puttygen") || !strings.Contains(warnings[2], "switch: skipped") {
+				t.Errorf("warnings = %q", warnings)
+			}
+		})
+	}
+}
+
+func TestParseMobaXterm(t *testing.T) {
+	hosts, warnings, err := ParseMobaXterm(strings.NewReader(`[Bookmarks]
+SubRep=
+ImgNum=42
+web server=#109#0%10.0.0.1%22%deploy%%-1%-1%%%22%%0%0%0%_ProfileDir_\.ssh\id_rsa%%-1%0%0%0%%1080%%0%0%1#MobaFont%10%0%0%-1%15#0# #-1
+desktop=#91#4%10.0.0.9%3389%admin%0%-1%-1%-1%-1%0%0%-1#MobaFont%10#0# #-1
+
+[Bookmarks_1]
+SubRep=Prod\Databases
+ImgNum=41
+db=#109#0%10.0.0.2%2222%admin%%-1%-1%uptime%bastion%2200%jump%0%0%0%%%-1%0%0%0%%1080%%0%0%1#MobaFont%10#0# #-1
+
+[Passwords]
+ssh22:deploy@10.0.0.1=_@9jajOXK
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Host{
+		{Name: "web-server", Hostname: "10.0.0.1", User: "deploy", Port: 22, IdentityFiles: []string{`~\.ssh\id_rsa`}},
+		{Name: "db", Hostname: "10.0.0.2", User: "admin", Port: 2222, ProxyJump: "jump@bastion:2200", Tags: []string{"Prod", "Databases"}},
+	}
+	if !reflect.DeepEqual(hosts, want) {
+		t.Errorf("ParseMobaXterm() =\n%+v\nwant\n%+v", hosts, want)
+	}
+	// the RDP session, the remote command and the passwords
+	if len(warnings) != 3 || !strings.Contains(warnings[0], "desktop: skipped") {
+		t.Errorf("warnings = %q", warnings)
+	}
+}
+
+func TestParseSecureCRT(t *testing.T) {
+	hosts, warnings, err := ParseSecureCRT(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>
+<VanDyke version="3.0">
+  <key name="Sessions">
+    <key name="Default">
+      <string name="Hostname"></string>
+    </key>
+    <key name="Production">
+      <key name="bastion">
+        <string name="Hostname">203.0.113.1</string>
+        <string name="Protocol Name">SSH2</string>
+        <dword name="[SSH2] Port">22</dword>
+      </key>
+      <key name="web 01">
+        <string name="Hostname">10.0.0.1</string>
+        <string name="Username">deploy</string>
+        <string name="Protocol Name">SSH2</string>
+        <dword name="[SSH2] Port">2222</dword>
+        <string name="Identity Filename V2">C:\Users\me\.ssh\id_ed25519::rawkey</string>
+        <string name="Firewall Name">Session:Production\bastion</string>
+        <string name="Password V2">02:0123456789abcdef</string>
+        <array name="Port Forward Table V2">
+          <string>web|0.0.0.0,8080|0|localhost|80|</string>
+        </array>
+      </key>
+    </key>
+    <key name="console">
+      <string name="Hostname">10.0.0.3</string>
+      <string name="Protocol Name">Serial</string>
+    </key>
+  </key>
+</VanDyke>
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Host{
+		{Name: "bastion", Hostname: "203.0.113.1", Port: 22, Tags: []string{"Production"}},
+		{Name: "web-01", Hostname: "10.0.0.1", User: "deploy", Port: 2222, IdentityFiles: []string{`C:\Users\me\.ssh\id_ed25519`},
+			ProxyJump: "bastion", Tags: []string{"Production"}},
+	}
+	if !reflect.DeepEqual(hosts, want) {
+		t.Errorf("ParseSecureCRT() =\n%+v\nwant\n%+v", hosts, want)
+	}
+	// the password, the port forwarding and the serial session
+	if len(warnings) != 3 || !strings.Contains(warnings[2], "console: skipped") {
+		t.Errorf("warnings = %q", warnings)
+	}
+	if _, _, err := ParseSecureCRT(strings.NewReader(`<VanDyke version="3.0"></VanDyke>`)); err == nil {
+		t.Error("ParseSecureCRT() accepted an export without sessions")
+	}
+}
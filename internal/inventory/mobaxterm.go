@@ -0,0 +1,118 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Fields of the settings of a MobaXterm SSH bookmark, which are separated by %.
+const (
+	mobaType = iota
+	mobaHost
+	mobaPort
+	mobaUser
+	_
+	_
+	_
+	mobaCommand
+	mobaGatewayHost
+	mobaGatewayPort
+	mobaGatewayUser
+	_
+	_
+	_
+	mobaKey
+)
+
+// ParseMobaXterm reads the SSH bookmarks of MobaXterm.ini or a .mxtsessions
+// export. The folders of the bookmarks become tags.
+func ParseMobaXterm(r io.Reader) ([]Host, []string, error) {
+	text, err := readText(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		hosts       []Host
+		warnings    []string
+		inBookmarks bool
+		folder      string
+	)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section := strings.ToLower(line[1 : len(line)-1])
+			inBookmarks = section == "bookmarks" || strings.HasPrefix(section, "bookmarks_")
+			folder = ""
+			if section == "passwords" {
+				warnings = append(warnings, "the saved passwords aren't imported, ssh_config can't hold passwords")
+			}
+			continue
+		}
+		i := strings.Index(line, "=")
+		if !inBookmarks || i == -1 {
+			continue
+		}
+		name, value := line[:i], line[i+1:]
+		switch name {
+		case "SubRep":
+			folder = value
+			continue
+		case "ImgNum":
+			continue
+		}
+		// #icon#settings#terminal settings#...
+		parts := strings.Split(value, "#")
+		if len(parts) < 3 {
+			continue
+		}
+		f := strings.Split(parts[2], "%")
+		for len(f) <= mobaKey {
+			f = append(f, "")
+		}
+		if f[mobaType] != "0" {
+			warnings = append(warnings, fmt.Sprintf("%s: skipped, it isn't an SSH session", name))
+			continue
+		}
+		if f[mobaHost] == "" {
+			continue
+		}
+		h := Host{Name: sessionName(name), Hostname: f[mobaHost], User: f[mobaUser], Port: parsePort(f[mobaPort])}
+		for _, t := range strings.Split(folder, `\`) {
+			h.AddTags(sessionName(t))
+		}
+		if key := f[mobaKey]; key != "" {
+			// _ProfileDir_ is the home directory of the Windows user
+			h.IdentityFiles = []string{strings.Replace(key, "_ProfileDir_", "~", 1)}
+		}
+		if gw := f[mobaGatewayHost]; gw != "" {
+			if u := f[mobaGatewayUser]; u != "" {
+				gw = u + "@" + gw
+			}
+			if p := parsePort(f[mobaGatewayPort]); p != 0 && p != 22 {
+				gw += ":" + strconv.Itoa(p)
+			}
+			h.ProxyJump = gw
+		}
+		if f[mobaCommand] != "" {
+			warnings = append(warnings, fmt.Sprintf("%s: the remote command isn't imported", name))
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, warnings, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// puttySessionsKey is the registry key PuTTY keeps its saved sessions under.
+const puttySessionsKey = `HKEY_CURRENT_USER\Software\SimonTatham\PuTTY\Sessions\`
+
+// ParsePuTTY reads the saved sessions of a registry export of PuTTY, like
+// `reg export HKCU\Software\SimonTatham\PuTTY\Sessions putty.reg` writes it.
+func ParsePuTTY(r io.Reader) ([]Host, []string, error) {
+	text, err := readText(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		names    []string
+		sessions = map[string]map[string]string{}
+		values   map[string]string
+	)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			values = nil
+			key := line[1 : len(line)-1]
+			if len(key) <= len(puttySessionsKey) || !strings.EqualFold(key[:len(puttySessionsKey)], puttySessionsKey) {
+				continue
+			}
+			name := key[len(puttySessionsKey):]
+			if n, err := url.PathUnescape(name); err == nil {
+				name = n
+			}
+			if _, ok := sessions[name]; !ok {
+				names = append(names, name)
+				sessions[name] = map[string]string{}
+			}
+			values = sessions[name]
+			continue
+		}
+		if values == nil || !strings.HasPrefix(line, `"`) {
+			continue
+		}
+		if k, v, ok := parseRegValue(line); ok {
+			values[k] = v
+		}
+	}
+
+	var hosts []Host
+	var warnings []string
+	for _, name := range names {
+		v := sessions[name]
+		if v["HostName"] == "" {
+			continue
+		}
+		if p := v["Protocol"]; p != "" && p != "ssh" {
+			warnings = append(warnings, fmt.Sprintf("%s: skipped, it uses the %s protocol", name, p))
+			continue
+		}
+		h := Host{Name: sessionName(name), Hostname: v["HostName"], User: v["UserName"], Port: parsePort(v["PortNumber"])}
+		if i := strings.LastIndex(h.Hostname, "@"); i != -1 {
+			h.User, h.Hostname = h.Hostname[:i], h.Hostname[i+1:]
+		}
+		if key := v["PublicKeyFile"]; key != "" {
+			h.IdentityFiles = []string{key}
+			if strings.HasSuffix(strings.ToLower(key), ".ppk") {
+				converted := key[:len(key)-len(".ppk")]
+				h.IdentityFiles = []string{converted}
+				warnings = append(warnings, fmt.Sprintf("%s: ssh can't read the PuTTY key %s, convert it with `puttygen %s -O private-openssh -o %s`",
+					name, key, key, converted))
+			}
+		}
+		switch method := v["ProxyMethod"]; method {
+		case "", "0":
+		case "6": // SSH to the proxy and forward the port
+			h.ProxyJump = v["ProxyHost"]
+			if u := v["ProxyUsername"]; u != "" {
+				h.ProxyJump = u + "@" + h.ProxyJump
+			}
+			if p := parsePort(v["ProxyPort"]); p != 0 && p != 22 {
+				h.ProxyJump += ":" + strconv.Itoa(p)
+			}
+		default:
+			warnings = append(warnings, fmt.Sprintf("%s: the proxy %s isn't imported, only SSH proxies map to ProxyJump", name, v["ProxyHost"]))
+		}
+		if v["PortForwardings"] != "" {
+			warnings = append(warnings, fmt.Sprintf("%s: the port forwardings aren't imported, use `sshctx tunnel` instead", name))
+		}
+		if v["RemoteCommand"] != "" {
+			warnings = append(warnings, fmt.Sprintf("%s: the remote command isn't imported", name))
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, warnings, nil
+}
+
+// parseRegValue parses a `"name"="string"` or `"name"=dword:hex` line of a
+// registry export. Other kinds of values are left out.
+func parseRegValue(line string) (string, string, bool) {
+	name, rest, ok := regString(line)
+	if !ok || !strings.HasPrefix(rest, "=") {
+		return "", "", false
+	}
+	rest = rest[1:]
+	if strings.HasPrefix(rest, `"`) {
+		v, _, ok := regString(rest)
+		return name, v, ok
+	}
+	if strings.HasPrefix(strings.ToLower(rest), "dword:") {
+		n, err := strconv.ParseUint(rest[len("dword:"):], 16, 32)
+		return name, strconv.FormatUint(n, 10), err == nil
+	}
+	return "", "", false
+}
+
+// regString reads the quoted string s starts with, returning what follows it.
+func regString(s string) (string, string, bool) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			i++
+			b.WriteByte(s[i])
+		case c == '"':
+			return b.String(), s[i+1:], true
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", false
+}
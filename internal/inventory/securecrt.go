@@ -0,0 +1,129 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inventory
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// secureCRTKey is a key of the XML export of SecureCRT, which holds folders,
+// sessions and their settings.
+type secureCRTKey struct {
+	Name    string           `xml:"name,attr"`
+	Keys    []secureCRTKey   `xml:"key"`
+	Strings []secureCRTValue `xml:"string"`
+	Dwords  []secureCRTValue `xml:"dword"`
+	Arrays  []struct {
+		Name  string   `xml:"name,attr"`
+		Items []string `xml:"string"`
+	} `xml:"array"`
+}
+
+type secureCRTValue struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+// value returns a string or dword setting of the key.
+func (k secureCRTKey) value(name string) string {
+	for _, vs := range [][]secureCRTValue{k.Strings, k.Dwords} {
+		for _, v := range vs {
+			if v.Name == name {
+				return strings.TrimSpace(v.Value)
+			}
+		}
+	}
+	return ""
+}
+
+// ParseSecureCRT reads the sessions of an XML export of SecureCRT. The
+// folders of the sessions become tags.
+func ParseSecureCRT(r io.Reader) ([]Host, []string, error) {
+	text, err := readText(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	d := xml.NewDecoder(strings.NewReader(text))
+	// readText already decoded the text, whatever the declaration says
+	d.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) { return r, nil }
+	var root secureCRTKey
+	if err := d.Decode(&root); err != nil {
+		return nil, nil, errors.Wrap(err, "can't parse SecureCRT export")
+	}
+	var sessions *secureCRTKey
+	for i, k := range root.Keys {
+		if k.Name == "Sessions" {
+			sessions = &root.Keys[i]
+		}
+	}
+	if sessions == nil {
+		return nil, nil, errors.New("SecureCRT export has no sessions")
+	}
+
+	var hosts []Host
+	var warnings []string
+	var walk func(k secureCRTKey, folders []string)
+	walk = func(k secureCRTKey, folders []string) {
+		if k.value("Hostname") == "" {
+			for _, c := range k.Keys {
+				walk(c, append(folders[:len(folders):len(folders)], k.Name))
+			}
+			return
+		}
+		name := k.Name
+		if p := k.value("Protocol Name"); p != "" && p != "SSH2" {
+			warnings = append(warnings, fmt.Sprintf("%s: skipped, it uses the %s protocol", name, p))
+			return
+		}
+		h := Host{Name: sessionName(name), Hostname: k.value("Hostname"), User: k.value("Username"), Port: parsePort(k.value("[SSH2] Port"))}
+		for _, f := range folders {
+			h.AddTags(sessionName(f))
+		}
+		// the path is followed by the kind of key, like ::rawkey
+		if key := k.value("Identity Filename V2"); key != "" {
+			if i := strings.Index(key, "::"); i != -1 {
+				key = key[:i]
+			}
+			h.IdentityFiles = []string{key}
+		}
+		switch fw := k.value("Firewall Name"); {
+		case fw == "" || fw == "None":
+		case strings.HasPrefix(fw, "Session:"):
+			// another session is the jump host
+			jump := fw[len("Session:"):]
+			h.ProxyJump = sessionName(jump[strings.LastIndex(jump, `\`)+1:])
+		default:
+			warnings = append(warnings, fmt.Sprintf("%s: the firewall %s isn't imported, only sessions map to ProxyJump", name, fw))
+		}
+		if k.value("Password V2") != "" || k.value("Password") != "" {
+			warnings = append(warnings, fmt.Sprintf("%s: the password isn't imported, ssh_config can't hold passwords", name))
+		}
+		for _, a := range k.Arrays {
+			if a.Name == "Port Forward Table V2" && len(a.Items) > 0 {
+				warnings = append(warnings, fmt.Sprintf("%s: the port forwardings aren't imported, use `sshctx tunnel` instead", name))
+			}
+		}
+		hosts = append(hosts, h)
+	}
+	for _, k := range sessions.Keys {
+		walk(k, nil)
+	}
+	return hosts, warnings, nil
+}
@@ -0,0 +1,257 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package knownhosts
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// hashPrefix starts hostnames hashed by `ssh-keygen -H` or HashKnownHosts.
+const hashPrefix = "|1|"
+
+// Entry is a line of a known_hosts file with a host key.
+type Entry struct {
+	Line     int      // 1-based
+	Marker   string   // "@cert-authority", "@revoked" or empty
+	Patterns []string // hostname patterns as written, possibly hashed or negated
+	Key      ssh.PublicKey
+}
+
+// File is a parsed known_hosts file.
+type File struct {
+	Path    string
+	Entries []Entry
+	lines   []string
+}
+
+// Load reads the known_hosts file at path. Lines that aren't valid entries
+// are kept but not returned in Entries.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read known_hosts")
+	}
+	f := &File{Path: path}
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(nil, 1024*1024)
+	for s.Scan() {
+		f.lines = append(f.lines, s.Text())
+		if e, ok := parseLine(s.Text()); ok {
+			e.Line = len(f.lines)
+			f.Entries = append(f.Entries, e)
+		}
+	}
+	return f, errors.Wrapf(s.Err(), "can't read %s", path)
+}
+
+func parseLine(line string) (Entry, bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+		return Entry{}, false
+	}
+	var e Entry
+	if strings.HasPrefix(fields[0], "@") {
+		e.Marker, fields = fields[0], fields[1:]
+	}
+	if len(fields) < 3 {
+		return Entry{}, false
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(strings.Join(fields[1:], " ")))
+	if err != nil {
+		return Entry{}, false
+	}
+	e.Patterns = strings.Split(fields[0], ",")
+	e.Key = key
+	return e, true
+}
+
+// Normalize returns how a host is written in known_hosts: `host` for port
+// 22, `[host]:port` otherwise.
+func Normalize(host string, port int) string {
+	if port == 0 || port == 22 {
+		return host
+	}
+	return "[" + host + "]:" + strconv.Itoa(port)
+}
+
+// Hashed returns whether the hostnames of the entry are hashed.
+func (e Entry) Hashed() bool {
+	for _, p := range e.Patterns {
+		if strings.HasPrefix(p, hashPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches returns whether the entry applies to name, a hostname as
+// returned by Normalize. Like ssh(1), negated patterns win.
+func (e Entry) Matches(name string) bool {
+	matched := false
+	for _, p := range e.Patterns {
+		negated := strings.HasPrefix(p, "!")
+		if matchPattern(strings.TrimPrefix(p, "!"), name) {
+			if negated {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched
+}
+
+// MatchesExactly returns whether the entry was written for name itself,
+// as a plain or hashed hostname rather than a wildcard pattern.
+func (e Entry) MatchesExactly(name string) bool {
+	for _, p := range e.Patterns {
+		if !strings.ContainsAny(p, "*?!") && matchPattern(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSpecific returns whether the entry is the key of specific hosts, i.e. a
+// plain entry without wildcards, which can become stale.
+func (e Entry) IsSpecific() bool {
+	if e.Marker != "" {
+		return false
+	}
+	for _, p := range e.Patterns {
+		if !strings.HasPrefix(p, hashPrefix) && strings.ContainsAny(p, "*?!") {
+			return false
+		}
+	}
+	return true
+}
+
+func matchPattern(pattern, name string) bool {
+	if strings.HasPrefix(pattern, hashPrefix) {
+		return matchHashed(pattern, name)
+	}
+	return wildcardMatch(strings.ToLower(pattern), strings.ToLower(name))
+}
+
+// matchHashed checks name against `|1|salt|hash`, where hash is the
+// HMAC-SHA1 of name keyed with salt.
+func matchHashed(hashed, name string) bool {
+	parts := strings.Split(strings.TrimPrefix(hashed, hashPrefix), "|")
+	if len(parts) != 2 {
+		return false
+	}
+	salt, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+	want, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(name))
+	return hmac.Equal(mac.Sum(nil), want)
+}
+
+// wildcardMatch matches the `*` and `?` wildcards of ssh_config(5). Unlike
+// path.Match, `[` has no special meaning since it starts `[host]:port`.
+func wildcardMatch(pattern, name string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(name); i >= 0; i-- {
+				if wildcardMatch(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if name == "" {
+				return false
+			}
+		default:
+			if name == "" || name[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return name == ""
+}
+
+// Remove deletes the lines of entries from the file, keeping the previous
+// contents in `<path>.old` like ssh-keygen -R.
+func (f *File) Remove(entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	remove := map[int]bool{}
+	for _, e := range entries {
+		remove[e.Line] = true
+	}
+	var b strings.Builder
+	for i, l := range f.lines {
+		if !remove[i+1] {
+			b.WriteString(l)
+			b.WriteByte('\n')
+		}
+	}
+
+	fi, err := os.Stat(f.Path)
+	if err != nil {
+		return errors.Wrap(err, "can't read known_hosts")
+	}
+	old, err := os.ReadFile(f.Path)
+	if err != nil {
+		return errors.Wrap(err, "can't read known_hosts")
+	}
+	if err := os.WriteFile(f.Path+".old", old, fi.Mode().Perm()); err != nil {
+		return errors.Wrap(err, "can't back up known_hosts")
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), filepath.Base(f.Path)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "can't write known_hosts")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		_ = tmp.Close()
+		return errors.Wrap(err, "can't write known_hosts")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "can't write known_hosts")
+	}
+	if err := os.Chmod(tmp.Name(), fi.Mode().Perm()); err != nil {
+		return errors.Wrap(err, "can't write known_hosts")
+	}
+	if err := os.Rename(tmp.Name(), f.Path); err != nil {
+		return errors.Wrap(err, "can't write known_hosts")
+	}
+	reloaded, err := Load(f.Path)
+	if err != nil {
+		return err
+	}
+	*f = *reloaded
+	return nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package knownhosts
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func writeFile(t *testing.T, lines ...string) string {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyText := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	content := strings.ReplaceAll(strings.Join(lines, "\n")+"\n", "KEY", keyText)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEntry_Matches(t *testing.T) {
+	f, err := Load(writeFile(t,
+		"# comment",
+		"web.example.com,10.0.0.1 KEY",
+		"[db.example.com]:2222 KEY",
+		"*.internal,!secret.internal KEY",
+		knownhosts.HashHostname("hashed.example.com")+" KEY",
+		"@cert-authority *.example.com KEY",
+		"not a valid line",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Entries) != 5 {
+		t.Fatalf("Load() found %d entries, want 5", len(f.Entries))
+	}
+	web, db, wildcard, hashed, ca := f.Entries[0], f.Entries[1], f.Entries[2], f.Entries[3], f.Entries[4]
+
+	tests := []struct {
+		name  string
+		entry Entry
+		host  string
+		want  bool
+	}{
+		{name: "plain", entry: web, host: "web.example.com", want: true},
+		{name: "second-name", entry: web, host: "10.0.0.1", want: true},
+		{name: "case-insensitive", entry: web, host: "WEB.example.com", want: true},
+		{name: "other-host", entry: web, host: "db.example.com"},
+		{name: "port", entry: db, host: Normalize("db.example.com", 2222), want: true},
+		{name: "wrong-port", entry: db, host: Normalize("db.example.com", 22)},
+		{name: "wildcard", entry: wildcard, host: "app.internal", want: true},
+		{name: "negated", entry: wildcard, host: "secret.internal"},
+		{name: "hashed", entry: hashed, host: "hashed.example.com", want: true},
+		{name: "hashed-other", entry: hashed, host: "web.example.com"},
+		{name: "cert-authority", entry: ca, host: "web.example.com", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.entry.Matches(tt.host); got != tt.want {
+				t.Errorf("Matches(%s) = %v, want %v", tt.host, got, tt.want)
+			}
+		})
+	}
+
+	if !hashed.Hashed() || web.Hashed() {
+		t.Error("Hashed() is wrong")
+	}
+	if !web.IsSpecific() || !hashed.IsSpecific() || wildcard.IsSpecific() || ca.IsSpecific() {
+		t.Error("IsSpecific() is wrong")
+	}
+	if wildcard.MatchesExactly("app.internal") || !hashed.MatchesExactly("hashed.example.com") {
+		t.Error("MatchesExactly() is wrong")
+	}
+}
+
+func TestFile_Remove(t *testing.T) {
+	path := writeFile(t, "# keep me", "a KEY", "b KEY", "c KEY")
+	f, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Remove([]Entry{f.Entries[0], f.Entries[2]}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 2 || lines[0] != "# keep me" || !strings.HasPrefix(lines[1], "b ") {
+		t.Errorf("known_hosts after Remove() =\n%s", data)
+	}
+	if len(f.Entries) != 1 || f.Entries[0].Line != 2 {
+		t.Errorf("Entries after Remove() = %+v", f.Entries)
+	}
+	old, err := os.ReadFile(path + ".old")
+	if err != nil || strings.Count(string(old), "\n") != 4 {
+		t.Errorf("%s.old = %q, %v, want the previous contents", path, old, err)
+	}
+}
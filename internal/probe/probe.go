@@ -0,0 +1,137 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package probe
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/cmdutil"
+)
+
+// maxPreBannerLines is how many lines a server may send before its
+// identification string (RFC 4253, section 4.2).
+const maxPreBannerLines = 16
+
+// Dialer opens network connections, *net.Dialer satisfies it.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Result is the outcome of probing one SSH server.
+type Result struct {
+	Addr    string
+	Up      bool          // a valid SSH banner was received
+	Banner  string        // e.g. SSH-2.0-OpenSSH_9.2p1 Debian-2
+	Latency time.Duration // time to establish the TCP connection
+	Err     error
+}
+
+// Version returns the software version of the banner, e.g. OpenSSH_9.2p1.
+func (r Result) Version() string {
+	parts := strings.SplitN(r.Banner, "-", 3)
+	if len(parts) < 3 {
+		return ""
+	}
+	if fields := strings.Fields(parts[2]); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// Prober checks that SSH servers are reachable.
+type Prober struct {
+	Dialer  Dialer
+	Timeout time.Duration // for dialing and reading the banner of a server
+}
+
+// New returns a Prober dialing with the standard library.
+func New(timeout time.Duration) *Prober {
+	return &Prober{Dialer: new(net.Dialer), Timeout: timeout}
+}
+
+// Probe connects to addr and reads the SSH protocol banner.
+func (p *Prober) Probe(ctx context.Context, addr string) Result {
+	r := Result{Addr: addr}
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := p.Dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	r.Latency = time.Since(start)
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetReadDeadline(deadline)
+	}
+	reader := bufio.NewReader(conn)
+	for i := 0; i < maxPreBannerLines; i++ {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "SSH-") {
+			r.Banner = line
+			r.Up = true
+			return r
+		}
+		if err != nil {
+			r.Err = errors.Wrap(err, "no SSH banner")
+			return r
+		}
+	}
+	r.Err = errors.New("no SSH banner")
+	return r
+}
+
+// ProbeAll probes the addresses concurrently. Results are in the order of addrs.
+func (p *Prober) ProbeAll(ctx context.Context, addrs []string) []Result {
+	results := make([]Result, len(addrs))
+	var wg sync.WaitGroup
+	for i, addr := range addrs {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			results[i] = p.Probe(ctx, addr)
+		}(i, addr)
+	}
+	wg.Wait()
+	return results
+}
+
+// Wait probes addr until it sends an SSH banner or ctx is done,
+// sleeping between attempts as told by backoff.
+func (p *Prober) Wait(ctx context.Context, addr string, backoff *cmdutil.Backoff) (Result, error) {
+	for {
+		r := p.Probe(ctx, addr)
+		if r.Up {
+			return r, nil
+		}
+		select {
+		case <-ctx.Done():
+			return r, ctx.Err()
+		case <-time.After(backoff.Next()):
+		}
+	}
+}
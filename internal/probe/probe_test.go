@@ -0,0 +1,144 @@
+package probe
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/spencercjh/sshctx/internal/cmdutil"
+)
+
+// serve accepts connections on a local listener and greets them with greeting.
+func serve(t *testing.T, greeting string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte(greeting))
+			go func() {
+				// keep the connection open like a server waiting for the client banner
+				time.Sleep(time.Second)
+				_ = conn.Close()
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+// closedAddr returns an address nothing listens on.
+func closedAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+	return addr
+}
+
+func TestProber_ProbeAll(t *testing.T) {
+	addrs := []string{
+		serve(t, "SSH-2.0-OpenSSH_9.2p1 Debian-2\r\n"),
+		serve(t, "Welcome to the bastion\r\nSSH-2.0-dropbear_2022.83\r\n"),
+		serve(t, "HTTP/1.1 400 Bad Request\r\n\r\n"),
+		serve(t, ""),
+		closedAddr(t),
+	}
+	want := []struct {
+		up      bool
+		version string
+	}{
+		{up: true, version: "OpenSSH_9.2p1"},
+		{up: true, version: "dropbear_2022.83"},
+		{up: false},
+		{up: false},
+		{up: false},
+	}
+
+	p := New(200 * time.Millisecond)
+	results := p.ProbeAll(context.Background(), addrs)
+	for i, r := range results {
+		if r.Addr != addrs[i] {
+			t.Errorf("result %d: Addr = %s, want %s", i, r.Addr, addrs[i])
+		}
+		if r.Up != want[i].up || r.Version() != want[i].version {
+			t.Errorf("result %d: Up = %v, Version() = %q, want %v, %q (err: %v)", i, r.Up, r.Version(), want[i].up, want[i].version, r.Err)
+		}
+		if !r.Up && r.Err == nil {
+			t.Errorf("result %d: a down server should have an error", i)
+		}
+	}
+}
+
+type recordingDialer struct {
+	dialed []string
+}
+
+func (d *recordingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d.dialed = append(d.dialed, address)
+	server, client := net.Pipe()
+	go func() {
+		_, _ = server.Write([]byte("SSH-2.0-Fake\r\n"))
+		_ = server.Close()
+	}()
+	return client, nil
+}
+
+func TestProber_Probe_injectedDialer(t *testing.T) {
+	d := new(recordingDialer)
+	p := &Prober{Dialer: d, Timeout: time.Second}
+	r := p.Probe(context.Background(), "10.115.40.98:22")
+	if !r.Up || r.Version() != "Fake" {
+		t.Errorf("Probe() = %+v", r)
+	}
+	if len(d.dialed) != 1 || d.dialed[0] != "10.115.40.98:22" {
+		t.Errorf("dialed %v", d.dialed)
+	}
+}
+
+func TestProber_Wait(t *testing.T) {
+	backoff := func() *cmdutil.Backoff {
+		return &cmdutil.Backoff{Initial: 20 * time.Millisecond, Max: 100 * time.Millisecond}
+	}
+
+	t.Run("comes-up", func(t *testing.T) {
+		addr := closedAddr(t)
+		go func() {
+			time.Sleep(200 * time.Millisecond)
+			l, err := net.Listen("tcp", addr)
+			if err != nil {
+				return
+			}
+			defer func() { _ = l.Close() }()
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte("SSH-2.0-OpenSSH_9.2p1\r\n"))
+			_ = conn.Close()
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		r, err := New(time.Second).Wait(ctx, addr, backoff())
+		if err != nil || !r.Up {
+			t.Errorf("Wait() = %+v, %v", r, err)
+		}
+	})
+
+	t.Run("times-out", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+		defer cancel()
+		r, err := New(time.Second).Wait(ctx, closedAddr(t), backoff())
+		if err != context.DeadlineExceeded || r.Up || r.Err == nil {
+			t.Errorf("Wait() = %+v, %v; want the last failure and DeadlineExceeded", r, err)
+		}
+	})
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+// Names of the providers.
+const (
+	Vagrant = "vagrant"
+	Docker  = "docker"
+)
+
+// Target is a virtual machine or container run by a provider.
+type Target struct {
+	Provider string
+	Name     string // name of the Vagrant machine or the container
+	Project  string // name of the Vagrant project, its directory name by default
+	ID       string // ID of the container
+	Host     string // address of the Vagrant machine
+	User     string
+	Port     int
+	Config   string // ssh_config of the Vagrant machines of the project, as written by `vagrant ssh-config`
+}
+
+// DisplayName names the target in the list, `project/machine` for Vagrant.
+func (t Target) DisplayName() string {
+	if t.Project != "" {
+		return t.Project + "/" + t.Name
+	}
+	return t.Name
+}
+
+// VagrantMachines returns the running machines of the Vagrant project in dir,
+// which should be absolute.
+func VagrantMachines(dir string) ([]Target, error) {
+	cmd := exec.Command("vagrant", "ssh-config")
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	// machines that aren't running make it fail, but the others are still printed
+	if err != nil && len(out) == 0 {
+		return nil, errors.Wrapf(err, "vagrant ssh-config failed in %s: %s", dir, strings.TrimSpace(stderr.String()))
+	}
+	blocks, err := sshconfig.ParseBlocks(bytes.NewReader(out))
+	if err != nil {
+		return nil, errors.Wrap(err, "can't parse vagrant ssh-config")
+	}
+	var targets []Target
+	for _, b := range blocks {
+		if b.Line == 0 || b.Match != "" || len(b.Patterns) != 1 {
+			continue
+		}
+		t := Target{Provider: Vagrant, Name: b.Patterns[0], Project: filepath.Base(dir), Config: string(out)}
+		for _, o := range b.Options {
+			switch strings.ToLower(o.Key) {
+			case "hostname":
+				t.Host = o.Value
+			case "user":
+				t.User = o.Value
+			case "port":
+				t.Port, _ = strconv.Atoi(o.Value)
+			}
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// DockerContainers returns the running Docker containers.
+func DockerContainers() ([]Target, error) {
+	// the same as `--format json`, which older versions don't know
+	cmd := exec.Command("docker", "ps", "--format", "{{json .}}")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "docker ps failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	var targets []Target
+	d := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var c struct {
+			ID    string
+			Names string
+		}
+		if err := d.Decode(&c); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "can't parse docker ps")
+		}
+		name := c.Names
+		if i := strings.Index(name, ","); i != -1 {
+			name = name[:i]
+		}
+		if name == "" {
+			name = c.ID
+		}
+		targets = append(targets, Target{Provider: Docker, Name: name, ID: c.ID})
+	}
+	return targets, nil
+}
+
+// Command returns the command line opening a shell on the target. The
+// Config of a Vagrant machine has to be saved to configFile.
+func (t Target) Command(configFile string) []string {
+	if t.Provider == Docker {
+		return []string{"docker", "exec", "-it", t.ID, "sh"}
+	}
+	return []string{"ssh", "-F", configFile, "-t", "-t", t.Name}
+}
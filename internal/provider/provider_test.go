@@ -0,0 +1,103 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+const vagrantSSHConfig = `Host web
+  HostName 127.0.0.1
+  User vagrant
+  Port 2222
+  UserKnownHostsFile /dev/null
+  IdentityFile /lab/.vagrant/machines/web/virtualbox/private_key
+
+Host db
+  HostName 127.0.0.1
+  User vagrant
+  Port 2200
+`
+
+// fakeCommand puts a shell script named name first in PATH.
+func fakeCommand(t *testing.T, name, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake commands are shell scripts")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestVagrantMachines(t *testing.T) {
+	// a machine that isn't running makes vagrant fail after printing the others
+	fakeCommand(t, "vagrant", "pwd >&2\ncat <<'EOF'\n"+vagrantSSHConfig+"EOF\nexit 1\n")
+	dir := filepath.Join(t.TempDir(), "lab")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	got, err := VagrantMachines(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Target{
+		{Provider: Vagrant, Name: "web", Project: "lab", Host: "127.0.0.1", User: "vagrant", Port: 2222, Config: vagrantSSHConfig},
+		{Provider: Vagrant, Name: "db", Project: "lab", Host: "127.0.0.1", User: "vagrant", Port: 2200, Config: vagrantSSHConfig},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("VagrantMachines() =\n%+v\nwant\n%+v", got, want)
+	}
+	if got[0].DisplayName() != "lab/web" {
+		t.Errorf("DisplayName() = %q", got[0].DisplayName())
+	}
+
+	fakeCommand(t, "vagrant", "echo 'A Vagrant environment or target machine is required' >&2\nexit 1\n")
+	if _, err := VagrantMachines(dir); err == nil {
+		t.Error("VagrantMachines() didn't fail without machines")
+	}
+}
+
+func TestDockerContainers(t *testing.T) {
+	fakeCommand(t, "docker", `[ "$1 $2 $3" = "ps --format {{json .}}" ] || exit 2
+echo '{"ID":"3f2a1b9c0d12","Image":"nginx","Names":"web-1","Status":"Up 2 hours"}'
+echo '{"ID":"9e8d7c6b5a43","Image":"redis","Names":"cache,cache-alias","Status":"Up 5 minutes"}'
+`)
+	got, err := DockerContainers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Target{
+		{Provider: Docker, Name: "web-1", ID: "3f2a1b9c0d12"},
+		{Provider: Docker, Name: "cache", ID: "9e8d7c6b5a43"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DockerContainers() = %+v, want %+v", got, want)
+	}
+	if cmd := got[0].Command(""); !reflect.DeepEqual(cmd, []string{"docker", "exec", "-it", "3f2a1b9c0d12", "sh"}) {
+		t.Errorf("Command() = %q", cmd)
+	}
+
+	fakeCommand(t, "docker", "echo 'Cannot connect to the Docker daemon' >&2\nexit 1\n")
+	if _, err := DockerContainers(); err == nil {
+		t.Error("DockerContainers() didn't fail without a daemon")
+	}
+}
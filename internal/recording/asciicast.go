@@ -0,0 +1,211 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Header is the first line of an asciicast v2 file.
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Event types of asciicast v2.
+const (
+	Output = "o"
+	Input  = "i"
+	Resize = "r"
+)
+
+// Event is a line of an asciicast v2 file after the header.
+type Event struct {
+	Time float64 // seconds since the start of the recording
+	Type string
+	Data string
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.Time, e.Type, e.Data})
+}
+
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if len(fields) != 3 {
+		return errors.Errorf("event with %d fields, want 3", len(fields))
+	}
+	if err := json.Unmarshal(fields[0], &e.Time); err != nil {
+		return errors.Wrap(err, "invalid event time")
+	}
+	if err := json.Unmarshal(fields[1], &e.Type); err != nil {
+		return errors.Wrap(err, "invalid event type")
+	}
+	return errors.Wrap(json.Unmarshal(fields[2], &e.Data), "invalid event data")
+}
+
+// Writer writes an asciicast v2 recording. It is safe for concurrent use.
+type Writer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	start   time.Time
+	pending map[string][]byte // incomplete UTF-8 sequences at the end of the last write
+}
+
+// NewWriter writes the header to w and starts the clock of the recording.
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	h.Version = 2
+	data, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return nil, errors.Wrap(err, "failed to write recording header")
+	}
+	return &Writer{w: w, start: time.Now(), pending: map[string][]byte{}}, nil
+}
+
+// WriteOutput records p as printed by the session.
+func (w *Writer) WriteOutput(p []byte) error {
+	return w.write(Output, p)
+}
+
+// WriteInput records p as typed by the user.
+func (w *Writer) WriteInput(p []byte) error {
+	return w.write(Input, p)
+}
+
+// WriteResize records the terminal changing its size.
+func (w *Writer) WriteResize(cols, rows int) error {
+	return w.write(Resize, []byte(strconv.Itoa(cols)+"x"+strconv.Itoa(rows)))
+}
+
+func (w *Writer) write(typ string, p []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// a read may end in the middle of a character, keep its first bytes
+	// for the next event so the data stays valid UTF-8
+	buf := append(w.pending[typ], p...)
+	n := completeUTF8(buf)
+	w.pending[typ] = append([]byte(nil), buf[n:]...)
+	if n == 0 {
+		return nil
+	}
+	e := Event{Time: time.Since(w.start).Round(time.Microsecond).Seconds(), Type: typ, Data: string(buf[:n])}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = w.w.Write(append(data, '\n'))
+	return err
+}
+
+// completeUTF8 returns the length of p without an incomplete character at its end.
+func completeUTF8(p []byte) int {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if utf8.FullRune(p[i:]) {
+				return len(p)
+			}
+			return i
+		}
+	}
+	return len(p)
+}
+
+// Reader reads an asciicast v2 recording.
+type Reader struct {
+	Header Header
+	r      *bufio.Reader
+}
+
+// NewReader reads the header of the recording.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	line, err := br.ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return nil, errors.Wrap(err, "failed to read recording header")
+	}
+	var h Header
+	if err := json.Unmarshal(line, &h); err != nil {
+		return nil, errors.Wrap(err, "invalid recording header")
+	}
+	if h.Version != 2 {
+		return nil, errors.Errorf("unsupported asciicast version %d", h.Version)
+	}
+	return &Reader{Header: h, r: br}, nil
+}
+
+// Next returns the next event, or io.EOF at the end of the recording.
+func (r *Reader) Next() (Event, error) {
+	for {
+		line, err := r.r.ReadBytes('\n')
+		if len(line) == 0 || (len(line) == 1 && line[0] == '\n') {
+			if err == nil {
+				continue
+			}
+			return Event{}, err
+		}
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return Event{}, errors.Wrap(err, "invalid recording event")
+		}
+		return e, nil
+	}
+}
+
+// Play writes the output of the recording to out at its original speed,
+// calling sleep to wait between events.
+func Play(out io.Writer, r io.Reader, sleep func(time.Duration)) error {
+	rec, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+	last := 0.0
+	for {
+		e, err := rec.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if e.Type != Output {
+			continue
+		}
+		if d := e.Time - last; d > 0 {
+			sleep(time.Duration(d * float64(time.Second)))
+		}
+		last = e.Time
+		if _, err := io.WriteString(out, e.Data); err != nil {
+			return err
+		}
+	}
+}
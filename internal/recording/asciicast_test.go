@@ -0,0 +1,74 @@
+package recording
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Width: 80, Height: 24, Title: "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	euro := []byte("€") // 3 bytes
+	_ = w.WriteOutput([]byte("price: "))
+	_ = w.WriteOutput(euro[:1])
+	_ = w.WriteInput([]byte("q"))
+	_ = w.WriteOutput(euro[1:])
+	_ = w.WriteResize(100, 30)
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Header.Version != 2 || r.Header.Width != 80 || r.Header.Title != "web" {
+		t.Errorf("Header = %+v", r.Header)
+	}
+	var got []string
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, e.Type+":"+e.Data)
+	}
+	// the split character is only written once it's complete
+	want := []string{"o:price: ", "i:q", "o:€", "r:100x30"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestPlay(t *testing.T) {
+	cast := `{"version": 2, "width": 80, "height": 24}
+[0.5, "o", "hello "]
+[0.6, "i", "ls\r"]
+
+[2.0, "o", "world\r\n"]
+`
+	var out bytes.Buffer
+	var slept []time.Duration
+	err := Play(&out, strings.NewReader(cast), func(d time.Duration) {
+		slept = append(slept, d)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "hello world\r\n" {
+		t.Errorf("played %q", out.String())
+	}
+	want := []time.Duration{500 * time.Millisecond, 1500 * time.Millisecond}
+	if len(slept) != len(want) || slept[0] != want[0] || slept[1] != want[1] {
+		t.Errorf("slept %v, want %v", slept, want)
+	}
+
+	if err := Play(io.Discard, strings.NewReader(`{"version": 1}`), func(time.Duration) {}); err == nil {
+		t.Errorf("Play() of asciicast v1 should fail")
+	}
+}
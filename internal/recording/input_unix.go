@@ -0,0 +1,61 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package recording
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// pollInterval is how often waitInput checks whether the session is done, in milliseconds.
+const pollInterval = 100
+
+// waitInput waits until f has input to read, returning false if done is
+// closed first, so that no input is read for a session that is over.
+func waitInput(f *os.File, done <-chan struct{}) bool {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return true
+	}
+	for {
+		select {
+		case <-done:
+			return false
+		default:
+		}
+		var n int
+		var pollErr error
+		err := rc.Control(func(fd uintptr) {
+			n, pollErr = unix.Poll([]unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}, pollInterval)
+		})
+		switch {
+		case err == nil && (pollErr == unix.EINTR || n == 0):
+			continue
+		case err != nil || pollErr != nil:
+			// can't poll f, let Read block
+			return true
+		}
+		select {
+		case <-done:
+			return false
+		default:
+			return true
+		}
+	}
+}
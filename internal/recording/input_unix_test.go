@@ -0,0 +1,61 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package recording
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestSession_Run_leavesLaterInput(t *testing.T) {
+	rec, err := NewWriter(io.Discard, Header{Width: 80, Height: 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, typing, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = in.Close()
+		_ = typing.Close()
+	}()
+	s, err := Start(exec.Command("sh", "-c", "exit 0"), rec, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Run(in, io.Discard); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	// a keystroke for whatever runs after the session, like a reconnection
+	if _, err := typing.Write([]byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(3 * pollInterval * time.Millisecond)
+	buf := make([]byte, 1)
+	if err := in.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := in.Read(buf); n != 1 || buf[0] != 'x' {
+		t.Errorf("Read() after Run() = %q, %v, want the keystroke", buf[:n], err)
+	}
+}
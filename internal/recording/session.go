@@ -0,0 +1,107 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recording
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/creack/pty"
+	"github.com/pkg/errors"
+)
+
+// Session is a process running on a pseudo-terminal whose output is
+// copied to the terminal of the user and recorded.
+type Session struct {
+	cmd         *exec.Cmd
+	pty         *os.File
+	rec         *Writer
+	recordInput bool
+}
+
+// Start runs cmd on a new pseudo-terminal of the given size, recording to rec.
+// A nil size leaves the pseudo-terminal at its default size.
+func Start(cmd *exec.Cmd, rec *Writer, recordInput bool, size *pty.Winsize) (*Session, error) {
+	f, err := pty.StartWithSize(cmd, size)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to start recorded session")
+	}
+	return &Session{cmd: cmd, pty: f, rec: rec, recordInput: recordInput}, nil
+}
+
+// Resize changes the size of the pseudo-terminal.
+func (s *Session) Resize(size *pty.Winsize) error {
+	if err := pty.Setsize(s.pty, size); err != nil {
+		return err
+	}
+	return s.rec.WriteResize(int(size.Cols), int(size.Rows))
+}
+
+// Run copies in to the session and its output to out until the process
+// exits, then returns the error of the process. Input typed after that is
+// left unread when in is a file like os.Stdin, for whatever reads it next.
+func (s *Session) Run(in io.Reader, out io.Writer) error {
+	done := make(chan struct{})
+	go s.copyInput(in, done)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := s.pty.Read(buf)
+			if n > 0 {
+				_ = s.rec.WriteOutput(buf[:n])
+				_, _ = out.Write(buf[:n])
+			}
+			if err != nil {
+				// EIO once the process exited and its side of the pty is closed
+				return
+			}
+		}
+	}()
+
+	err := s.cmd.Wait()
+	close(done)
+	wg.Wait()
+	_ = s.pty.Close()
+	return err
+}
+
+// copyInput copies in to the pty until done is closed or in ends.
+func (s *Session) copyInput(in io.Reader, done <-chan struct{}) {
+	f, isFile := in.(*os.File)
+	buf := make([]byte, 4096)
+	for {
+		if isFile && !waitInput(f, done) {
+			return
+		}
+		n, err := in.Read(buf)
+		if n > 0 {
+			if s.recordInput {
+				_ = s.rec.WriteInput(buf[:n])
+			}
+			if _, err := s.pty.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package recording
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSession_Run(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no pseudo-terminals on windows")
+	}
+	var cast bytes.Buffer
+	rec, err := NewWriter(&cast, Header{Width: 80, Height: 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Start(exec.Command("sh", "-c", "read line; echo \"got $line\"; exit 3"), rec, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	err = s.Run(strings.NewReader("ping\n"), &out)
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 3 {
+		t.Errorf("Run() = %v, want exit status 3", err)
+	}
+	if !strings.Contains(out.String(), "got ping") {
+		t.Errorf("output = %q", out.String())
+	}
+
+	r, err := NewReader(&cast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recorded, typed string
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		switch e.Type {
+		case Output:
+			recorded += e.Data
+		case Input:
+			typed += e.Data
+		}
+	}
+	if recorded != out.String() || typed != "ping\n" {
+		t.Errorf("recorded output %q and input %q", recorded, typed)
+	}
+}
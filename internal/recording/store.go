@@ -0,0 +1,111 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recording
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+// timeLayout names recording files, without colons so it's a valid file name everywhere.
+const timeLayout = "20060102T150405"
+
+// Entry is a recording kept in the recordings directory.
+type Entry struct {
+	Host  string
+	Path  string
+	Start time.Time
+}
+
+// Duration reads the recording to find the time of its last event.
+func (e Entry) Duration() (time.Duration, error) {
+	f, err := os.Open(e.Path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+	r, err := NewReader(f)
+	if err != nil {
+		return 0, err
+	}
+	last := 0.0
+	for {
+		ev, err := r.Next()
+		if err == io.EOF {
+			return time.Duration(last * float64(time.Second)).Round(time.Second), nil
+		} else if err != nil {
+			return 0, err
+		}
+		last = ev.Time
+	}
+}
+
+// Dir returns the directory recordings are kept in: ~/.sshctx/recordings.
+func Dir() (string, error) {
+	dir, err := sshconfig.GetSSHCtxDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "recordings"), nil
+}
+
+// Create creates the file of a new recording of host started at t.
+func Create(dir, host string, t time.Time) (*os.File, error) {
+	hostDir := filepath.Join(dir, hostDirName(host))
+	if err := os.MkdirAll(hostDir, 0700); err != nil {
+		return nil, errors.Wrap(err, "failed to create recordings directory")
+	}
+	path := filepath.Join(hostDir, t.Format(timeLayout)+".cast")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	return f, errors.Wrap(err, "failed to create recording")
+}
+
+// List returns the recordings of host, or of all hosts if host is empty,
+// oldest first.
+func List(dir, host string) ([]Entry, error) {
+	pattern := filepath.Join(dir, "*", "*.cast")
+	if host != "" {
+		pattern = filepath.Join(dir, hostDirName(host), "*.cast")
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, p := range paths {
+		start, err := time.ParseInLocation(timeLayout, strings.TrimSuffix(filepath.Base(p), ".cast"), time.Local)
+		if err != nil {
+			// not created by sshctx
+			continue
+		}
+		entries = append(entries, Entry{Host: filepath.Base(filepath.Dir(p)), Path: p, Start: start})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Start.Before(entries[j].Start)
+	})
+	return entries, nil
+}
+
+// hostDirName makes a DisplayName safe to use as a directory name.
+func hostDirName(host string) string {
+	return strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(host)
+}
@@ -0,0 +1,47 @@
+package recording
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCreateAndList(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2021, 11, 5, 9, 30, 0, 0, time.Local)
+	for i, host := range []string{"web", "db", "web"} {
+		f, err := Create(dir, host, start.Add(time.Duration(i)*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := NewWriter(f, Header{Width: 80, Height: 24})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = w.WriteOutput([]byte("hi"))
+		_ = f.Close()
+	}
+	if _, err := Create(dir, "web", start); err == nil {
+		t.Errorf("Create() should not overwrite a recording")
+	}
+	_ = os.WriteFile(filepath.Join(dir, "web", "notes.cast"), nil, 0600)
+
+	all, err := List(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[0].Host != "web" || all[1].Host != "db" || !all[2].Start.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("List() = %+v", all)
+	}
+	web, err := List(dir, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(web) != 2 {
+		t.Errorf("List(web) = %+v", web)
+	}
+	if d, err := web[0].Duration(); err != nil || d != 0 {
+		t.Errorf("Duration() = %s, %v", d, err)
+	}
+}
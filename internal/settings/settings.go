@@ -0,0 +1,194 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package settings
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+	"gopkg.in/yaml.v3"
+)
+
+// Settings are the preferences of the user, kept in ~/.sshctx/settings.yaml.
+// Unlike the sshctxData file, sshctx never writes to it.
+type Settings struct {
+	Backend      string        `yaml:"backend"` // client to connect with, see backend.Command
+	Engine       string        `yaml:"engine"`  // how `exec` runs commands: "ssh" (default) or "go"
+	HostBackends []HostBackend `yaml:"host_backends"`
+	Record       Record        `yaml:"record"`
+	Hooks        []Hook        `yaml:"hooks"`
+	Agent        Agent         `yaml:"agent"`
+	Tunnels      []Tunnel      `yaml:"tunnels"`
+	Discovery    Discovery     `yaml:"discovery"`
+	Providers    Providers     `yaml:"providers"`
+}
+
+// Discovery enables offering hosts that aren't in sshconfig in the picker.
+type Discovery struct {
+	KnownHosts bool `yaml:"known_hosts"` // unhashed hostnames in known_hosts
+	History    bool `yaml:"history"`     // destinations of ssh commands in the shell history
+}
+
+// Providers enables offering local virtual machines and containers in the picker.
+type Providers struct {
+	Vagrant []string `yaml:"vagrant"` // directories of Vagrant projects
+	Docker  bool     `yaml:"docker"`  // running Docker containers
+}
+
+// Agent configures checking that the key of a host is in ssh-agent before connecting.
+type Agent struct {
+	Skip     bool          `yaml:"skip"`     // don't check
+	Lifetime time.Duration `yaml:"lifetime"` // how long keys added by sshctx stay in the agent, forever if 0
+}
+
+// Hook are shell commands run around connections to some hosts.
+type Hook struct {
+	Hosts       string `yaml:"hosts"` // selector of a group of hosts or a single host, empty for all hosts
+	PreConnect  string `yaml:"pre_connect"`
+	PostConnect string `yaml:"post_connect"`
+}
+
+// Record decides which sessions are recorded without `--record`.
+type Record struct {
+	Hosts string `yaml:"hosts"` // selector of the hosts whose sessions are always recorded
+	Input bool   `yaml:"input"` // record what is typed as well
+}
+
+// HostBackend overrides the backend for the hosts matching a selector.
+type HostBackend struct {
+	Hosts   string `yaml:"hosts"` // selector like `laptop-*,mbp`
+	Backend string `yaml:"backend"`
+}
+
+// Tunnel is a named port forward kept open in the background.
+type Tunnel struct {
+	Name      string `yaml:"name"`
+	Host      string `yaml:"host"` // DisplayName of the host to tunnel through
+	LocalPort int    `yaml:"local_port"`
+	Remote    string `yaml:"remote"` // host:port to forward to, ignored for SOCKS
+	Socks     bool   `yaml:"socks"`  // forward dynamically (-D) instead of to Remote (-L)
+}
+
+// Validate checks the tunnel has everything needed to start it.
+func (t Tunnel) Validate() error {
+	switch {
+	case t.Name == "":
+		return errors.New("tunnel without name")
+	case strings.ContainsAny(t.Name, `/\`) || t.Name == "." || t.Name == "..":
+		return fmt.Errorf("tunnel %s: name can't be a path", t.Name)
+	case t.Host == "":
+		return fmt.Errorf("tunnel %s: missing host", t.Name)
+	case t.LocalPort <= 0 || t.LocalPort > 65535:
+		return fmt.Errorf("tunnel %s: invalid local_port %d", t.Name, t.LocalPort)
+	case !t.Socks && t.Remote == "":
+		return fmt.Errorf("tunnel %s: missing remote", t.Name)
+	}
+	return nil
+}
+
+// Tunnel returns the tunnel with the given name.
+func (s *Settings) Tunnel(name string) (Tunnel, error) {
+	for _, t := range s.Tunnels {
+		if t.Name == name {
+			return t, t.Validate()
+		}
+	}
+	return Tunnel{}, fmt.Errorf("no tunnel named %s in settings", name)
+}
+
+// BackendFor returns the backend to connect to h with: the first matching
+// host_backends entry, or the global backend.
+func (s *Settings) BackendFor(h sshconfig.Host) (string, error) {
+	for _, hb := range s.HostBackends {
+		ok, err := h.MatchesSelector(hb.Hosts)
+		if err != nil {
+			return "", errors.Wrap(err, "invalid host_backends entry")
+		}
+		if ok {
+			return hb.Backend, nil
+		}
+	}
+	return s.Backend, nil
+}
+
+// AlwaysRecord reports whether sessions to h are always recorded.
+func (s *Settings) AlwaysRecord(h sshconfig.Host) (bool, error) {
+	if s.Record.Hosts == "" {
+		return false, nil
+	}
+	ok, err := h.MatchesSelector(s.Record.Hosts)
+	return ok, errors.Wrap(err, "invalid record hosts")
+}
+
+// HooksFor returns the pre_connect and post_connect commands of all hooks
+// matching h, in the order of the settings file.
+func (s *Settings) HooksFor(h sshconfig.Host) ([]string, []string, error) {
+	var pre, post []string
+	for _, hook := range s.Hooks {
+		if hook.Hosts != "" {
+			ok, err := h.MatchesSelector(hook.Hosts)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "invalid hook hosts")
+			}
+			if !ok {
+				continue
+			}
+		}
+		if hook.PreConnect != "" {
+			pre = append(pre, hook.PreConnect)
+		}
+		if hook.PostConnect != "" {
+			post = append(post, hook.PostConnect)
+		}
+	}
+	return pre, post, nil
+}
+
+// Path returns the path of the settings file.
+func Path() (string, error) {
+	// for dev
+	if v := os.Getenv("SSHCTX_SETTINGS"); v != "" {
+		return v, nil
+	}
+	dir, err := sshconfig.GetSSHCtxDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "settings.yaml"), nil
+}
+
+// Load reads the settings file. A missing file means default settings.
+func Load() (*Settings, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, errors.Wrap(err, "Can't determine settings path")
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return new(Settings), nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "Can't read settings")
+	}
+	s := new(Settings)
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, errors.Wrapf(err, "Can't parse settings: %s", path)
+	}
+	return s, nil
+}
@@ -0,0 +1,146 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("missing-file", func(t *testing.T) {
+		t.Setenv("SSHCTX_SETTINGS", filepath.Join(t.TempDir(), "settings.yaml"))
+		s, err := Load()
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if len(s.Tunnels) != 0 {
+			t.Errorf("Load() tunnels = %v, want none", s.Tunnels)
+		}
+	})
+
+	t.Run("tunnels", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "settings.yaml")
+		data := `tunnels:
+  - name: grafana
+    host: prme-nsx-perf-001
+    local_port: 3000
+    remote: localhost:3000
+  - name: proxy
+    host: aws-jumphost
+    local_port: 1080
+    socks: true
+`
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("SSHCTX_SETTINGS", path)
+		s, err := Load()
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		tun, err := s.Tunnel("proxy")
+		if err != nil {
+			t.Fatalf("Tunnel() error = %v", err)
+		}
+		if !tun.Socks || tun.LocalPort != 1080 || tun.Host != "aws-jumphost" {
+			t.Errorf("Tunnel() = %+v", tun)
+		}
+		if _, err := s.Tunnel("missing"); err == nil {
+			t.Errorf("Tunnel() of a missing tunnel should fail")
+		}
+	})
+}
+
+func TestTunnel_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		tunnel  Tunnel
+		wantErr bool
+	}{
+		{name: "local", tunnel: Tunnel{Name: "a", Host: "h", LocalPort: 8080, Remote: "localhost:80"}},
+		{name: "socks", tunnel: Tunnel{Name: "a", Host: "h", LocalPort: 1080, Socks: true}},
+		{name: "no-remote", tunnel: Tunnel{Name: "a", Host: "h", LocalPort: 8080}, wantErr: true},
+		{name: "no-host", tunnel: Tunnel{Name: "a", LocalPort: 8080, Remote: "localhost:80"}, wantErr: true},
+		{name: "path-name", tunnel: Tunnel{Name: "../a", Host: "h", LocalPort: 1080, Socks: true}, wantErr: true},
+		{name: "bad-port", tunnel: Tunnel{Name: "a", Host: "h", LocalPort: 70000, Socks: true}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.tunnel.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSettings_BackendFor(t *testing.T) {
+	s := &Settings{
+		Backend: "ssh",
+		HostBackends: []HostBackend{
+			{Hosts: "laptop-*, mbp", Backend: "mosh"},
+			{Hosts: "laptop-2", Backend: "et"},
+		},
+	}
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "laptop-2", want: "mosh"},
+		{host: "mbp", want: "mosh"},
+		{host: "server", want: "ssh"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			got, err := s.BackendFor(sshconfig.Host{DisplayName: tt.host})
+			if err != nil || got != tt.want {
+				t.Errorf("BackendFor() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	s.HostBackends = []HostBackend{{Hosts: "[", Backend: "mosh"}}
+	if _, err := s.BackendFor(sshconfig.Host{DisplayName: "server"}); err == nil {
+		t.Errorf("BackendFor() with an invalid selector should fail")
+	}
+}
+
+func TestSettings_AlwaysRecord(t *testing.T) {
+	s := new(Settings)
+	if ok, err := s.AlwaysRecord(sshconfig.Host{DisplayName: "prod-1"}); ok || err != nil {
+		t.Errorf("AlwaysRecord() without settings = %v, %v", ok, err)
+	}
+	s.Record.Hosts = "prod-*"
+	if ok, err := s.AlwaysRecord(sshconfig.Host{DisplayName: "prod-1"}); !ok || err != nil {
+		t.Errorf("AlwaysRecord(prod-1) = %v, %v", ok, err)
+	}
+	if ok, err := s.AlwaysRecord(sshconfig.Host{DisplayName: "dev-1"}); ok || err != nil {
+		t.Errorf("AlwaysRecord(dev-1) = %v, %v", ok, err)
+	}
+}
+
+func TestSettings_HooksFor(t *testing.T) {
+	s := &Settings{Hooks: []Hook{
+		{PreConnect: "vpn-refresh"},
+		{Hosts: "prod-*", PreConnect: "check-change-window", PostConnect: "log-prod"},
+		{Hosts: "prod-db", PostConnect: "log-db"},
+	}}
+	tests := []struct {
+		host      string
+		pre, post []string
+	}{
+		{host: "dev", pre: []string{"vpn-refresh"}},
+		{host: "prod-web", pre: []string{"vpn-refresh", "check-change-window"}, post: []string{"log-prod"}},
+		{host: "prod-db", pre: []string{"vpn-refresh", "check-change-window"}, post: []string{"log-prod", "log-db"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			pre, post, err := s.HooksFor(sshconfig.Host{DisplayName: tt.host})
+			if err != nil || !reflect.DeepEqual(pre, tt.pre) || !reflect.DeepEqual(post, tt.post) {
+				t.Errorf("HooksFor() = %q, %q, %v, want %q, %q", pre, post, err, tt.pre, tt.post)
+			}
+		})
+	}
+}
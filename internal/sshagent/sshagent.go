@@ -0,0 +1,97 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshagent
+
+import (
+	"net"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/sshkeys"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// ErrNoAgent is returned by Dial when no agent is running.
+var ErrNoAgent = errors.New("no ssh-agent running: SSH_AUTH_SOCK is not set")
+
+// Key is an identity file and whether the agent holds it.
+type Key struct {
+	Path        string
+	Fingerprint string // SHA256 fingerprint of the public key
+	Loaded      bool
+	Err         error // why the public key can't be read
+}
+
+// Client checks which keys an agent holds.
+type Client struct {
+	agent agent.Agent
+	conn  net.Conn
+}
+
+// New returns a client of a.
+func New(a agent.Agent) *Client {
+	return &Client{agent: a}
+}
+
+// Dial connects to the agent listening on $SSH_AUTH_SOCK.
+func Dial() (*Client, error) {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, ErrNoAgent
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to ssh-agent")
+	}
+	return &Client{agent: agent.NewClient(conn), conn: conn}, nil
+}
+
+// Agent returns the agent, e.g. to authenticate with its keys.
+func (c *Client) Agent() agent.Agent {
+	return c.agent
+}
+
+// Close closes the connection to the agent.
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// Check reports for each identity file whether the agent holds its key.
+func (c *Client) Check(paths []string) ([]Key, error) {
+	loaded, err := c.agent.List()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list keys of ssh-agent")
+	}
+	fingerprints := map[string]bool{}
+	for _, k := range loaded {
+		fingerprints[ssh.FingerprintSHA256(k)] = true
+	}
+	keys := make([]Key, len(paths))
+	for i, p := range paths {
+		keys[i].Path = p
+		pub, err := sshkeys.PublicKey(p)
+		if err != nil {
+			keys[i].Err = err
+			continue
+		}
+		keys[i].Fingerprint = ssh.FingerprintSHA256(pub)
+		keys[i].Loaded = fingerprints[keys[i].Fingerprint]
+	}
+	return keys, nil
+}
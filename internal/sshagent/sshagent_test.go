@@ -0,0 +1,105 @@
+package sshagent
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// newKey writes a private key to dir/name, with a .pub file when withPub is set.
+func newKey(t *testing.T, dir, name string, withPub bool) (string, ed25519.PrivateKey) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if withPub {
+		pub, err := ssh.NewPublicKey(priv.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path+".pub", ssh.MarshalAuthorizedKey(pub), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path, priv
+}
+
+// serveAgent serves keyring on a unix socket and points SSH_AUTH_SOCK to it.
+func serveAgent(t *testing.T, keyring agent.Agent) {
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_ = agent.ServeAgent(keyring, conn)
+				_ = conn.Close()
+			}()
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", sock)
+}
+
+func TestClient_Check(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("ssh-agent listens on a named pipe on windows")
+	}
+	dir := t.TempDir()
+	loaded, priv := newKey(t, dir, "loaded", true)
+	notLoaded, _ := newKey(t, dir, "not_loaded", false)
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: priv}); err != nil {
+		t.Fatal(err)
+	}
+	serveAgent(t, keyring)
+
+	c, err := Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = c.Close() }()
+	keys, err := c.Check([]string{loaded, notLoaded, filepath.Join(dir, "missing")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !keys[0].Loaded || keys[0].Err != nil || keys[0].Fingerprint == "" {
+		t.Errorf("key with .pub = %+v, want loaded", keys[0])
+	}
+	if keys[1].Loaded || keys[1].Err != nil || keys[1].Fingerprint == "" {
+		t.Errorf("key without .pub = %+v, want not loaded", keys[1])
+	}
+	if keys[2].Loaded || keys[2].Err == nil {
+		t.Errorf("missing key = %+v, want an error", keys[2])
+	}
+}
+
+func TestDial_noAgent(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	if _, err := Dial(); err != ErrNoAgent {
+		t.Errorf("Dial() = %v, want ErrNoAgent", err)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshclient
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+// maxJumps limits ProxyJump chains so that jump hosts referring to each other fail.
+const maxJumps = 10
+
+// Config is how to connect to a host, resolved from sshconfig.
+type Config struct {
+	Name             string // the host or jump host as written in sshconfig, for messages
+	Addr             string // host:port
+	User             string
+	IdentityFiles    []string
+	CertificateFiles []string
+	KnownHostsFiles  []string
+	HostKeyAlias     string // name to look up the host key with instead of Addr
+	// StrictHostKeyChecking rejects hosts missing from KnownHostsFiles.
+	// Hosts whose key changed are always rejected.
+	StrictHostKeyChecking bool
+	Timeout               time.Duration // 0 means no timeout
+	// Jumps are the hosts to connect through, in order.
+	Jumps []*Config
+}
+
+// Resolve returns how to connect to the host with its options in sshconfig,
+// including the jump hosts of its ProxyJump option.
+func Resolve(sc *sshconfig.SSHConfig, h sshconfig.Host) (*Config, error) {
+	return resolve(sc, h, 0)
+}
+
+func resolve(sc *sshconfig.SSHConfig, h sshconfig.Host, depth int) (*Config, error) {
+	cfg := &Config{
+		Name:                  h.DisplayName,
+		Addr:                  h.Addr(),
+		User:                  h.Username,
+		IdentityFiles:         sc.IdentityFiles(h),
+		CertificateFiles:      sc.CertificateFiles(h),
+		KnownHostsFiles:       append(sc.UserKnownHostsFiles(h), sc.GlobalKnownHostsFiles(h)...),
+		HostKeyAlias:          sc.Option(h, "HostKeyAlias"),
+		StrictHostKeyChecking: strictHostKeyChecking(sc.Option(h, "StrictHostKeyChecking")),
+	}
+	if v := sc.Option(h, "ConnectTimeout"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, errors.Errorf("invalid ConnectTimeout of %s: %s", h.DisplayName, v)
+		}
+		cfg.Timeout = time.Duration(seconds) * time.Second
+	}
+
+	jump := sc.Option(h, "ProxyJump")
+	if jump == "" || strings.EqualFold(jump, "none") {
+		return cfg, nil
+	}
+	if depth == maxJumps {
+		return nil, errors.Errorf("ProxyJump of %s: more than %d jump hosts, do they refer to each other?", h.DisplayName, maxJumps)
+	}
+	for i, spec := range strings.Split(jump, ",") {
+		hop, err := jumpHost(sc, strings.TrimSpace(spec))
+		if err != nil {
+			return nil, errors.Wrapf(err, "ProxyJump of %s", h.DisplayName)
+		}
+		hopCfg, err := resolve(sc, hop, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		// like ssh(1), only the first jump host is reached through its own ProxyJump
+		if i == 0 {
+			cfg.Jumps = append(cfg.Jumps, hopCfg.Jumps...)
+		}
+		hopCfg.Jumps = nil
+		cfg.Jumps = append(cfg.Jumps, hopCfg)
+	}
+	return cfg, nil
+}
+
+// jumpHost returns the host of a [user@]host[:port] ProxyJump entry. The
+// host may be a host of sshconfig, whose user and port the entry overrides.
+func jumpHost(sc *sshconfig.SSHConfig, spec string) (sshconfig.Host, error) {
+	if strings.HasPrefix(spec, "ssh://") {
+		spec = strings.TrimSuffix(strings.TrimPrefix(spec, "ssh://"), "/")
+	}
+	var user string
+	if i := strings.LastIndex(spec, "@"); i >= 0 {
+		user, spec = spec[:i], spec[i+1:]
+	}
+	name, port := spec, 0
+	if host, p, err := net.SplitHostPort(spec); err == nil {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return sshconfig.EmptyHost, errors.Errorf("invalid port of jump host %s", spec)
+		}
+		name, port = host, n
+	}
+	if name == "" {
+		return sshconfig.EmptyHost, errors.New("empty jump host")
+	}
+
+	h, err := sc.Lookup(name)
+	if err != nil {
+		h = sshconfig.Host{DisplayName: name, Host: name, Username: sc.Option(sshconfig.Host{DisplayName: name}, "User")}
+		if v := sc.Option(h, "Hostname"); v != "" {
+			h.Host = v
+		}
+		h.Port, _ = strconv.Atoi(sc.Option(h, "Port"))
+		if h.Username == "" {
+			h.Username = os.Getenv("USER")
+		}
+	}
+	if user != "" {
+		h.Username = user
+	}
+	if port != 0 {
+		h.Port = port
+	}
+	return h, nil
+}
+
+// strictHostKeyChecking returns whether a StrictHostKeyChecking value
+// rejects unknown hosts. sshctx never asks and never adds hosts to
+// known_hosts, so `ask` is strict and `accept-new` isn't.
+func strictHostKeyChecking(v string) bool {
+	switch strings.ToLower(v) {
+	case "no", "off", "accept-new":
+		return false
+	}
+	return true
+}
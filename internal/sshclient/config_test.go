@@ -0,0 +1,131 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshclient
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+func parseConfig(t *testing.T, config string) *sshconfig.SSHConfig {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("USER", "me")
+	path := filepath.Join(home, "config")
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SSHCONFIG", path)
+	t.Setenv("SSHCTX", "")
+	sc := new(sshconfig.SSHConfig).WithLoader(sshconfig.DefaultLoader)
+	t.Cleanup(func() { _ = sc.Close() })
+	if err := sc.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	return sc
+}
+
+func TestResolve(t *testing.T) {
+	sc := parseConfig(t, `Host web
+    Hostname 10.0.0.1
+    User root
+    ProxyJump inner,admin@edge:2200
+    StrictHostKeyChecking accept-new
+    ConnectTimeout 5
+    UserKnownHostsFile ~/.ssh/kh_%r none
+
+Host inner
+    Hostname 10.0.0.2
+    Port 2222
+    ProxyJump bastion
+
+Host bastion
+    Hostname bastion.example.com
+    User jump
+
+Host edge
+    Hostname edge.example.com
+    ProxyJump bastion
+`)
+	h, err := sc.Lookup("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Resolve(sc, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Addr != "10.0.0.1:22" || cfg.User != "root" || cfg.StrictHostKeyChecking || cfg.Timeout != 5*time.Second {
+		t.Errorf("Resolve(web) = %+v", cfg)
+	}
+	wantKnownHosts := []string{filepath.Join(os.Getenv("HOME"), ".ssh", "kh_root"),
+		"/etc/ssh/ssh_known_hosts", "/etc/ssh/ssh_known_hosts2"}
+	if !reflect.DeepEqual(cfg.KnownHostsFiles, wantKnownHosts) {
+		t.Errorf("KnownHostsFiles = %q, want %q", cfg.KnownHostsFiles, wantKnownHosts)
+	}
+
+	// the ProxyJump of inner is used, the one of edge isn't
+	var jumps []string
+	for _, j := range cfg.Jumps {
+		jumps = append(jumps, j.User+"@"+j.Addr)
+		if !j.StrictHostKeyChecking || len(j.Jumps) > 0 {
+			t.Errorf("jump host %s = %+v", j.Name, j)
+		}
+	}
+	want := []string{"jump@bastion.example.com:22", "me@10.0.0.2:2222", "admin@edge.example.com:2200"}
+	if !reflect.DeepEqual(jumps, want) {
+		t.Errorf("Jumps = %q, want %q", jumps, want)
+	}
+}
+
+func TestResolve_jumpHostNotInConfig(t *testing.T) {
+	sc := parseConfig(t, `Host web
+    Hostname 10.0.0.1
+    ProxyJump ops@[fd00::1]:2200
+
+Host *
+    StrictHostKeyChecking no
+`)
+	h, _ := sc.Lookup("web")
+	cfg, err := Resolve(sc, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Jumps) != 1 || cfg.Jumps[0].Addr != "[fd00::1]:2200" || cfg.Jumps[0].User != "ops" || cfg.Jumps[0].StrictHostKeyChecking {
+		t.Errorf("Jumps = %+v", cfg.Jumps)
+	}
+}
+
+func TestResolve_loop(t *testing.T) {
+	sc := parseConfig(t, `Host a
+    Hostname 10.0.0.1
+    ProxyJump b
+
+Host b
+    Hostname 10.0.0.2
+    ProxyJump a
+`)
+	h, _ := sc.Lookup("a")
+	if _, err := Resolve(sc, h); err == nil {
+		t.Error("Resolve() of jump hosts referring to each other succeeded")
+	}
+}
@@ -0,0 +1,408 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshclient
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/sshkeys"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// DialError is returned when a host or jump host can't be reached.
+type DialError struct {
+	Addr string
+	Via  string // jump host the connection was opened from, empty for direct connections
+	Err  error
+}
+
+func (e *DialError) Error() string {
+	if e.Via != "" {
+		return fmt.Sprintf("failed to connect to %s via %s: %v", e.Addr, e.Via, e.Err)
+	}
+	return fmt.Sprintf("failed to connect to %s: %v", e.Addr, e.Err)
+}
+
+func (e *DialError) Unwrap() error { return e.Err }
+
+// HostKeyError is returned when the key of a host can't be verified with known_hosts.
+type HostKeyError struct {
+	Host    string
+	Key     ssh.PublicKey // the key the host presented
+	Changed bool          // known_hosts has a different key for the host, otherwise it has none
+	Err     error
+}
+
+func (e *HostKeyError) Error() string {
+	if e.Changed {
+		return fmt.Sprintf("host key of %s changed to %s %s: possible man-in-the-middle attack",
+			e.Host, e.Key.Type(), ssh.FingerprintSHA256(e.Key))
+	}
+	return fmt.Sprintf("host key of %s is not in known_hosts: %s %s",
+		e.Host, e.Key.Type(), ssh.FingerprintSHA256(e.Key))
+}
+
+func (e *HostKeyError) Unwrap() error { return e.Err }
+
+// AuthError is returned when none of the keys is accepted by a host.
+type AuthError struct {
+	User string
+	Host string
+	Err  error
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("permission denied for %s@%s: %v", e.User, e.Host, e.Err)
+}
+
+func (e *AuthError) Unwrap() error { return e.Err }
+
+// ExitError is returned when a remote command exits with a non-zero status.
+type ExitError struct {
+	Status int
+	Signal string // signal that killed the command, if any
+}
+
+func (e *ExitError) Error() string {
+	if e.Signal != "" {
+		return fmt.Sprintf("remote command killed by signal %s", e.Signal)
+	}
+	return fmt.Sprintf("remote command exited with status %d", e.Status)
+}
+
+// Client is a connection to a host, possibly through jump hosts.
+type Client struct {
+	*ssh.Client
+	name  string // of the host the client is connected to
+	jumps []*ssh.Client
+}
+
+// Dial connects to the host of cfg through its jump hosts. Keys are taken
+// from the certificate and identity files of each host and from a, which
+// may be nil.
+func Dial(cfg *Config, a agent.Agent) (*Client, error) {
+	c := &Client{}
+	for _, hop := range append(append([]*Config{}, cfg.Jumps...), cfg) {
+		if err := c.connect(hop, a); err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// VerifyHostKey connects to the host of cfg through its jump hosts and
+// checks its key with known_hosts without authenticating to it. It returns
+// a *HostKeyError when the key is unknown or changed.
+func VerifyHostKey(cfg *Config, a agent.Agent) error {
+	c := &Client{}
+	defer c.Close()
+	for _, hop := range cfg.Jumps {
+		if err := c.connect(hop, a); err != nil {
+			return err
+		}
+	}
+	conn, err := c.open(cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = handshake(conn, cfg, nil, true)
+	return err
+}
+
+// open opens a TCP connection to hop, through the host c is connected to if any.
+func (c *Client) open(hop *Config) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	if c.Client == nil {
+		conn, err = net.DialTimeout("tcp", hop.Addr, hop.Timeout)
+	} else {
+		conn, err = c.Client.Dial("tcp", hop.Addr)
+	}
+	if err != nil {
+		return nil, &DialError{Addr: hop.Addr, Via: c.name, Err: err}
+	}
+	return conn, nil
+}
+
+// connect connects c to hop, keeping the current connection as a jump host.
+func (c *Client) connect(hop *Config, a agent.Agent) error {
+	conn, err := c.open(hop)
+	if err != nil {
+		return err
+	}
+	client, err := handshake(conn, hop, a, false)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	if c.Client != nil {
+		c.jumps = append(c.jumps, c.Client)
+	}
+	c.Client, c.name = client, hop.Name
+	return nil
+}
+
+// Close closes the connection and the connections to the jump hosts.
+func (c *Client) Close() error {
+	var err error
+	if c.Client != nil {
+		err = c.Client.Close()
+	}
+	for i := len(c.jumps) - 1; i >= 0; i-- {
+		_ = c.jumps[i].Close()
+	}
+	return err
+}
+
+// Run runs command on the host like `ssh host command`. It returns an
+// *ExitError when the command fails. Like os/exec, stdout and stderr may be
+// the same writer.
+func (c *Client) Run(command string, stdin io.Reader, stdout, stderr io.Writer) error {
+	session, err := c.NewSession()
+	if err != nil {
+		return errors.Wrap(err, "failed to open session")
+	}
+	defer session.Close()
+	if stdout != nil && stdout == stderr {
+		stdout = &lockedWriter{w: stdout}
+		stderr = stdout
+	}
+	session.Stdin, session.Stdout, session.Stderr = stdin, stdout, stderr
+
+	err = session.Run(command)
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return &ExitError{Status: exitErr.ExitStatus(), Signal: exitErr.Signal()}
+	}
+	return err
+}
+
+// lockedWriter serializes the writes of stdout and stderr to the same writer.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// errHostKeyVerified stops the handshake of VerifyHostKey once the host key is verified.
+var errHostKeyVerified = errors.New("host key verified")
+
+// handshake opens an ssh connection to hop over conn. With verifyOnly, it
+// stops after verifying the host key and returns no client.
+func handshake(conn net.Conn, hop *Config, a agent.Agent, verifyOnly bool) (*ssh.Client, error) {
+	check, err := knownHostsCallback(hop)
+	if err != nil {
+		return nil, err
+	}
+	hostKeyAddr := hop.Addr
+	if hop.HostKeyAlias != "" {
+		hostKeyAddr = net.JoinHostPort(hop.HostKeyAlias, "22")
+	}
+	// errors of the callback are only returned as text, so keep them
+	var hostKeyErr *HostKeyError
+	verified := false
+	callback := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if err := check(hostname, remote, key); err != nil {
+			if !errors.As(err, &hostKeyErr) {
+				hostKeyErr = &HostKeyError{Key: key, Err: err}
+			}
+			hostKeyErr.Host = hop.Name
+			return err
+		}
+		verified = true
+		if verifyOnly {
+			return errHostKeyVerified
+		}
+		return nil
+	}
+	config := &ssh.ClientConfig{
+		User:              hop.User,
+		Auth:              []ssh.AuthMethod{ssh.PublicKeysCallback(func() ([]ssh.Signer, error) { return signers(hop, a), nil })},
+		HostKeyCallback:   callback,
+		HostKeyAlgorithms: knownHostKeyAlgorithms(check, hostKeyAddr, conn.RemoteAddr()),
+		Timeout:           hop.Timeout,
+	}
+
+	if hop.Timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(hop.Timeout))
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, hostKeyAddr, config)
+	switch {
+	case hostKeyErr != nil:
+		return nil, hostKeyErr
+	case verifyOnly && verified:
+		return nil, nil
+	case err != nil && strings.Contains(err.Error(), "unable to authenticate"):
+		return nil, &AuthError{User: hop.User, Host: hop.Name, Err: err}
+	case err != nil:
+		return nil, &DialError{Addr: hop.Addr, Err: err}
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// knownHostsCallback verifies host keys with the known_hosts files of hop.
+func knownHostsCallback(hop *Config) (ssh.HostKeyCallback, error) {
+	var files []string
+	for _, f := range hop.KnownHostsFiles {
+		if _, err := os.Stat(f); err == nil {
+			files = append(files, f)
+		}
+	}
+	known, err := knownhosts.New(files...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read known_hosts")
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := known(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+		if len(keyErr.Want) > 0 {
+			return &HostKeyError{Host: hop.Name, Key: key, Changed: true, Err: err}
+		}
+		if !hop.StrictHostKeyChecking {
+			return nil
+		}
+		return &HostKeyError{Host: hop.Name, Key: key, Err: err}
+	}, nil
+}
+
+// unknownKey is a key no host has, to look up which keys known_hosts has for a host.
+var unknownKey, _ = ssh.NewPublicKey(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)).Public())
+
+// knownHostKeyAlgorithms returns the algorithms of the keys known_hosts has
+// for the host, so that the host presents one of them instead of a key of
+// another type, which would look like a changed key. It returns nil for
+// unknown hosts, which lets the host choose.
+func knownHostKeyAlgorithms(check ssh.HostKeyCallback, addr string, remote net.Addr) []string {
+	var keyErr *knownhosts.KeyError
+	if !errors.As(check(addr, remote, unknownKey), &keyErr) {
+		return nil
+	}
+	var algorithms []string
+	for _, k := range keyErr.Want {
+		switch k.Key.Type() {
+		case ssh.KeyAlgoRSA:
+			algorithms = append(algorithms, ssh.SigAlgoRSASHA2512, ssh.SigAlgoRSASHA2256, ssh.SigAlgoRSA)
+		default:
+			algorithms = append(algorithms, k.Key.Type())
+		}
+	}
+	return algorithms
+}
+
+// signers returns the keys to authenticate to hop with, in the order
+// ssh(1) tries them: certificates, identity files, then the other keys of
+// the agent. Keys that can't be used, e.g. identity files with a
+// passphrase that aren't in the agent, are skipped.
+func signers(hop *Config, a agent.Agent) []ssh.Signer {
+	var agentSigners []ssh.Signer
+	if a != nil {
+		agentSigners, _ = a.Signers()
+	}
+	var keys []ssh.Signer
+	for _, f := range hop.IdentityFiles {
+		if s := identitySigner(f, agentSigners); s != nil {
+			keys = append(keys, s)
+		}
+	}
+
+	var result []ssh.Signer
+	for _, f := range hop.CertificateFiles {
+		cert, err := readCertificate(f)
+		if err != nil {
+			continue
+		}
+		for _, s := range append(append([]ssh.Signer{}, keys...), agentSigners...) {
+			if bytes.Equal(s.PublicKey().Marshal(), cert.Key.Marshal()) {
+				if certSigner, err := ssh.NewCertSigner(cert, s); err == nil {
+					result = append(result, certSigner)
+				}
+				break
+			}
+		}
+	}
+	seen := map[string]bool{}
+	for _, s := range append(keys, agentSigners...) {
+		key := string(s.PublicKey().Marshal())
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// identitySigner returns the key of an identity file, or the agent's if the
+// file needs a passphrase.
+func identitySigner(path string, agentSigners []ssh.Signer) ssh.Signer {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	s, err := ssh.ParsePrivateKey(b)
+	if err == nil {
+		return s
+	}
+	if _, ok := err.(*ssh.PassphraseMissingError); !ok {
+		return nil
+	}
+	pub, err := sshkeys.PublicKey(path)
+	if err != nil {
+		return nil
+	}
+	for _, as := range agentSigners {
+		if bytes.Equal(as.PublicKey().Marshal(), pub.Marshal()) {
+			return as
+		}
+	}
+	return nil
+}
+
+func readCertificate(path string) (*ssh.Certificate, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey(b)
+	if err != nil {
+		return nil, err
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		return nil, errors.Errorf("%s is not a certificate", path)
+	}
+	return cert, nil
+}
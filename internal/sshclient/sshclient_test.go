@@ -0,0 +1,478 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshclient
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testServer is an ssh server running commands by echoing them. The
+// command `fail` exits with status 3. It forwards direct-tcpip channels so
+// that it can be used as jump host.
+type testServer struct {
+	Addr    string
+	HostKey ssh.Signer
+}
+
+// startServer starts a server accepting the keys in authorized and the
+// certificates signed by ca, which may be nil.
+func startServer(t *testing.T, authorized []ssh.PublicKey, ca ssh.PublicKey) *testServer {
+	t.Helper()
+	hostKey := newSigner(t)
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return ca != nil && bytes.Equal(auth.Marshal(), ca.Marshal())
+		},
+		UserKeyFallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			for _, k := range authorized {
+				if bytes.Equal(k.Marshal(), key.Marshal()) {
+					return nil, nil
+				}
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+	config := &ssh.ServerConfig{PublicKeyCallback: checker.Authenticate}
+	config.AddHostKey(hostKey)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn, config)
+		}
+	}()
+	return &testServer{Addr: l.Addr().String(), HostKey: hostKey}
+}
+
+func serve(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for ch := range chans {
+		switch ch.ChannelType() {
+		case "session":
+			go serveSession(ch)
+		case "direct-tcpip":
+			go serveForward(ch)
+		default:
+			_ = ch.Reject(ssh.UnknownChannelType, "unsupported")
+		}
+	}
+}
+
+func serveSession(newCh ssh.NewChannel) {
+	ch, reqs, err := newCh.Accept()
+	if err != nil {
+		return
+	}
+	defer ch.Close()
+	for req := range reqs {
+		if req.Type != "exec" {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		var payload struct{ Command string }
+		_ = ssh.Unmarshal(req.Payload, &payload)
+		_ = req.Reply(true, nil)
+		status := uint32(0)
+		if payload.Command == "fail" {
+			_, _ = io.WriteString(ch.Stderr(), "failed\n")
+			status = 3
+		} else {
+			_, _ = io.WriteString(ch, "ran "+payload.Command+"\n")
+		}
+		_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+		return
+	}
+}
+
+func serveForward(newCh ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newCh.ExtraData(), &payload); err != nil {
+		_ = newCh.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	target, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+	if err != nil {
+		_ = newCh.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	ch, reqs, err := newCh.Accept()
+	if err != nil {
+		_ = target.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	go func() {
+		_, _ = io.Copy(ch, target)
+		_ = ch.CloseWrite()
+	}()
+	_, _ = io.Copy(target, ch)
+	_ = target.Close()
+	_ = ch.Close()
+}
+
+func newSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// writeKey writes a new private key to dir and returns its path and signer.
+func writeKey(t *testing.T, dir, name string) (string, ssh.Signer) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, s
+}
+
+// writeKnownHosts writes a known_hosts file with the host keys of servers.
+func writeKnownHosts(t *testing.T, dir string, servers ...*testServer) string {
+	t.Helper()
+	var b bytes.Buffer
+	for _, s := range servers {
+		b.WriteString(knownhosts.Line([]string{knownhosts.Normalize(s.Addr)}, s.HostKey.PublicKey()) + "\n")
+	}
+	path := filepath.Join(dir, "known_hosts")
+	if err := os.WriteFile(path, b.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func run(t *testing.T, cfg *Config, a agent.Agent, command string) (string, error) {
+	t.Helper()
+	c, err := Dial(cfg, a)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+	var out bytes.Buffer
+	err = c.Run(command, nil, &out, &out)
+	return out.String(), err
+}
+
+func TestClient_Run(t *testing.T) {
+	dir := t.TempDir()
+	keyFile, key := writeKey(t, dir, "id_ed25519")
+	server := startServer(t, []ssh.PublicKey{key.PublicKey()}, nil)
+	cfg := &Config{Name: "web", Addr: server.Addr, User: "root", IdentityFiles: []string{keyFile},
+		KnownHostsFiles: []string{writeKnownHosts(t, dir, server)}, StrictHostKeyChecking: true}
+
+	out, err := run(t, cfg, nil, "uptime")
+	if err != nil || out != "ran uptime\n" {
+		t.Errorf("Run(uptime) = %q, %v", out, err)
+	}
+
+	out, err = run(t, cfg, nil, "fail")
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Status != 3 {
+		t.Errorf("Run(fail) error = %v, want exit status 3", err)
+	}
+	if out != "failed\n" {
+		t.Errorf("Run(fail) output = %q", out)
+	}
+}
+
+func TestDial_hostKey(t *testing.T) {
+	dir := t.TempDir()
+	keyFile, key := writeKey(t, dir, "id_ed25519")
+	server := startServer(t, []ssh.PublicKey{key.PublicKey()}, nil)
+	other := startServer(t, nil, nil)
+	// known_hosts has the key of other for the address of server
+	changed := filepath.Join(dir, "changed")
+	line := knownhosts.Line([]string{knownhosts.Normalize(server.Addr)}, other.HostKey.PublicKey())
+	if err := os.WriteFile(changed, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		knownHosts  string
+		strict      bool
+		wantErr     bool
+		wantChanged bool
+	}{
+		{name: "known", knownHosts: writeKnownHosts(t, dir, server), strict: true},
+		{name: "unknown", knownHosts: filepath.Join(dir, "missing"), strict: true, wantErr: true},
+		{name: "unknown-not-strict", knownHosts: filepath.Join(dir, "missing")},
+		{name: "changed", knownHosts: changed, strict: true, wantErr: true, wantChanged: true},
+		{name: "changed-not-strict", knownHosts: changed, wantErr: true, wantChanged: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Name: "web", Addr: server.Addr, User: "root", IdentityFiles: []string{keyFile},
+				KnownHostsFiles: []string{tt.knownHosts}, StrictHostKeyChecking: tt.strict}
+			_, err := run(t, cfg, nil, "uptime")
+			var hostKeyErr *HostKeyError
+			if got := errors.As(err, &hostKeyErr); got != tt.wantErr {
+				t.Fatalf("Dial() error = %v, want HostKeyError %v", err, tt.wantErr)
+			}
+			if tt.wantErr && hostKeyErr.Changed != tt.wantChanged {
+				t.Errorf("HostKeyError.Changed = %v, want %v", hostKeyErr.Changed, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestDial_auth(t *testing.T) {
+	dir := t.TempDir()
+	keyFile, key := writeKey(t, dir, "id_ed25519")
+	otherFile, _ := writeKey(t, dir, "id_other")
+	server := startServer(t, []ssh.PublicKey{key.PublicKey()}, nil)
+	knownHosts := writeKnownHosts(t, dir, server)
+	cfg := func(identityFiles ...string) *Config {
+		return &Config{Name: "web", Addr: server.Addr, User: "root", IdentityFiles: identityFiles,
+			KnownHostsFiles: []string{knownHosts}, StrictHostKeyChecking: true}
+	}
+
+	if _, err := run(t, cfg(otherFile, keyFile), nil, "uptime"); err != nil {
+		t.Errorf("Dial() trying the right key second error = %v", err)
+	}
+	_, err := run(t, cfg(otherFile), nil, "uptime")
+	var authErr *AuthError
+	if !errors.As(err, &authErr) {
+		t.Errorf("Dial() with a wrong key error = %v, want AuthError", err)
+	}
+
+	keyring := agent.NewKeyring()
+	_, agentKey, _ := ed25519.GenerateKey(rand.Reader)
+	if err := keyring.Add(agent.AddedKey{PrivateKey: agentKey}); err != nil {
+		t.Fatal(err)
+	}
+	agentSigner, _ := ssh.NewSignerFromKey(agentKey)
+	server = startServer(t, []ssh.PublicKey{agentSigner.PublicKey()}, nil)
+	knownHosts = writeKnownHosts(t, dir, server)
+	if _, err := run(t, cfg(otherFile), keyring, "uptime"); err != nil {
+		t.Errorf("Dial() with the key in the agent error = %v", err)
+	}
+}
+
+func TestDial_relativeIdentityFile(t *testing.T) {
+	dir := t.TempDir()
+	_, key := writeKey(t, dir, "id_ed25519")
+	server := startServer(t, []ssh.PublicKey{key.PublicKey()}, nil)
+	host, port, err := net.SplitHostPort(server.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := parseConfig(t, fmt.Sprintf(`Host web
+    Hostname %s
+    Port %s
+    User root
+    IdentityFile id_ed25519
+    UserKnownHostsFile %s
+`, host, port, writeKnownHosts(t, dir, server)))
+	// like ssh(1), the key is looked up in the current directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.Chdir(wd)
+	}()
+
+	h, _ := sc.Lookup("web")
+	cfg, err := Resolve(sc, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := run(t, cfg, nil, "uptime"); err != nil || out != "ran uptime\n" {
+		t.Errorf("Run(uptime) = %q, %v", out, err)
+	}
+}
+
+func TestDial_certificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := newSigner(t)
+	keyFile, key := writeKey(t, dir, "id_ed25519")
+	cert := &ssh.Certificate{
+		Key:             key.PublicKey(),
+		CertType:        ssh.UserCert,
+		KeyId:           "test",
+		ValidPrincipals: []string{"root"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+	certFile := keyFile + "-cert.pub"
+	if err := os.WriteFile(certFile, ssh.MarshalAuthorizedKey(cert), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// only certificates are accepted
+	server := startServer(t, nil, ca.PublicKey())
+	cfg := &Config{Name: "web", Addr: server.Addr, User: "root", IdentityFiles: []string{keyFile},
+		KnownHostsFiles: []string{writeKnownHosts(t, dir, server)}, StrictHostKeyChecking: true}
+
+	if _, err := run(t, cfg, nil, "uptime"); err == nil {
+		t.Error("Dial() without certificate succeeded")
+	}
+	cfg.CertificateFiles = []string{certFile}
+	if _, err := run(t, cfg, nil, "uptime"); err != nil {
+		t.Errorf("Dial() with certificate error = %v", err)
+	}
+}
+
+func TestDial_jumps(t *testing.T) {
+	dir := t.TempDir()
+	keyFile, key := writeKey(t, dir, "id_ed25519")
+	authorized := []ssh.PublicKey{key.PublicKey()}
+	bastion := startServer(t, authorized, nil)
+	inner := startServer(t, authorized, nil)
+	target := startServer(t, authorized, nil)
+	knownHosts := writeKnownHosts(t, dir, bastion, inner, target)
+	hop := func(name, addr string) *Config {
+		return &Config{Name: name, Addr: addr, User: "root", IdentityFiles: []string{keyFile},
+			KnownHostsFiles: []string{knownHosts}, StrictHostKeyChecking: true}
+	}
+
+	cfg := hop("web", target.Addr)
+	cfg.Jumps = []*Config{hop("bastion", bastion.Addr), hop("inner", inner.Addr)}
+	out, err := run(t, cfg, nil, "hostname")
+	if err != nil || out != "ran hostname\n" {
+		t.Errorf("Run() through jump hosts = %q, %v", out, err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := l.Addr().String()
+	_ = l.Close()
+	cfg = hop("web", closed)
+	cfg.Jumps = []*Config{hop("bastion", bastion.Addr)}
+	_, err = run(t, cfg, nil, "hostname")
+	var dialErr *DialError
+	if !errors.As(err, &dialErr) || dialErr.Via != "bastion" || dialErr.Addr != closed {
+		t.Errorf("Dial() of a closed port error = %#v, want DialError via bastion", err)
+	}
+}
+
+func TestDial_unreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+	_, err = Dial(&Config{Name: "web", Addr: addr, User: "root"}, nil)
+	var dialErr *DialError
+	if !errors.As(err, &dialErr) || dialErr.Via != "" {
+		t.Errorf("Dial() error = %v, want DialError", err)
+	}
+}
+
+func TestKnownHostKeyAlgorithms(t *testing.T) {
+	dir := t.TempDir()
+	server := startServer(t, nil, nil)
+	check, err := knownHostsCallback(&Config{KnownHostsFiles: []string{writeKnownHosts(t, dir, server)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(server.Addr)
+	p, _ := strconv.Atoi(port)
+	remote := &net.TCPAddr{IP: net.ParseIP(host), Port: p}
+	if got := knownHostKeyAlgorithms(check, server.Addr, remote); len(got) != 1 || got[0] != ssh.KeyAlgoED25519 {
+		t.Errorf("knownHostKeyAlgorithms() = %q, want %q", got, ssh.KeyAlgoED25519)
+	}
+	if got := knownHostKeyAlgorithms(check, "127.0.0.2:22", &net.TCPAddr{IP: net.ParseIP("127.0.0.2"), Port: 22}); got != nil {
+		t.Errorf("knownHostKeyAlgorithms() of an unknown host = %q, want nil", got)
+	}
+}
+
+func TestVerifyHostKey(t *testing.T) {
+	dir := t.TempDir()
+	// nothing is authorized: verifying doesn't authenticate
+	server := startServer(t, nil, nil)
+	other := startServer(t, nil, nil)
+	cfg := &Config{Name: "web", Addr: server.Addr, User: "root",
+		KnownHostsFiles: []string{writeKnownHosts(t, dir, server)}, StrictHostKeyChecking: true}
+	if err := VerifyHostKey(cfg, nil); err != nil {
+		t.Errorf("VerifyHostKey() of a known host = %v", err)
+	}
+
+	alias := filepath.Join(dir, "alias")
+	if err := os.WriteFile(alias, []byte(knownhosts.Line([]string{"web-alias"}, server.HostKey.PublicKey())+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg.KnownHostsFiles, cfg.HostKeyAlias = []string{alias}, "web-alias"
+	if err := VerifyHostKey(cfg, nil); err != nil {
+		t.Errorf("VerifyHostKey() with HostKeyAlias = %v", err)
+	}
+
+	cfg.Addr, cfg.HostKeyAlias = other.Addr, "web-alias"
+	err := VerifyHostKey(cfg, nil)
+	var hostKeyErr *HostKeyError
+	if !errors.As(err, &hostKeyErr) || !hostKeyErr.Changed || hostKeyErr.Host != "web" {
+		t.Errorf("VerifyHostKey() of a changed key = %v, want HostKeyError of web", err)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshconfig
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// defaultIdentityFiles are the keys in ~/.ssh ssh(1) tries when a host has no IdentityFile.
+var defaultIdentityFiles = []string{"id_rsa", "id_ecdsa", "id_ecdsa_sk", "id_ed25519", "id_ed25519_sk", "id_xmss", "id_dsa"}
+
+// IdentityFiles returns the private keys ssh(1) authenticates to the host
+// with: its IdentityFile options with tokens expanded, or else the default
+// keys that exist in ~/.ssh.
+func (s *SSHConfig) IdentityFiles(h Host) []string {
+	var files []string
+	for _, f := range s.Options(h)["identityfile"] {
+		if f != "none" {
+			files = append(files, s.ExpandTokens(h, f))
+		}
+	}
+	if len(files) > 0 {
+		return files
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	for _, name := range defaultIdentityFiles {
+		f := filepath.Join(home, ".ssh", name)
+		if _, err := os.Stat(f); err == nil {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// CertificateFiles returns the certificates ssh(1) authenticates to the
+// host with: its CertificateFile options with tokens expanded, and the
+// `-cert.pub` files that exist next to its identity files.
+func (s *SSHConfig) CertificateFiles(h Host) []string {
+	var files []string
+	for _, f := range s.Options(h)["certificatefile"] {
+		if f != "none" {
+			files = append(files, s.ExpandTokens(h, f))
+		}
+	}
+next:
+	for _, f := range s.IdentityFiles(h) {
+		cert := f + "-cert.pub"
+		for _, configured := range files {
+			if configured == cert {
+				continue next
+			}
+		}
+		if _, err := os.Stat(cert); err == nil {
+			files = append(files, cert)
+		}
+	}
+	return files
+}
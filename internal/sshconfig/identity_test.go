@@ -0,0 +1,76 @@
+package sshconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSSHConfig_IdentityFiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	if err := os.MkdirAll(filepath.Join(home, ".ssh"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"id_ed25519", "id_rsa", "other"} {
+		if err := os.WriteFile(filepath.Join(home, ".ssh", name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blocks, err := ParseBlocks(strings.NewReader(`
+Host web
+    IdentityFile ~/.ssh/web_%r
+    IdentityFile /keys/shared
+    IdentityFile keys/relative
+
+Host nokey
+    IdentityFile none
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := &SSHConfig{Blocks: blocks}
+
+	got := sc.IdentityFiles(Host{DisplayName: "web", Host: "10.0.0.1", Username: "root"})
+	want := []string{filepath.Join(home, ".ssh", "web_root"), "/keys/shared", "keys/relative"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("IdentityFiles(web) = %q, want %q", got, want)
+	}
+	got = sc.IdentityFiles(Host{DisplayName: "nokey"})
+	want = []string{filepath.Join(home, ".ssh", "id_rsa"), filepath.Join(home, ".ssh", "id_ed25519")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("IdentityFiles(nokey) = %q, want the default keys %q", got, want)
+	}
+}
+
+func TestSSHConfig_CertificateFiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	if err := os.MkdirAll(filepath.Join(home, ".ssh"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"id_ed25519", "id_ed25519-cert.pub", "id_rsa"} {
+		if err := os.WriteFile(filepath.Join(home, ".ssh", name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blocks, err := ParseBlocks(strings.NewReader(`
+Host ca
+    CertificateFile ~/.ssh/ca-%r.pub
+    CertificateFile ~/.ssh/id_ed25519-cert.pub
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := &SSHConfig{Blocks: blocks}
+
+	got := sc.CertificateFiles(Host{DisplayName: "ca", Username: "root"})
+	want := []string{filepath.Join(home, ".ssh", "ca-root.pub"), filepath.Join(home, ".ssh", "id_ed25519-cert.pub")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CertificateFiles() = %q, want %q", got, want)
+	}
+}
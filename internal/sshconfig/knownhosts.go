@@ -0,0 +1,55 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshconfig
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/spencercjh/sshctx/internal/cmdutil"
+)
+
+// UserKnownHostsFiles returns the known_hosts files of the user ssh(1)
+// verifies the host with: its UserKnownHostsFile option with tokens
+// expanded, or ~/.ssh/known_hosts and ~/.ssh/known_hosts2.
+func (s *SSHConfig) UserKnownHostsFiles(h Host) []string {
+	v := s.Option(h, "UserKnownHostsFile")
+	if v == "" {
+		dir := filepath.Join(cmdutil.HomeDir(), ".ssh")
+		return []string{filepath.Join(dir, "known_hosts"), filepath.Join(dir, "known_hosts2")}
+	}
+	return s.knownHostsFiles(h, v)
+}
+
+// GlobalKnownHostsFiles returns the system-wide known_hosts files ssh(1)
+// verifies the host with: its GlobalKnownHostsFile option, or
+// /etc/ssh/ssh_known_hosts and /etc/ssh/ssh_known_hosts2.
+func (s *SSHConfig) GlobalKnownHostsFiles(h Host) []string {
+	v := s.Option(h, "GlobalKnownHostsFile")
+	if v == "" {
+		return []string{"/etc/ssh/ssh_known_hosts", "/etc/ssh/ssh_known_hosts2"}
+	}
+	return s.knownHostsFiles(h, v)
+}
+
+func (s *SSHConfig) knownHostsFiles(h Host, value string) []string {
+	var files []string
+	for _, f := range strings.Fields(value) {
+		if f != "none" && f != "/dev/null" {
+			files = append(files, s.ExpandTokens(h, f))
+		}
+	}
+	return files
+}
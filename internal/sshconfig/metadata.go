@@ -0,0 +1,66 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshconfig
+
+import "strings"
+
+// metadataPrefix starts the comments sshctx reads its own per-host settings
+// from, e.g. `#sshctx: backend=mosh`. ssh(1) ignores them like any comment.
+const metadataPrefix = "#sshctx:"
+
+// Metadata returns the sshctx metadata of the host, resolved like Options:
+// the first value of each key in the matching blocks wins. Keys are lower case.
+func (s *SSHConfig) Metadata(h Host) map[string]string {
+	meta := map[string]string{}
+	for i := range s.Blocks {
+		b := &s.Blocks[i]
+		if !b.Matches(h.DisplayName) {
+			continue
+		}
+		for _, m := range b.Metadata {
+			key := strings.ToLower(m.Key)
+			if _, ok := meta[key]; !ok {
+				meta[key] = m.Value
+			}
+		}
+	}
+	return meta
+}
+
+// Tags returns the tags of the host, given as `#sshctx: tags=web,prod`.
+func (s *SSHConfig) Tags(h Host) []string {
+	var tags []string
+	for _, t := range strings.Split(s.Metadata(h)["tags"], ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// splitMetadata splits a `#sshctx: key=value` line into its key and value.
+// The value is the rest of the line, so it may contain spaces.
+func splitMetadata(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, metadataPrefix) {
+		return "", "", false
+	}
+	kv := strings.TrimSpace(strings.TrimPrefix(line, metadataPrefix))
+	i := strings.Index(kv, "=")
+	if i <= 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(kv[:i]), strings.TrimSpace(kv[i+1:]), true
+}
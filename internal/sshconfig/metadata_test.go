@@ -0,0 +1,72 @@
+package sshconfig
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const metadataExample = `
+#sshctx: backend=ssh
+
+Host laptop
+    Hostname 192.168.1.10
+    #sshctx: backend = mosh
+    # sshctx: not=metadata
+    #sshctx: tags=home,mobile
+
+Host laptop-*
+    #sshctx: backend=et
+    #sshctx: greeting=ssh -p {{.Port}} x=y
+    #sshctx: invalid
+`
+
+func TestSSHConfig_Metadata(t *testing.T) {
+	blocks, err := ParseBlocks(strings.NewReader(metadataExample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := &SSHConfig{Blocks: blocks}
+
+	tests := []struct {
+		host string
+		want map[string]string
+	}{
+		{host: "laptop", want: map[string]string{"backend": "ssh", "tags": "home,mobile"}},
+		{host: "laptop-2", want: map[string]string{"backend": "ssh", "greeting": "ssh -p {{.Port}} x=y"}},
+		{host: "db", want: map[string]string{"backend": "ssh"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := sc.Metadata(Host{DisplayName: tt.host}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Metadata() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if opts := sc.Options(Host{DisplayName: "laptop"}); len(opts) != 1 {
+		t.Errorf("metadata leaked into Options(): %v", opts)
+	}
+}
+
+func TestSSHConfig_Tags(t *testing.T) {
+	blocks, err := ParseBlocks(strings.NewReader(metadataExample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := &SSHConfig{Blocks: blocks}
+
+	if got := sc.Tags(Host{DisplayName: "laptop"}); !reflect.DeepEqual(got, []string{"home", "mobile"}) {
+		t.Errorf("Tags(laptop) = %v", got)
+	}
+	if got := sc.Tags(Host{DisplayName: "db"}); got != nil {
+		t.Errorf("Tags(db) = %v, want none", got)
+	}
+	b, ok := sc.HostBlock(Host{DisplayName: "laptop-*"})
+	if !ok || b.Line != 10 {
+		t.Errorf("HostBlock(laptop-*) = %+v, %v, want the block at line 10", b, ok)
+	}
+	if _, ok := sc.HostBlock(Host{DisplayName: "laptop-2"}); ok {
+		t.Error("HostBlock() returned a block matching by pattern")
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshconfig
+
+import (
+	"bufio"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Block is a `Host` or `Match` section of sshconfig. Options before the
+// first `Host` line form a block matching every host.
+type Block struct {
+	Patterns []string
+	Match    string // criteria of a `Match` block, only `all` is evaluated
+	Options  []Option
+	Metadata []Option // `#sshctx: key=value` comments of the block
+	Line     int      // 1-based line of the `Host` or `Match` keyword, 0 for the options before them
+}
+
+// Option is a `Keyword value` line of a Block.
+type Option struct {
+	Key   string // as written in sshconfig, compare with strings.EqualFold
+	Value string
+}
+
+// multiValued are the keywords ssh(1) accumulates instead of taking the first value.
+var multiValued = map[string]bool{
+	"identityfile":    true,
+	"certificatefile": true,
+	"localforward":    true,
+	"remoteforward":   true,
+	"dynamicforward":  true,
+	"sendenv":         true,
+	"setenv":          true,
+}
+
+// Matches reports whether the block applies to the host alias, following
+// the pattern rules of ssh_config(5): `*` and `?` wildcards, and a matching
+// `!pattern` excludes the host whatever the other patterns say.
+func (b *Block) Matches(alias string) bool {
+	if b.Match != "" {
+		return strings.EqualFold(b.Match, "all")
+	}
+	if strings.Join(b.Patterns, " ") == alias {
+		return true
+	}
+	matched := false
+	for _, p := range b.Patterns {
+		negated := strings.HasPrefix(p, "!")
+		ok, _ := path.Match(strings.TrimPrefix(p, "!"), alias)
+		if ok && negated {
+			return false
+		}
+		matched = matched || ok
+	}
+	return matched
+}
+
+// Options returns the options applying to the host like ssh(1) resolves them:
+// blocks are read in order and the first value of each keyword wins, except
+// for keywords like IdentityFile which collect all values.
+// Keys are lower case.
+func (s *SSHConfig) Options(h Host) map[string][]string {
+	opts := map[string][]string{}
+	for i := range s.Blocks {
+		b := &s.Blocks[i]
+		if !b.Matches(h.DisplayName) {
+			continue
+		}
+		for _, o := range b.Options {
+			key := strings.ToLower(o.Key)
+			if _, ok := opts[key]; ok && !multiValued[key] {
+				continue
+			}
+			opts[key] = append(opts[key], o.Value)
+		}
+	}
+	return opts
+}
+
+// HostBlock returns the `Host` block the host was read from.
+func (s *SSHConfig) HostBlock(h Host) (Block, bool) {
+	for _, b := range s.Blocks {
+		if b.Match == "" && b.Line > 0 && strings.Join(b.Patterns, " ") == h.DisplayName {
+			return b, true
+		}
+	}
+	return Block{}, false
+}
+
+// Option returns the value of a single-valued option of the host, or "" if it isn't set.
+func (s *SSHConfig) Option(h Host, key string) string {
+	if v := s.Options(h)[strings.ToLower(key)]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// ParseBlocks reads the Host and Match blocks of sshconfig.
+func ParseBlocks(r io.Reader) ([]Block, error) {
+	scanner := bufio.NewScanner(r)
+	blocks := []Block{{Patterns: []string{"*"}}}
+	line := 0
+	for scanner.Scan() {
+		line++
+		if key, value, ok := splitMetadata(scanner.Text()); ok {
+			b := &blocks[len(blocks)-1]
+			b.Metadata = append(b.Metadata, Option{Key: key, Value: value})
+			continue
+		}
+		key, value := splitOption(scanner.Text())
+		switch {
+		case key == "":
+			continue
+		case strings.EqualFold(key, "Host"):
+			blocks = append(blocks, Block{Patterns: strings.Fields(value), Line: line})
+		case strings.EqualFold(key, "Match"):
+			blocks = append(blocks, Block{Match: value, Line: line})
+		default:
+			b := &blocks[len(blocks)-1]
+			b.Options = append(b.Options, Option{Key: key, Value: value})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "Can not scan sshconfig")
+	}
+	return blocks, nil
+}
+
+// splitOption splits a sshconfig line into its keyword and value.
+// Comments and blank lines give an empty keyword.
+func splitOption(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", ""
+	}
+	i := strings.IndexAny(line, " \t=")
+	if i == -1 {
+		return line, ""
+	}
+	key := line[:i]
+	value := strings.TrimLeft(line[i:], " \t")
+	value = strings.TrimPrefix(value, "=")
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+	}
+	return key, value
+}
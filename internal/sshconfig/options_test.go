@@ -0,0 +1,119 @@
+package sshconfig
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const optionsExample = `
+Compression yes
+
+Host web-*
+    User deploy
+    ControlPath ~/.ssh/cm-%r@%h:%p
+    IdentityFile ~/.ssh/web
+
+Host web-1
+    Hostname 10.0.0.1
+    User root
+    Port=2222
+
+Match host bastion
+    User nobody
+
+Host * !web-2
+    ControlMaster auto
+    IdentityFile "~/.ssh/id_ed25519"
+    User fallback
+`
+
+func TestSSHConfig_Options(t *testing.T) {
+	blocks, err := ParseBlocks(strings.NewReader(optionsExample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := &SSHConfig{Blocks: blocks}
+
+	tests := []struct {
+		name string
+		host string
+		want map[string][]string
+	}{
+		{
+			name: "first-value-wins",
+			host: "web-1",
+			want: map[string][]string{
+				"compression":   {"yes"},
+				"user":          {"deploy"},
+				"controlpath":   {"~/.ssh/cm-%r@%h:%p"},
+				"identityfile":  {"~/.ssh/web", "~/.ssh/id_ed25519"},
+				"hostname":      {"10.0.0.1"},
+				"port":          {"2222"},
+				"controlmaster": {"auto"},
+			},
+		},
+		{
+			name: "negated-pattern",
+			host: "web-2",
+			want: map[string][]string{
+				"compression":  {"yes"},
+				"user":         {"deploy"},
+				"controlpath":  {"~/.ssh/cm-%r@%h:%p"},
+				"identityfile": {"~/.ssh/web"},
+			},
+		},
+		{
+			name: "wildcard-only",
+			host: "db",
+			want: map[string][]string{
+				"compression":   {"yes"},
+				"controlmaster": {"auto"},
+				"identityfile":  {"~/.ssh/id_ed25519"},
+				"user":          {"fallback"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sc.Options(Host{DisplayName: tt.host})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Options() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := sc.Option(Host{DisplayName: "web-1"}, "ControlPath"); got != "~/.ssh/cm-%r@%h:%p" {
+		t.Errorf("Option() = %q", got)
+	}
+	if got := sc.Option(Host{DisplayName: "web-2"}, "ControlMaster"); got != "" {
+		t.Errorf("Option() of an unset option = %q, want empty", got)
+	}
+}
+
+func TestExpandTokens(t *testing.T) {
+	tokens := map[byte]string{
+		'C': connectionHash("laptop", "10.0.0.1", "22", "root", ""),
+		'd': "/home/me",
+		'h': "10.0.0.1",
+		'p': "22",
+		'r': "root",
+		'n': "web-1",
+	}
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "~/.ssh/cm-%r@%h:%p", want: "/home/me/.ssh/cm-root@10.0.0.1:22"},
+		{value: "%d/.ssh/%C", want: "/home/me/.ssh/710e32394270d174e320537d163a8326072db8ee"},
+		{value: "/tmp/%n-100%%-%x", want: "/tmp/web-1-100%-%x"},
+		{value: "/tmp/trailing%", want: "/tmp/trailing%"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := expandTokens(tt.value, tokens); got != tt.want {
+				t.Errorf("expandTokens() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
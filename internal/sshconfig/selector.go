@@ -0,0 +1,81 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshconfig
+
+import (
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Select returns the hosts whose DisplayName matches the selector.
+// A selector is a comma-separated list of shell patterns, e.g. `web-*,db-1`.
+// Hosts are returned in the order they appear in hosts, without duplicates.
+func Select(hosts []Host, selector string) ([]Host, error) {
+	patterns, err := selectorPatterns(selector)
+	if err != nil {
+		return nil, err
+	}
+	var selected []Host
+	for _, h := range hosts {
+		ok, err := h.matchesAny(patterns)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			selected = append(selected, h)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, errors.Errorf("no host matches selector: %s", selector)
+	}
+	return selected, nil
+}
+
+// MatchesSelector reports whether the DisplayName of the host matches the selector.
+func (h *Host) MatchesSelector(selector string) (bool, error) {
+	patterns, err := selectorPatterns(selector)
+	if err != nil {
+		return false, err
+	}
+	return h.matchesAny(patterns)
+}
+
+func (h *Host) matchesAny(patterns []string) (bool, error) {
+	for _, p := range patterns {
+		ok, err := path.Match(p, h.DisplayName)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid host selector %q", p)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func selectorPatterns(selector string) ([]string, error) {
+	var patterns []string
+	for _, p := range strings.Split(selector, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	if len(patterns) == 0 {
+		return nil, errors.New("empty host selector")
+	}
+	return patterns, nil
+}
@@ -0,0 +1,43 @@
+package sshconfig
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelect(t *testing.T) {
+	hosts := []Host{
+		{DisplayName: "web-1"},
+		{DisplayName: "web-2"},
+		{DisplayName: "db-1"},
+	}
+	tests := []struct {
+		name     string
+		selector string
+		want     []string
+		wantErr  bool
+	}{
+		{name: "exact", selector: "db-1", want: []string{"db-1"}},
+		{name: "glob", selector: "web-*", want: []string{"web-1", "web-2"}},
+		{name: "list", selector: "db-1, web-2", want: []string{"web-2", "db-1"}},
+		{name: "overlap", selector: "web-*,web-1", want: []string{"web-1", "web-2"}},
+		{name: "no-match", selector: "cache-*", wantErr: true},
+		{name: "empty", selector: " , ", wantErr: true},
+		{name: "bad-pattern", selector: "web-[", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Select(hosts, tt.selector)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Select() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var names []string
+			for _, h := range got {
+				names = append(names, h.DisplayName)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("Select() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
@@ -16,9 +16,11 @@ package sshconfig
 
 import (
 	"bufio"
+	"bytes"
 	"github.com/spencercjh/sshctx/internal/printer"
 	"gopkg.in/yaml.v3"
 	"io"
+	"net"
 	"os"
 	"strconv"
 	"strings"
@@ -36,6 +38,7 @@ type SSHConfig struct {
 	sshctxDataRWC io.ReadWriteCloser
 	sshconfigRWC  io.ReadWriteCloser
 	Hosts         []Host
+	Blocks        []Block
 	PreviousHost  Host
 	rootNode      *yaml.Node
 }
@@ -49,11 +52,40 @@ type Host struct {
 
 func (h *Host) ToSSHParameter() string {
 	if h.Port > 0 {
-		return h.Username + "@" + h.Host + " -p " + strconv.Itoa(h.Port)
+		return h.Destination() + " -p " + strconv.Itoa(h.Port)
+	}
+	return h.Destination()
+}
+
+// Destination returns user@host, or only the host when it has no user.
+func (h *Host) Destination() string {
+	if h.Username == "" {
+		return h.Host
 	}
 	return h.Username + "@" + h.Host
 }
 
+// ToSSHArgs returns the destination of the host as separate ssh(1) arguments.
+func (h *Host) ToSSHArgs() []string {
+	if h.Port > 0 {
+		return []string{"-p", strconv.Itoa(h.Port), h.Destination()}
+	}
+	return []string{h.Destination()}
+}
+
+// EffectivePort returns the port ssh(1) connects to for the host.
+func (h *Host) EffectivePort() int {
+	if h.Port > 0 {
+		return h.Port
+	}
+	return 22
+}
+
+// Addr returns the host:port address ssh(1) connects to for the host.
+func (h *Host) Addr() string {
+	return net.JoinHostPort(h.Host, strconv.Itoa(h.EffectivePort()))
+}
+
 var EmptyHost = Host{}
 
 type SSHCTXData struct {
@@ -94,7 +126,15 @@ func (s *SSHConfig) Parse() error {
 	}
 	s.sshctxDataRWC = sshctxData
 
-	s.Hosts, err = getSSHConfigItems(s.sshconfigRWC)
+	data, err := io.ReadAll(s.sshconfigRWC)
+	if err != nil {
+		return errors.Wrap(err, "Can not read sshconfig")
+	}
+	s.Hosts, err = getSSHConfigItems(bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "Can not parse sshconfig")
+	}
+	s.Blocks, err = ParseBlocks(bytes.NewReader(data))
 	if err != nil {
 		return errors.Wrap(err, "Can not parse sshconfig")
 	}
@@ -123,6 +163,16 @@ func (s *SSHConfig) Parse() error {
 	return nil
 }
 
+// Lookup returns the host with the given DisplayName.
+func (s *SSHConfig) Lookup(displayName string) (Host, error) {
+	for _, h := range s.Hosts {
+		if h.DisplayName == displayName {
+			return h, nil
+		}
+	}
+	return EmptyHost, errors.Errorf("no config for host: %s", displayName)
+}
+
 func getSSHConfigItems(rwc io.Reader) ([]Host, error) {
 	s := bufio.NewScanner(rwc)
 
@@ -137,7 +187,7 @@ func getSSHConfigItems(rwc io.Reader) ([]Host, error) {
 		itemBeginIndex := i
 		var itemEndIndex int
 		if j >= len(hostIndices) {
-			itemEndIndex = len(rows) - 1
+			itemEndIndex = len(rows)
 		} else {
 			itemEndIndex = hostIndices[j]
 		}
@@ -165,10 +215,10 @@ func extractConfigItem(itemBeginIndex int, itemEndIndex int, rows []string) Host
 		if strings.HasPrefix(itemRow, "Hostname ") {
 			hostname = strings.TrimSpace(itemRow[9:])
 		}
-		if strings.HasPrefix(itemRow, "User") {
+		if strings.HasPrefix(itemRow, "User ") {
 			configItem.Username = strings.TrimSpace(itemRow[4:])
 		}
-		if strings.HasPrefix(itemRow, "Port") {
+		if strings.HasPrefix(itemRow, "Port ") {
 			configItem.Port, _ = strconv.Atoi(strings.TrimSpace(itemRow[4:]))
 		}
 	}
@@ -27,6 +27,8 @@ import (
 
 var (
 	DefaultLoader Loader = new(StandardLoader)
+	// ReadOnlyLoader is for commands only looking at hosts, like --dry-run.
+	ReadOnlyLoader Loader = new(ReadOnlyStandardLoader)
 )
 
 type StandardLoader struct{}
@@ -34,7 +36,7 @@ type StandardLoader struct{}
 // LoadSSHConfig loads the SSH config from the given path.
 // return: sshconfig, sshctxData, error
 func (*StandardLoader) LoadSSHConfig() (io.ReadWriteCloser, error) {
-	path, err := getSSHConfigPath()
+	path, err := GetSSHConfigPath()
 	if err != nil {
 		return nil, errors.Wrap(err, "Can't determine sshconfig path")
 	}
@@ -55,7 +57,7 @@ func (*StandardLoader) LoadSSHCTXData() (io.ReadWriteCloser, error) {
 	file, err = openFile(path, "sshctxData")
 	if err != nil {
 		_ = printer.Warning(os.Stderr, "Can't open given sshctxData by path: %s", path)
-		dir, _ := getSSHCtxDataDir()
+		dir, _ := GetSSHCtxDataDir()
 		defaultPath := filepath.Join(dir, "config.yaml")
 		// try to open default sshctxData
 		file, err = openFile(defaultPath, "sshctxData")
@@ -82,7 +84,44 @@ func (*StandardLoader) LoadSSHCTXData() (io.ReadWriteCloser, error) {
 	return io.ReadWriteCloser(file), nil
 }
 
-func getSSHConfigPath() (string, error) {
+// ReadOnlyStandardLoader loads like StandardLoader, but opens sshctxData
+// read-only and doesn't create it when it's missing.
+type ReadOnlyStandardLoader struct {
+	StandardLoader
+}
+
+func (*ReadOnlyStandardLoader) LoadSSHCTXData() (io.ReadWriteCloser, error) {
+	path, err := GetSSHCtxDataPath()
+	if err != nil {
+		return nil, errors.Wrap(err, "Can't determine sshconfig path")
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return emptyData{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to open sshctxData")
+	}
+	return io.ReadWriteCloser(file), nil
+}
+
+// emptyData stands for a missing sshctxData file.
+type emptyData struct{}
+
+func (emptyData) Read([]byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (emptyData) Write([]byte) (int, error) {
+	return 0, errors.New("sshctxData is read-only")
+}
+
+func (emptyData) Close() error {
+	return nil
+}
+
+// GetSSHConfigPath returns the path of the sshconfig file, $SSHCONFIG or ~/.ssh/config.
+func GetSSHConfigPath() (string, error) {
 	// for dev
 	if v := os.Getenv("SSHCONFIG"); v != "" {
 		list := filepath.SplitList(v)
@@ -101,7 +140,8 @@ func getSSHConfigPath() (string, error) {
 	return filepath.Join(home, ".ssh", "config"), nil
 }
 
-func getSSHCtxDataDir() (string, error) {
+// GetSSHCtxDataDir returns the directory sshctx keeps its files in, ~/.sshctx.
+func GetSSHCtxDataDir() (string, error) {
 	home := cmdutil.HomeDir()
 	if home == "" {
 		return "", errors.New("HOME or USERPROFILE environment variable not set")
@@ -120,7 +160,7 @@ func GetSSHCtxDataPath() (string, error) {
 		return v, nil
 	}
 
-	dir, _ := getSSHCtxDataDir()
+	dir, _ := GetSSHCtxDataDir()
 	return filepath.Join(dir, "config.yaml"), nil
 }
 
@@ -10,7 +10,7 @@ import (
 	"testing"
 )
 
-func Test_getSSHCtxDataDir(t *testing.T) {
+func TestGetSSHCtxDataDir(t *testing.T) {
 	tests := []struct {
 		name    string
 		want    string
@@ -20,13 +20,13 @@ func Test_getSSHCtxDataDir(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := getSSHCtxDataDir()
+			got, err := GetSSHCtxDataDir()
 			if (err != nil) != tt.wantErr {
-				t.Errorf("getSSHCtxDataDir() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("GetSSHCtxDataDir() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if got != tt.want {
-				t.Errorf("getSSHCtxDataDir() got = %v, want %v", got, tt.want)
+				t.Errorf("GetSSHCtxDataDir() got = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -54,7 +54,7 @@ func TestGetSSHCtxDataPath(t *testing.T) {
 	}
 }
 
-func Test_getSSHConfigPath(t *testing.T) {
+func TestGetSSHConfigPath(t *testing.T) {
 	testutil.SetupSSHConfig(t)
 	defer testutil.TearDownSSHConfig()
 
@@ -67,13 +67,13 @@ func Test_getSSHConfigPath(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := getSSHConfigPath()
+			got, err := GetSSHConfigPath()
 			if (err != nil) != tt.wantErr {
-				t.Errorf("getSSHConfigPath() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("GetSSHConfigPath() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if got != tt.want {
-				t.Errorf("getSSHConfigPath() got = %v, want %v", got, tt.want)
+				t.Errorf("GetSSHConfigPath() got = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -120,7 +120,7 @@ var cwd, _ = os.Getwd()
 func tearUpSSHCTXData() {
 	path, _ := GetSSHCtxDataPath()
 	_ = os.Remove(path)
-	dir, _ := getSSHCtxDataDir()
+	dir, _ := GetSSHCtxDataDir()
 	_ = os.Remove(dir)
 }
 
@@ -200,7 +200,7 @@ func TestStandardLoader_LoadSSHCTXData(t *testing.T) {
 				t.Errorf("test file: %s shouldn't exist but not", path)
 			}
 		}
-		dir, _ := getSSHCtxDataDir()
+		dir, _ := GetSSHCtxDataDir()
 		_ = os.Remove(filepath.Join(dir, "config.yaml"))
 		st := &StandardLoader{}
 		sshctx, err := st.LoadSSHCTXData()
@@ -222,7 +222,7 @@ func TestStandardLoader_LoadSSHCTXData(t *testing.T) {
 				t.Errorf("test file: %s shouldn't exist but not", path)
 			}
 		}
-		dir, _ := getSSHCtxDataDir()
+		dir, _ := GetSSHCtxDataDir()
 		_ = os.Mkdir(dir, 0777)
 		st := &StandardLoader{}
 		sshctx, err := st.LoadSSHCTXData()
@@ -239,7 +239,7 @@ func TestStandardLoader_LoadSSHConfig(t *testing.T) {
 	t.Setenv(env.Debug, "true")
 	testutil.SetupSSHConfig(t)
 	defer testutil.TearDownSSHConfig()
-	defaultSSHConfigPath, _ := getSSHConfigPath()
+	defaultSSHConfigPath, _ := GetSSHConfigPath()
 	if _, err := os.Stat(defaultSSHConfigPath); err == nil {
 		// ~/.ssh/config exist
 		t.Run("default", func(t *testing.T) {
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -18,6 +19,7 @@ func TestHost_ToSSHParameter(t *testing.T) {
 		{name: "ipv4", host: Host{Host: "192.168.1.1", Username: "test", Port: 22}, want: "test@192.168.1.1 -p 22"},
 		{name: "domain", host: Host{Host: "test.com", Username: "test", Port: 22}, want: "test@test.com -p 22"},
 		{name: "localhost", host: Host{Host: "localhost", Username: "test", Port: 22}, want: "test@localhost -p 22"},
+		{name: "no-user", host: Host{Host: "web1"}, want: "web1"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -33,6 +35,28 @@ func TestHost_ToSSHParameter(t *testing.T) {
 	}
 }
 
+func Test_getSSHConfigItems_lastLine(t *testing.T) {
+	config := "Host web\n    Hostname 10.0.0.1\n\nHost db\n    Hostname 10.0.0.2\n    User admin\n"
+	hosts, err := getSSHConfigItems(strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 2 || hosts[1].Username != "admin" {
+		t.Errorf("getSSHConfigItems() = %+v, want the last line of the file to be read", hosts)
+	}
+}
+
+func Test_getSSHConfigItems_similarKeywords(t *testing.T) {
+	config := "Host web\n    Hostname 10.0.0.1\n    User admin\n    UserKnownHostsFile /dev/null\n    Port 2222\n"
+	hosts, err := getSSHConfigItems(strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0].Username != "admin" || hosts[0].Port != 2222 {
+		t.Errorf("getSSHConfigItems() = %+v, want User and Port not to be taken from other keywords", hosts)
+	}
+}
+
 func TestSSHConfig_Parse(t *testing.T) {
 	testutil.SetupSSHConfig(t)
 	defer testutil.TearDownSSHConfig()
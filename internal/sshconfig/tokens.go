@@ -0,0 +1,88 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshconfig
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/spencercjh/sshctx/internal/cmdutil"
+)
+
+// ExpandTokens expands a leading `~` and the %-tokens of ssh_config(5) in
+// value, e.g. `~/.ssh/cm-%r@%h:%p` or `~/.ssh/cm-%C` in ControlPath.
+func (s *SSHConfig) ExpandTokens(h Host, value string) string {
+	return expandTokens(value, s.tokens(h))
+}
+
+func (s *SSHConfig) tokens(h Host) map[byte]string {
+	local, _ := os.Hostname()
+	localUser := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		localUser = u.Username
+	}
+	jump := s.Option(h, "ProxyJump")
+	if strings.EqualFold(jump, "none") {
+		jump = ""
+	}
+	port := strconv.Itoa(h.EffectivePort())
+	return map[byte]string{
+		'C': connectionHash(local, h.Host, port, h.Username, jump),
+		'd': cmdutil.HomeDir(),
+		'h': h.Host,
+		'i': strconv.Itoa(os.Getuid()),
+		'j': jump,
+		'L': strings.SplitN(local, ".", 2)[0],
+		'l': local,
+		'n': h.DisplayName,
+		'p': port,
+		'r': h.Username,
+		'u': localUser,
+	}
+}
+
+// connectionHash is the %C token: a hash of %l%h%p%r%j.
+func connectionHash(local, host, port, user, jump string) string {
+	sum := sha1.Sum([]byte(local + host + port + user + jump))
+	return hex.EncodeToString(sum[:])
+}
+
+func expandTokens(value string, tokens map[byte]string) string {
+	if value == "~" || strings.HasPrefix(value, "~/") {
+		value = tokens['d'] + value[1:]
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '%' || i+1 == len(value) {
+			b.WriteByte(value[i])
+			continue
+		}
+		i++
+		if value[i] == '%' {
+			b.WriteByte('%')
+		} else if v, ok := tokens[value[i]]; ok {
+			b.WriteString(v)
+		} else {
+			// unknown tokens are kept as they are
+			b.WriteByte('%')
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
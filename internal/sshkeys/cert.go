@@ -0,0 +1,61 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshkeys
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ValidAfter returns when the certificate becomes valid.
+func ValidAfter(cert *ssh.Certificate) time.Time {
+	return time.Unix(int64(cert.ValidAfter), 0)
+}
+
+// ValidBefore returns when the certificate expires, and false if it never does.
+func ValidBefore(cert *ssh.Certificate) (time.Time, bool) {
+	if cert.ValidBefore == ssh.CertTimeInfinity || cert.ValidBefore > uint64(1<<63-1) {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(cert.ValidBefore), 0), true
+}
+
+// CheckValidity returns why the certificate can't be used at now.
+func CheckValidity(cert *ssh.Certificate, now time.Time) []string {
+	var problems []string
+	if after := ValidAfter(cert); now.Before(after) {
+		problems = append(problems, fmt.Sprintf("not valid until %s", after.Format(time.RFC3339)))
+	}
+	if before, ok := ValidBefore(cert); ok && !now.Before(before) {
+		problems = append(problems, fmt.Sprintf("expired %s ago", now.Sub(before).Round(time.Second)))
+	}
+	return problems
+}
+
+// HasPrincipal reports whether the user certificate lets user log in.
+// A certificate without principals is valid for any user.
+func HasPrincipal(cert *ssh.Certificate, user string) bool {
+	if len(cert.ValidPrincipals) == 0 {
+		return true
+	}
+	for _, p := range cert.ValidPrincipals {
+		if p == user {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package sshkeys
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestCheckValidity(t *testing.T) {
+	now := time.Date(2021, 11, 5, 12, 0, 0, 0, time.UTC)
+	unix := func(d time.Duration) uint64 { return uint64(now.Add(d).Unix()) }
+	tests := []struct {
+		name string
+		cert *ssh.Certificate
+		want int
+	}{
+		{name: "valid", cert: &ssh.Certificate{ValidAfter: unix(-time.Hour), ValidBefore: unix(time.Hour)}},
+		{name: "forever", cert: &ssh.Certificate{ValidBefore: ssh.CertTimeInfinity}},
+		{name: "expired", cert: &ssh.Certificate{ValidAfter: unix(-2 * time.Hour), ValidBefore: unix(-time.Hour)}, want: 1},
+		{name: "not-yet-valid", cert: &ssh.Certificate{ValidAfter: unix(time.Hour), ValidBefore: unix(2 * time.Hour)}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckValidity(tt.cert, now); len(got) != tt.want {
+				t.Errorf("CheckValidity() = %q, want %d problems", got, tt.want)
+			}
+		})
+	}
+	if got := CheckValidity(tests[2].cert, now); got[0] != "expired 1h0m0s ago" {
+		t.Errorf("CheckValidity() = %q", got)
+	}
+}
+
+func TestHasPrincipal(t *testing.T) {
+	cert := &ssh.Certificate{ValidPrincipals: []string{"root", "deploy"}}
+	if !HasPrincipal(cert, "deploy") || HasPrincipal(cert, "admin") {
+		t.Errorf("HasPrincipal() doesn't match ValidPrincipals")
+	}
+	if !HasPrincipal(&ssh.Certificate{}, "admin") {
+		t.Errorf("HasPrincipal() of a certificate without principals should be true")
+	}
+}
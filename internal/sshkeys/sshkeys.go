@@ -0,0 +1,158 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshkeys
+
+import (
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// minRSABits is the size below which RSA keys are reported as weak.
+const minRSABits = 2048
+
+// Info describes an identity or certificate file.
+type Info struct {
+	Path        string
+	Type        string // key type like ssh-ed25519, or the certificate type
+	Bits        int
+	Fingerprint string // SHA256 fingerprint of the public key
+	Cert        *ssh.Certificate
+	Problems    []string
+}
+
+// InspectIdentity reads the identity file at path and audits it.
+func InspectIdentity(path string) Info {
+	info := Info{Path: path}
+	fi, err := os.Stat(path)
+	if err != nil {
+		info.Problems = append(info.Problems, "missing")
+		return info
+	}
+	// ssh(1) refuses private keys others can read, permissions don't apply on windows
+	if perm := fi.Mode().Perm(); runtime.GOOS != "windows" && perm&0077 != 0 {
+		info.Problems = append(info.Problems, fmt.Sprintf("readable by others (%04o)", perm))
+	}
+	pub, err := PublicKey(path)
+	if err != nil {
+		info.Problems = append(info.Problems, err.Error())
+		return info
+	}
+	info.describe(pub)
+	return info
+}
+
+// InspectCertificate reads the certificate file at path and audits it.
+func InspectCertificate(path string) Info {
+	info := Info{Path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		info.Problems = append(info.Problems, "missing")
+		return info
+	} else if err != nil {
+		info.Problems = append(info.Problems, err.Error())
+		return info
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		info.Problems = append(info.Problems, "invalid certificate: "+err.Error())
+		return info
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		info.Problems = append(info.Problems, "not a certificate")
+		return info
+	}
+	info.Cert = cert
+	info.describe(cert.Key)
+	info.Type = cert.Type()
+	if cert.Signature != nil && cert.Signature.Format == ssh.KeyAlgoRSA {
+		info.Problems = append(info.Problems, "signed with ssh-rsa (SHA-1), deprecated")
+	}
+	return info
+}
+
+// describe fills in the type, size and fingerprint of pub and flags deprecated keys.
+func (info *Info) describe(pub ssh.PublicKey) {
+	info.Type = pub.Type()
+	info.Fingerprint = ssh.FingerprintSHA256(pub)
+	info.Bits = bits(pub)
+	switch {
+	case info.Type == ssh.KeyAlgoDSA:
+		info.Problems = append(info.Problems, "DSA is deprecated")
+	case info.Type == ssh.KeyAlgoRSA && info.Bits < minRSABits:
+		info.Problems = append(info.Problems, fmt.Sprintf("RSA key shorter than %d bits", minRSABits))
+	}
+}
+
+// AllowsSHA1 reports whether a PubkeyAcceptedAlgorithms value of ssh_config(5)
+// lets ssh(1) sign with ssh-rsa, whose signatures use SHA-1. OpenSSH doesn't
+// by default since 8.8.
+func AllowsSHA1(algorithms string) bool {
+	if algorithms == "" || strings.HasPrefix(algorithms, "-") {
+		return false
+	}
+	for _, a := range strings.Split(strings.TrimLeft(algorithms, "+^"), ",") {
+		if ok, _ := path.Match(strings.TrimSpace(a), ssh.KeyAlgoRSA); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func bits(pub ssh.PublicKey) int {
+	cpk, ok := pub.(ssh.CryptoPublicKey)
+	if !ok {
+		// security keys
+		return 256
+	}
+	switch k := cpk.CryptoPublicKey().(type) {
+	case *rsa.PublicKey:
+		return k.N.BitLen()
+	case *ecdsa.PublicKey:
+		return k.Curve.Params().BitSize
+	case *dsa.PublicKey:
+		return k.P.BitLen()
+	}
+	return 256
+}
+
+// PublicKey reads the public key of the identity file at path, from the
+// .pub file next to it or from the private key if it isn't encrypted.
+func PublicKey(path string) (ssh.PublicKey, error) {
+	if data, err := os.ReadFile(path + ".pub"); err == nil {
+		pub, _, _, _, err := ssh.ParseAuthorizedKey(data)
+		return pub, errors.Wrapf(err, "invalid public key %s.pub", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read identity file")
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if _, ok := err.(*ssh.PassphraseMissingError); ok {
+		return nil, errors.Errorf("%s is encrypted and has no .pub file", path)
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "invalid identity file %s", path)
+	}
+	return signer.PublicKey(), nil
+}
@@ -0,0 +1,127 @@
+package sshkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// writeKey writes a dummy private key with the given mode and the .pub file of pub next to it.
+func writeKey(t *testing.T, path string, pub crypto.PublicKey, mode os.FileMode) ssh.PublicKey {
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("private"), mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".pub", ssh.MarshalAuthorizedKey(sshPub), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return sshPub
+}
+
+func TestInspectIdentity(t *testing.T) {
+	dir := t.TempDir()
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed := writeKey(t, filepath.Join(dir, "ed"), edPub, 0600)
+	writeKey(t, filepath.Join(dir, "rsa"), &rsaKey.PublicKey, 0644)
+
+	info := InspectIdentity(filepath.Join(dir, "ed"))
+	if info.Type != "ssh-ed25519" || info.Bits != 256 || info.Fingerprint != ssh.FingerprintSHA256(ed) || len(info.Problems) != 0 {
+		t.Errorf("InspectIdentity(ed) = %+v", info)
+	}
+
+	info = InspectIdentity(filepath.Join(dir, "rsa"))
+	want := []string{"readable by others (0644)", "RSA key shorter than 2048 bits"}
+	if runtime.GOOS == "windows" {
+		want = want[1:]
+	}
+	if info.Type != "ssh-rsa" || info.Bits != 1024 || !reflect.DeepEqual(info.Problems, want) {
+		t.Errorf("InspectIdentity(rsa) = %+v, want problems %q", info, want)
+	}
+
+	info = InspectIdentity(filepath.Join(dir, "missing"))
+	if !reflect.DeepEqual(info.Problems, []string{"missing"}) {
+		t.Errorf("InspectIdentity(missing) = %+v", info)
+	}
+}
+
+func TestInspectCertificate(t *testing.T) {
+	dir := t.TempDir()
+	userPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, caPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := ssh.NewSignerFromKey(caPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(userPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &ssh.Certificate{Key: key, CertType: ssh.UserCert, ValidPrincipals: []string{"root"}, ValidBefore: ssh.CertTimeInfinity}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "id-cert.pub")
+	if err := os.WriteFile(path, ssh.MarshalAuthorizedKey(cert), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	info := InspectCertificate(path)
+	if info.Cert == nil || info.Type != ssh.CertAlgoED25519v01 || info.Fingerprint != ssh.FingerprintSHA256(key) || len(info.Problems) != 0 {
+		t.Errorf("InspectCertificate() = %+v", info)
+	}
+	if info := InspectCertificate(path + ".missing"); !reflect.DeepEqual(info.Problems, []string{"missing"}) {
+		t.Errorf("InspectCertificate(missing) = %+v", info)
+	}
+	if err := os.WriteFile(path, ssh.MarshalAuthorizedKey(key), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if info := InspectCertificate(path); !reflect.DeepEqual(info.Problems, []string{"not a certificate"}) {
+		t.Errorf("InspectCertificate(public key) = %+v", info)
+	}
+}
+
+func TestAllowsSHA1(t *testing.T) {
+	tests := []struct {
+		algorithms string
+		want       bool
+	}{
+		{algorithms: "", want: false},
+		{algorithms: "+ssh-rsa", want: true},
+		{algorithms: "^ssh-rsa,ssh-ed25519", want: true},
+		{algorithms: "ssh-ed25519,rsa-sha2-512", want: false},
+		{algorithms: "ssh-*", want: true},
+		{algorithms: "-ssh-rsa", want: false},
+	}
+	for _, tt := range tests {
+		if got := AllowsSHA1(tt.algorithms); got != tt.want {
+			t.Errorf("AllowsSHA1(%q) = %v, want %v", tt.algorithms, got, tt.want)
+		}
+	}
+}
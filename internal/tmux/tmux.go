@@ -0,0 +1,135 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tmux
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/cmdutil"
+)
+
+// Layouts of connections in tmux.
+const (
+	Window = "window" // a new window
+	Split  = "split"  // a new pane in the current window
+	Tiled  = "tiled"  // a new window with a pane for each host
+)
+
+// Pane is a command to run in its own tmux pane.
+type Pane struct {
+	Title string // DisplayName of the host
+	Argv  []string
+	// Env holds NAME=value pairs set in the pane. Panes otherwise get the
+	// environment of the tmux server, not the one of sshctx.
+	Env []string
+}
+
+// command returns the arguments of new-window and split-window running p.
+func (p Pane) command() []string {
+	var args []string
+	for _, kv := range p.Env {
+		args = append(args, "-e", kv)
+	}
+	return append(args, cmdutil.ShellQuote(p.Argv))
+}
+
+// Inside reports whether sshctx runs inside a tmux session.
+func Inside() bool {
+	return os.Getenv("TMUX") != ""
+}
+
+// Client runs tmux commands against the current tmux server.
+type Client struct {
+	Bin string // tmux binary, "tmux" if empty
+}
+
+// OpenWindow runs p in a new window named after its title.
+func (c *Client) OpenWindow(p Pane) error {
+	_, err := c.run(append([]string{"new-window", "-n", p.Title}, p.command()...)...)
+	return err
+}
+
+// OpenSplit runs p in a new pane split from the current one.
+func (c *Client) OpenSplit(p Pane) error {
+	id, err := c.run(append([]string{"split-window", "-P", "-F", "#{pane_id}"}, p.command()...)...)
+	if err != nil {
+		return err
+	}
+	return c.setTitle(id, p.Title)
+}
+
+// OpenTiled runs each pane in a new window named name, tiling the panes.
+// sync types into all panes at once with synchronize-panes.
+func (c *Client) OpenTiled(name string, panes []Pane, sync bool) error {
+	if len(panes) == 0 {
+		return errors.New("no panes to open")
+	}
+	ids, err := c.run(append([]string{"new-window", "-P", "-F", "#{window_id} #{pane_id}", "-n", name}, panes[0].command()...)...)
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(ids)
+	if len(fields) != 2 {
+		return errors.Errorf("unexpected output of tmux new-window: %q", ids)
+	}
+	window := fields[0]
+	if err := c.setTitle(fields[1], panes[0].Title); err != nil {
+		return err
+	}
+	for _, p := range panes[1:] {
+		id, err := c.run(append([]string{"split-window", "-t", window, "-P", "-F", "#{pane_id}"}, p.command()...)...)
+		if err != nil {
+			return err
+		}
+		if err := c.setTitle(id, p.Title); err != nil {
+			return err
+		}
+		// re-tile after each split, or tmux runs out of space for the next one
+		if _, err := c.run("select-layout", "-t", window, "tiled"); err != nil {
+			return err
+		}
+	}
+	if sync {
+		if _, err := c.run("set-window-option", "-t", window, "synchronize-panes", "on"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) setTitle(pane, title string) error {
+	_, err := c.run("select-pane", "-t", pane, "-T", title)
+	return err
+}
+
+// run runs a tmux command and returns its output.
+func (c *Client) run(args ...string) (string, error) {
+	bin := c.Bin
+	if bin == "" {
+		bin = "tmux"
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(bin, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", errors.Wrapf(err, "tmux %s failed: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,104 @@
+package tmux
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeTmux is a tmux that logs its arguments, one command per line, and
+// prints ids like tmux does for -P.
+const fakeTmux = `#!/bin/sh
+echo "$*" >> "$(dirname "$0")/log"
+n=$(wc -l < "$(dirname "$0")/log" | tr -d ' ')
+case "$1" in
+new-window) case "$*" in *-P*) echo "@1 %$n" ;; esac ;;
+split-window) echo "%$n" ;;
+esac
+`
+
+func newFakeTmux(t *testing.T) (*Client, func() []string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tmux is a shell script")
+	}
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "tmux")
+	if err := os.WriteFile(bin, []byte(fakeTmux), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &Client{Bin: bin}, func() []string {
+		data, _ := os.ReadFile(filepath.Join(dir, "log"))
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+}
+
+func assertCommands(t *testing.T, got, want []string) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("tmux commands:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestClient_OpenWindow(t *testing.T) {
+	c, log := newFakeTmux(t)
+	if err := c.OpenWindow(Pane{Title: "web", Argv: []string{"ssh", "-t", "-t", "root@10.0.0.1"}}); err != nil {
+		t.Fatal(err)
+	}
+	assertCommands(t, log(), []string{"new-window -n web ssh -t -t root@10.0.0.1"})
+}
+
+func TestClient_OpenSplit(t *testing.T) {
+	c, log := newFakeTmux(t)
+	p := Pane{Title: "web", Argv: []string{"mosh", "--ssh=ssh -p 2222", "root@10.0.0.1"}, Env: []string{"SSHCONFIG=/tmp/ssh config"}}
+	if err := c.OpenSplit(p); err != nil {
+		t.Fatal(err)
+	}
+	assertCommands(t, log(), []string{
+		"split-window -P -F #{pane_id} -e SSHCONFIG=/tmp/ssh config mosh '--ssh=ssh -p 2222' root@10.0.0.1",
+		"select-pane -t %1 -T web",
+	})
+}
+
+func TestClient_OpenTiled(t *testing.T) {
+	panes := []Pane{
+		{Title: "web-1", Argv: []string{"ssh", "root@10.0.0.1"}, Env: []string{"SSHCTX=/tmp/sshctx.yaml"}},
+		{Title: "web-2", Argv: []string{"ssh", "root@10.0.0.2"}, Env: []string{"SSHCTX=/tmp/sshctx.yaml"}},
+		{Title: "web-3", Argv: []string{"ssh", "root@10.0.0.3"}},
+	}
+	for _, sync := range []bool{false, true} {
+		c, log := newFakeTmux(t)
+		if err := c.OpenTiled("web-*", panes, sync); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{
+			"new-window -P -F #{window_id} #{pane_id} -n web-* -e SSHCTX=/tmp/sshctx.yaml ssh root@10.0.0.1",
+			"select-pane -t %1 -T web-1",
+			"split-window -t @1 -P -F #{pane_id} -e SSHCTX=/tmp/sshctx.yaml ssh root@10.0.0.2",
+			"select-pane -t %3 -T web-2",
+			"select-layout -t @1 tiled",
+			"split-window -t @1 -P -F #{pane_id} ssh root@10.0.0.3",
+			"select-pane -t %6 -T web-3",
+			"select-layout -t @1 tiled",
+		}
+		if sync {
+			want = append(want, "set-window-option -t @1 synchronize-panes on")
+		}
+		assertCommands(t, log(), want)
+	}
+}
+
+func TestClient_error(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tmux is a shell script")
+	}
+	bin := filepath.Join(t.TempDir(), "tmux")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\necho 'no server running' >&2\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	err := (&Client{Bin: bin}).OpenWindow(Pane{Title: "web", Argv: []string{"ssh", "web"}})
+	if err == nil || !strings.Contains(err.Error(), "no server running") {
+		t.Errorf("OpenWindow() = %v, want the error of tmux", err)
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tunnel
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/settings"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+// startTimeout is how long Up waits for the local port of a new tunnel to accept connections.
+const startTimeout = 10 * time.Second
+
+// Manager starts and stops tunnels, keeping their PIDs in a directory.
+type Manager struct {
+	Dir string
+}
+
+// State is what Status found out about a tunnel.
+type State struct {
+	PID     int  // 0 if the tunnel isn't running
+	Healthy bool // the local port accepts connections
+}
+
+// NewManager returns a Manager keeping its files in ~/.sshctx/run.
+func NewManager() (*Manager, error) {
+	dir, err := sshconfig.GetSSHCtxDataDir()
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Join(dir, "run")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrapf(err, "Can't create run dir: %s", dir)
+	}
+	return &Manager{Dir: dir}, nil
+}
+
+// CheckName returns an error if the tunnel name can't be used as a file name in the run dir.
+func CheckName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid tunnel name %q", name)
+	}
+	return nil
+}
+
+func (m *Manager) pidFile(name string) string {
+	return filepath.Join(m.Dir, name+".pid")
+}
+
+func (m *Manager) logFile(name string) string {
+	return filepath.Join(m.Dir, name+".log")
+}
+
+// Args returns the ssh(1) arguments keeping the tunnel open without running a command.
+func Args(t settings.Tunnel, h sshconfig.Host) []string {
+	args := []string{"-N", "-o", "ExitOnForwardFailure=yes"}
+	if t.Socks {
+		args = append(args, "-D", strconv.Itoa(t.LocalPort))
+	} else {
+		args = append(args, "-L", strconv.Itoa(t.LocalPort)+":"+t.Remote)
+	}
+	return append(args, h.ToSSHArgs()...)
+}
+
+// Up starts the tunnel in the background and waits until its local port is open.
+func (m *Manager) Up(t settings.Tunnel, h sshconfig.Host) (int, error) {
+	if err := CheckName(t.Name); err != nil {
+		return 0, err
+	}
+	if pid := m.pid(t.Name); pid != 0 {
+		return pid, fmt.Errorf("tunnel %s is already up (pid %d)", t.Name, pid)
+	}
+	if err := CheckPort(t.LocalPort); err != nil {
+		return 0, err
+	}
+
+	logFile, err := os.Create(m.logFile(t.Name))
+	if err != nil {
+		return 0, errors.Wrap(err, "Can't create tunnel log")
+	}
+	defer func() {
+		_ = logFile.Close()
+	}()
+	cmd := exec.Command("ssh", Args(t, h)...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return 0, errors.Wrap(err, "failed to start ssh")
+	}
+	pid := cmd.Process.Pid
+	if err := writePID(m.pidFile(t.Name), pid); err != nil {
+		_ = cmd.Process.Kill()
+		return 0, errors.Wrap(err, "Can't record tunnel pid")
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	deadline := time.After(startTimeout)
+	for {
+		select {
+		case err := <-exited:
+			_ = os.Remove(m.pidFile(t.Name))
+			out, _ := os.ReadFile(m.logFile(t.Name))
+			return 0, errors.Errorf("ssh exited (%v): %s", err, strings.TrimSpace(string(out)))
+		case <-deadline:
+			return pid, fmt.Errorf("tunnel %s started (pid %d) but port %d doesn't accept connections yet", t.Name, pid, t.LocalPort)
+		case <-time.After(200 * time.Millisecond):
+			if Healthy(t.LocalPort, time.Second) {
+				return pid, nil
+			}
+		}
+	}
+}
+
+// Down stops the tunnel.
+func (m *Manager) Down(name string) error {
+	if err := CheckName(name); err != nil {
+		return err
+	}
+	pid := m.pid(name)
+	if pid == 0 {
+		return fmt.Errorf("tunnel %s is not up", name)
+	}
+	if err := terminate(pid); err != nil {
+		return errors.Wrapf(err, "failed to stop tunnel %s (pid %d)", name, pid)
+	}
+	_ = os.Remove(m.logFile(name))
+	return os.Remove(m.pidFile(name))
+}
+
+// Status reports whether the tunnel is running and healthy.
+func (m *Manager) Status(t settings.Tunnel) State {
+	s := State{PID: m.pid(t.Name)}
+	if s.PID != 0 {
+		s.Healthy = Healthy(t.LocalPort, time.Second)
+	}
+	return s
+}
+
+// Reap removes the PID files of tunnels whose process is gone or whose PID
+// now belongs to another process, returning the names of those tunnels.
+func (m *Manager) Reap() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(m.Dir, "*.pid"))
+	if err != nil {
+		return nil, err
+	}
+	var reaped []string
+	for _, f := range files {
+		name := strings.TrimSuffix(filepath.Base(f), ".pid")
+		if m.pid(name) != 0 {
+			continue
+		}
+		if err := os.Remove(f); err != nil {
+			return reaped, errors.Wrapf(err, "Can't remove %s", f)
+		}
+		reaped = append(reaped, name)
+	}
+	return reaped, nil
+}
+
+// pid returns the PID of the tunnel, or 0 if it isn't running. A process
+// started at another time than the recorded one reuses the PID of a tunnel
+// that is gone, and isn't reported.
+func (m *Manager) pid(name string) int {
+	if CheckName(name) != nil {
+		return 0
+	}
+	pid, started, err := readPID(m.pidFile(name))
+	if err != nil || !alive(pid) {
+		return 0
+	}
+	if st, err := startTime(pid); err != nil || st != started {
+		return 0
+	}
+	return pid
+}
+
+// writePID records the PID and the start time of the process, one per line.
+func writePID(path string, pid int) error {
+	started, err := startTime(pid)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strconv.Itoa(pid)+"\n"+started+"\n"), 0600)
+}
+
+func readPID(path string) (pid int, started string, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, "", err
+	}
+	lines := strings.SplitN(strings.TrimSpace(string(data)), "\n", 2)
+	if len(lines) != 2 {
+		return 0, "", errors.Errorf("%s: missing start time", path)
+	}
+	pid, err = strconv.Atoi(strings.TrimSpace(lines[0]))
+	return pid, strings.TrimSpace(lines[1]), err
+}
+
+// CheckPort returns an error if something already listens on the local port.
+func CheckPort(port int) error {
+	l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return errors.Wrapf(err, "local port %d is not available", port)
+	}
+	return l.Close()
+}
+
+// Healthy reports whether the local port accepts connections.
+func Healthy(port int, timeout time.Duration) bool {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), timeout)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
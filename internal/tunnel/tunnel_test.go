@@ -0,0 +1,154 @@
+package tunnel
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spencercjh/sshctx/internal/settings"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+)
+
+var host = sshconfig.Host{DisplayName: "jump", Host: "10.0.0.1", Username: "root", Port: 2222}
+
+func TestArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		tunnel settings.Tunnel
+		want   []string
+	}{
+		{
+			name:   "local",
+			tunnel: settings.Tunnel{Name: "grafana", LocalPort: 3000, Remote: "localhost:3000"},
+			want:   []string{"-N", "-o", "ExitOnForwardFailure=yes", "-L", "3000:localhost:3000", "-p", "2222", "root@10.0.0.1"},
+		},
+		{
+			name:   "socks",
+			tunnel: settings.Tunnel{Name: "proxy", LocalPort: 1080, Socks: true},
+			want:   []string{"-N", "-o", "ExitOnForwardFailure=yes", "-D", "1080", "-p", "2222", "root@10.0.0.1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Args(tt.tunnel, host); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Args() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPortAndHealthy(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+
+	if err := CheckPort(port); err == nil {
+		t.Errorf("CheckPort() of a port in use should fail")
+	}
+	if !Healthy(port, time.Second) {
+		t.Errorf("Healthy() = false for a listening port")
+	}
+
+	_ = l.Close()
+	if err := CheckPort(port); err != nil {
+		t.Errorf("CheckPort() error = %v for a free port", err)
+	}
+	if Healthy(port, time.Second) {
+		t.Errorf("Healthy() = true for a closed port")
+	}
+}
+
+func TestManager_Reap(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a unix process model")
+	}
+	m := &Manager{Dir: t.TempDir()}
+
+	dead := exec.Command("true")
+	if err := dead.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePID(m.pidFile("alive"), os.Getpid()); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"dead":   strconv.Itoa(dead.Process.Pid) + "\n1\n",
+		"reused": strconv.Itoa(os.Getpid()) + "\n1\n",
+		"old":    strconv.Itoa(os.Getpid()) + "\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(m.pidFile(name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reaped, err := m.Reap()
+	if err != nil {
+		t.Fatalf("Reap() error = %v", err)
+	}
+	sort.Strings(reaped)
+	if want := []string{"dead", "old", "reused"}; !reflect.DeepEqual(reaped, want) {
+		t.Errorf("Reap() = %v, want %v", reaped, want)
+	}
+	if got := m.Status(settings.Tunnel{Name: "alive"}).PID; got != os.Getpid() {
+		t.Errorf("Status().PID = %d, want %d", got, os.Getpid())
+	}
+}
+
+func TestManager_Down(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a unix process model")
+	}
+	m := &Manager{Dir: t.TempDir()}
+	// the PID of a tunnel that is gone, now used by this test
+	content := strconv.Itoa(os.Getpid()) + "\n1\n"
+	if err := os.WriteFile(m.pidFile("reused"), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Down("reused"); err == nil || !strings.Contains(err.Error(), "not up") {
+		t.Errorf("Down() error = %v, want the tunnel not to be up", err)
+	}
+	for _, name := range []string{"", "..", "../reused", `a\b`} {
+		if err := m.Down(name); err == nil || !strings.Contains(err.Error(), "invalid tunnel name") {
+			t.Errorf("Down(%q) error = %v, want an invalid name", name, err)
+		}
+	}
+}
+
+func TestManager_Up_sshFails(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell script as fake ssh")
+	}
+	bin := t.TempDir()
+	script := "#!/bin/sh\necho 'connect to host 10.0.0.1 port 2222: Connection refused' >&2\nexit 255\n"
+	if err := os.WriteFile(filepath.Join(bin, "ssh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	_ = l.Close()
+
+	m := &Manager{Dir: t.TempDir()}
+	_, err = m.Up(settings.Tunnel{Name: "proxy", LocalPort: port, Socks: true}, host)
+	if err == nil || !strings.Contains(err.Error(), "Connection refused") {
+		t.Errorf("Up() error = %v, want the error of ssh", err)
+	}
+	if _, err := os.Stat(m.pidFile("proxy")); !os.IsNotExist(err) {
+		t.Errorf("pid file of a failed tunnel should be removed")
+	}
+}
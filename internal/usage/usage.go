@@ -0,0 +1,76 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spencercjh/sshctx/internal/cmdutil"
+	"github.com/spencercjh/sshctx/internal/sshconfig"
+	"gopkg.in/yaml.v3"
+)
+
+// Path returns the file the last time each host was connected to is kept in.
+func Path() (string, error) {
+	dir, err := sshconfig.GetSSHCtxDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "last_used.yaml"), nil
+}
+
+// Load returns when each host was last connected to, by DisplayName.
+// A missing file means no host was used yet.
+func Load() (map[string]time.Time, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]time.Time{}, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "Can't read last used times")
+	}
+	used := map[string]time.Time{}
+	if err := yaml.Unmarshal(data, &used); err != nil {
+		return nil, errors.Wrapf(err, "Can't parse last used times: %s", path)
+	}
+	return used, nil
+}
+
+// Record remembers that the host was connected to at the given time.
+func Record(displayName string, at time.Time) error {
+	used, err := Load()
+	if err != nil {
+		return err
+	}
+	used[displayName] = at
+	data, err := yaml.Marshal(used)
+	if err != nil {
+		return errors.Wrap(err, "Can't marshal last used times")
+	}
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.Wrap(err, "Can't create sshctx dir")
+	}
+	return errors.Wrap(cmdutil.WriteFileAtomic(path, data, 0600), "Can't write last used times")
+}
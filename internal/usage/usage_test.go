@@ -0,0 +1,44 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package usage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecord(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	if used, err := Load(); err != nil || len(used) != 0 {
+		t.Fatalf("Load() without file = %v, %v", used, err)
+	}
+	first := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	if err := Record("web", first); err != nil {
+		t.Fatal(err)
+	}
+	if err := Record("db", first.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	used, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !used["web"].Equal(first) || !used["db"].Equal(first.Add(time.Hour)) || len(used) != 2 {
+		t.Errorf("Load() = %v", used)
+	}
+}